@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/spf13/viper"
+)
+
+// withAccessList wraps sign so that it attaches an EIP-2930 access list to each transaction
+// before signing it: the one given by --access-list, or with --auto-access-list, the one
+// the node suggests for the transaction.
+func withAccessList(sign bind.SignerFn) bind.SignerFn {
+	return func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		tx = addAccessList(tx, getAccessList(from, tx))
+		return sign(from, tx)
+	}
+}
+
+// getAccessList returns the access list to attach to tx, which is sent from from.
+func getAccessList(from common.Address, tx *types.Transaction) types.AccessList {
+	listJSON := viper.GetString("access-list")
+	if listJSON != "" && viper.GetBool("auto-access-list") {
+		fatal("--access-list and --auto-access-list both set the access list, so only give one of them")
+	}
+	if listJSON != "" {
+		var list types.AccessList
+		check(json.Unmarshal([]byte(listJSON), &list), "parsing --access-list")
+		return list
+	}
+
+	list, _, vmErr, err := gethclient.New(getRPC()).CreateAccessList(context.Background(), ethereum.CallMsg{
+		From:     from,
+		To:       tx.To(),
+		Gas:      tx.Gas(),
+		GasPrice: tx.GasPrice(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	})
+	check(err, "creating access list")
+	if vmErr != "" {
+		fatal("creating access list: the transaction fails: " + vmErr)
+	}
+	return *list
+}
+
+// addAccessList returns a copy of the unsigned transaction tx with list as its access list.
+// Since a legacy transaction can't have one, it becomes an EIP-2930 transaction at the same gas price.
+// The gas limit, which was estimated without the list, is raised by what the list itself costs,
+// unless it was set with --gas-limit.
+func addAccessList(tx *types.Transaction, list types.AccessList) *types.Transaction {
+	gas := tx.Gas()
+	if !isExplicitlySet("gas-limit") {
+		gas += uint64(len(list))*params.TxAccessListAddressGas +
+			uint64(list.StorageKeys())*params.TxAccessListStorageKeyGas
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    getChainID(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  tx.GasTipCap(),
+			GasFeeCap:  tx.GasFeeCap(),
+			Gas:        gas,
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: list,
+		})
+	}
+	return types.NewTx(&types.AccessListTx{
+		ChainID:    getChainID(),
+		Nonce:      tx.Nonce(),
+		GasPrice:   tx.GasPrice(),
+		Gas:        gas,
+		To:         tx.To(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: list,
+	})
+}
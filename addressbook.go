@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/viper"
+)
+
+// addressBook maps the aliases of addresses, as in %treasury, to the addresses or ENS names they stand for.
+// It's loaded by lookupAlias on first use.
+var addressBook map[string]string
+
+// loadAddressBook reads the address book: the aliases in ~/.poke/addresses.toml, like treasury = "0xabc...",
+// and those under the addresses key of the config file, which take precedence.
+// Like flag names, aliases aren't case-sensitive.
+func loadAddressBook() map[string]string {
+	book := make(map[string]string)
+	if home, err := os.UserHomeDir(); err == nil {
+		file := filepath.Join(home, ".poke", "addresses.toml")
+		if _, err := os.Stat(file); err == nil {
+			v := viper.New()
+			v.SetConfigFile(file)
+			check(v.ReadInConfig(), "reading address book "+file)
+			for _, alias := range v.AllKeys() {
+				book[alias] = v.GetString(alias)
+			}
+		}
+	}
+	for alias, address := range viper.GetStringMapString("addresses") {
+		book[strings.ToLower(alias)] = address
+	}
+	return book
+}
+
+// addressBookAlias returns the alias of address in the address book, or "" if it has none.
+// Of several aliases, it returns the first alphabetically. Entries that are ENS names aren't resolved for this.
+func addressBookAlias(address common.Address) string {
+	if addressBook == nil {
+		addressBook = loadAddressBook()
+	}
+	found := ""
+	for alias, entry := range addressBook {
+		if common.IsHexAddress(entry) && common.HexToAddress(entry) == address && (found == "" || alias < found) {
+			found = alias
+		}
+	}
+	return found
+}
+
+// isAlias reports whether s refers to an address book entry, like %treasury.
+func isAlias(s string) bool {
+	return strings.HasPrefix(s, "%")
+}
+
+// lookupAlias returns the address of the address book entry s refers to, like %treasury.
+func lookupAlias(s string) common.Address {
+	if addressBook == nil {
+		addressBook = loadAddressBook()
+	}
+	address, ok := addressBook[strings.ToLower(s[1:])]
+	if !ok {
+		fatalf("%v isn't in the address book. Add it to ~/.poke/addresses.toml, or under addresses in .poke.yaml.\n", s)
+	}
+	if isENSName(address) {
+		return resolveENS(address)
+	}
+	return hexToAddress(address)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// parseArray splits s into the elements of an array argument.
+// s is either a JSON array, or a comma-separated list like "a,b,c", optionally in brackets.
+// Elements that are themselves arrays, like the "[1,2]" in "[[1,2],[3,4]]", are kept whole.
+func parseArray(s string) []string {
+	s = strings.TrimSpace(s)
+	var elems []json.RawMessage
+	if json.Unmarshal([]byte(s), &elems) == nil {
+		parts := make([]string, len(elems))
+		for i, elem := range elems {
+			var str string
+			if json.Unmarshal(elem, &str) == nil {
+				parts[i] = str
+			} else {
+				parts[i] = string(elem)
+			}
+		}
+		return parts
+	}
+
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
+	}
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+// parseArrayArg parses s as a dynamic or fixed-size array of type t, whose elements
+// may be of any type parseArg understands, including other arrays.
+func parseArrayArg(t abi.Type, s string) interface{} {
+	parts := parseArray(s)
+	var value reflect.Value
+	if t.T == abi.ArrayTy {
+		if len(parts) != t.Size {
+			fatalf("%v has %v elements, but %v expects %v\n", s, len(parts), t, t.Size)
+		}
+		value = reflect.New(t.GetType()).Elem()
+	} else {
+		value = reflect.MakeSlice(t.GetType(), len(parts), len(parts))
+	}
+	for i, part := range parts {
+		elem := reflect.ValueOf(parseArg(*t.Elem, part))
+		if !elem.Type().AssignableTo(value.Index(i).Type()) {
+			fatalf("I don't know how to parse arguments of type %v\n", t)
+		}
+		value.Index(i).Set(elem)
+	}
+	return value.Interface()
+}
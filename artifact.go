@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// artifact is a single-contract build artifact, as written by Foundry (out/X.sol/X.json)
+// and Hardhat (artifacts/contracts/X.sol/X.json). The formats are close enough to share a parser:
+// Foundry nests the bytecode in an object and includes the compiler metadata (and so the docs),
+// while Hardhat stores the bytecode as a plain string and names the contract and its source.
+type artifact struct {
+	ABI              json.RawMessage
+	Bytecode         json.RawMessage
+	DeployedBytecode json.RawMessage
+	ContractName     string
+	SourceName       string
+	LinkReferences   linkReferences
+	AST              json.RawMessage
+	Metadata         json.RawMessage
+	StorageLayout    json.RawMessage
+}
+
+// linkReferences lists the libraries a contract links against, keyed by source file and then library name.
+type linkReferences map[string]map[string]json.RawMessage
+
+// artifactMetadata is the part of solc's metadata that poke uses.
+type artifactMetadata struct {
+	Output struct {
+		DevDoc  json.RawMessage
+		UserDoc json.RawMessage
+	}
+	Settings struct {
+		CompilationTarget map[string]string
+	}
+}
+
+// isArtifact reports whether compiled is a Foundry or Hardhat artifact, rather than solc's combined-json output.
+func isArtifact(compiled []byte) bool {
+	var probe struct {
+		ABI json.RawMessage
+	}
+	if json.Unmarshal(compiled, &probe) != nil {
+		return false
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(probe.ABI), []byte("["))
+}
+
+// readABIFile reads a file holding nothing but a contract's ABI into a cacheObject for the contract `name`, without bytecode.
+func readABIFile(filename string, name string) (*cacheObject, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, xerrors.Errorf("reading ABI file: %w", err)
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(contents), []byte("[")) {
+		return nil, xerrors.Errorf("%q should hold a JSON array, like the \"abi\" field of compiler output", filename)
+	}
+	return &cacheObject{ABI: string(contents), Name: name}, nil
+}
+
+// parseArtifact parses a Foundry or Hardhat artifact, read from artifactFile, into a cacheObject.
+// contractName must match the artifact's contract, unless it was only defaulted from the file name.
+func parseArtifact(compiled []byte, contractName string, artifactFile string, defaultContractName bool) (*cacheObject, error) {
+	var art artifact
+	if err := json.Unmarshal(compiled, &art); err != nil {
+		return nil, xerrors.Errorf("failed to decode artifact: %w", err)
+	}
+
+	// Hardhat artifacts leave out the metadata (and so the docs) and the AST,
+	// but the build-info file they were compiled in has both.
+	var buildInfo *combinedJSON
+	if len(art.Metadata) == 0 {
+		var err error
+		buildInfo, err = readArtifactBuildInfo(artifactFile)
+		if err != nil {
+			return nil, err
+		}
+		if buildInfo != nil {
+			output := buildInfo.Contracts[art.SourceName+":"+art.ContractName]
+			art.Metadata = json.RawMessage(output.Metadata)
+			if len(art.StorageLayout) == 0 {
+				art.StorageLayout = json.RawMessage(output.StorageLayout)
+			}
+		}
+	}
+
+	var metadata artifactMetadata
+	var raw []byte
+	if len(art.Metadata) > 0 {
+		raw = []byte(art.Metadata)
+		// Older Foundry versions, like solc itself, store the metadata as a JSON-encoded string.
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			raw = []byte(s)
+		}
+		if err := json.Unmarshal(raw, &metadata); err != nil {
+			return nil, xerrors.Errorf("unmarshaling artifact metadata: %w", err)
+		}
+	}
+
+	// Work out which contract this is an artifact of.
+	name, source := art.ContractName, art.SourceName
+	for file, target := range metadata.Settings.CompilationTarget {
+		if name == "" {
+			name, source = target, file
+		}
+	}
+	if name == "" {
+		name = contractName[strings.LastIndex(contractName, ":")+1:]
+	}
+	if !matchesContract(source+":"+name, contractName) && !defaultContractName {
+		return nil, xerrors.Errorf("the artifact is for the contract %q, not %q", source+":"+name, contractName)
+	}
+
+	// Hardhat stores the bytecode as a string, Foundry as {"object": "0x...", "linkReferences": {...}}.
+	var bin string
+	links := art.LinkReferences
+	if err := json.Unmarshal(art.Bytecode, &bin); err != nil {
+		var bytecode struct {
+			Object         string
+			LinkReferences linkReferences
+		}
+		if err := json.Unmarshal(art.Bytecode, &bytecode); err != nil {
+			return nil, xerrors.Errorf("unmarshaling artifact bytecode: %w", err)
+		}
+		bin = bytecode.Object
+		links = bytecode.LinkReferences
+	}
+	// The deployed bytecode is stored the same way, and is only used to check --address, so it's optional.
+	var binRuntime string
+	if json.Unmarshal(art.DeployedBytecode, &binRuntime) != nil {
+		var bytecode struct {
+			Object string
+		}
+		json.Unmarshal(art.DeployedBytecode, &bytecode)
+		binRuntime = bytecode.Object
+	}
+	contracts := []string{source + ":" + name}
+	for file, libraries := range links {
+		for library := range libraries {
+			contracts = append(contracts, file+":"+library)
+		}
+	}
+
+	devDoc, userDoc, err := parseDocs(metadata.Output.DevDoc, metadata.Output.UserDoc)
+	if err != nil {
+		return nil, err
+	}
+
+	enums := make(map[string][]string)
+	if err := parseEnumDefinitions(art.AST, enums); err != nil {
+		return nil, xerrors.Errorf("reading enums from AST: %w", err)
+	}
+	if buildInfo != nil {
+		for _, source := range buildInfo.Sources {
+			if err := parseEnumDefinitions(source.AST, enums); err != nil {
+				return nil, xerrors.Errorf("reading enums from AST: %w", err)
+			}
+		}
+	}
+	enumInputs, err := parseEnumInputs(string(art.ABI), enums)
+	if err != nil {
+		return nil, xerrors.Errorf("reading enum inputs from ABI: %w", err)
+	}
+
+	return &cacheObject{
+		ABI:       string(art.ABI),
+		DevDoc:    devDoc,
+		UserDoc:   userDoc,
+		Name:      name,
+		Bin:       strings.TrimPrefix(bin, "0x"),
+		Contracts: contracts,
+
+		EnumInputs:    enumInputs,
+		Metadata:      string(raw),
+		StorageLayout: string(art.StorageLayout),
+		BinRuntime:    strings.TrimPrefix(binRuntime, "0x"),
+	}, nil
+}
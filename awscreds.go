@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// awsCredentials are the keys AWS requests are signed with. SessionToken is only set for temporary credentials.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// awsCreds caches the credentials awsCredentialChain found.
+var awsCreds *awsCredentials
+
+// metadataClient is for the metadata services of cloud machines, which don't exist elsewhere,
+// so there's no point waiting long for them.
+var metadataClient = &http.Client{Timeout: 2 * time.Second}
+
+// awsCredentialChain finds AWS credentials where the AWS CLI and SDKs look for them, in the same order:
+//
+//   - the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables,
+//   - a web identity token to assume AWS_ROLE_ARN with, from AWS_WEB_IDENTITY_TOKEN_FILE, as on EKS (IRSA),
+//   - the AWS_PROFILE profile, or the default one, in ~/.aws/credentials and ~/.aws/config: its keys, or its credential_process,
+//   - the credentials of an ECS task's role, and
+//   - those of an EC2 instance's role.
+//
+// SSO profiles aren't read directly; give them a credential_process that runs `aws configure export-credentials`.
+func awsCredentialChain() (awsCredentials, error) {
+	if awsCreds != nil {
+		return *awsCreds, nil
+	}
+	creds, err := findAWSCredentials()
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	awsCreds = &creds
+	return creds, nil
+}
+
+func findAWSCredentials() (awsCredentials, error) {
+	if accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); accessKey != "" && secretKey != "" {
+		return awsCredentials{accessKey, secretKey, os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+	if tokenFile, role := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN"); tokenFile != "" && role != "" {
+		creds, err := awsWebIdentityCredentials(tokenFile, role)
+		if err != nil {
+			return awsCredentials{}, xerrors.Errorf("assuming %v with the web identity token in %v: %w", role, tokenFile, err)
+		}
+		return creds, nil
+	}
+	profile := awsProfile()
+	if creds, ok, err := awsProfileCredentials(profile); ok || err != nil {
+		if err != nil {
+			return awsCredentials{}, xerrors.Errorf("AWS profile %v: %w", profile, err)
+		}
+		return creds, nil
+	}
+	if creds, ok, err := awsContainerCredentials(); ok || err != nil {
+		if err != nil {
+			return awsCredentials{}, xerrors.Errorf("getting the ECS task's credentials: %w", err)
+		}
+		return creds, nil
+	}
+	if creds, err := awsInstanceCredentials(); err == nil {
+		return creds, nil
+	}
+	return awsCredentials{}, xerrors.New("no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, " +
+		"or AWS_PROFILE to a profile in ~/.aws/credentials or ~/.aws/config, or run on an AWS machine with a role")
+}
+
+// awsProfile returns the name of the profile in the shared config files to use.
+func awsProfile() string {
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile
+	}
+	return "default"
+}
+
+// awsSharedFile returns the shared credentials or config file: the one env names, or else the one in ~/.aws.
+func awsSharedFile(env, name string) string {
+	if file := os.Getenv(env); file != "" {
+		return file
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".aws", name)
+}
+
+// awsProfileSettings returns the settings of profile from the shared credentials and config files,
+// those in the credentials file taking precedence. In the config file, profiles other than the default
+// are in sections named like [profile name].
+func awsProfileSettings(profile string) (map[string]string, error) {
+	settings := make(map[string]string)
+	configSection := "profile " + profile
+	if profile == "default" {
+		configSection = "default"
+	}
+	for _, file := range []struct{ name, section string }{
+		{awsSharedFile("AWS_CONFIG_FILE", "config"), configSection},
+		{awsSharedFile("AWS_SHARED_CREDENTIALS_FILE", "credentials"), profile},
+	} {
+		section, err := readINISection(file.name, file.section)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range section {
+			settings[key] = value
+		}
+	}
+	return settings, nil
+}
+
+// readINISection returns the keys and values in section of the INI file, which may not exist.
+func readINISection(file, section string) (map[string]string, error) {
+	values := make(map[string]string)
+	f, err := os.Open(file)
+	if os.IsNotExist(err) || file == "" {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	inSection := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			inSection = strings.TrimSpace(line[1:len(line)-1]) == section
+		case inSection:
+			if i := strings.Index(line, "="); i != -1 {
+				values[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+			}
+		}
+	}
+	return values, scanner.Err()
+}
+
+// awsProfileCredentials returns the credentials of profile from the shared files, if it has any.
+func awsProfileCredentials(profile string) (creds awsCredentials, ok bool, err error) {
+	settings, err := awsProfileSettings(profile)
+	if err != nil {
+		return awsCredentials{}, false, err
+	}
+	if settings["aws_access_key_id"] != "" && settings["aws_secret_access_key"] != "" {
+		return awsCredentials{settings["aws_access_key_id"], settings["aws_secret_access_key"], settings["aws_session_token"]}, true, nil
+	}
+	if process := settings["credential_process"]; process != "" {
+		creds, err := awsProcessCredentials(process)
+		return creds, true, err
+	}
+	if settings["sso_start_url"] != "" || settings["sso_session"] != "" {
+		return awsCredentials{}, true, xerrors.New("SSO profiles need a credential_process, like `aws configure export-credentials --profile " +
+			profile + " --format process`")
+	}
+	return awsCredentials{}, false, nil
+}
+
+// awsProcessCredentials runs a profile's credential_process, which prints the credentials as JSON.
+func awsProcessCredentials(process string) (awsCredentials, error) {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd := exec.Command(shell, flag, process)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return awsCredentials{}, xerrors.Errorf("running credential_process: %w", err)
+	}
+	var response struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		SessionToken    string
+	}
+	if err := json.Unmarshal(out, &response); err != nil {
+		return awsCredentials{}, xerrors.Errorf("reading the output of credential_process: %w", err)
+	}
+	return awsCredentials{response.AccessKeyID, response.SecretAccessKey, response.SessionToken}, nil
+}
+
+// awsWebIdentityCredentials trades the web identity token in tokenFile for temporary credentials of role,
+// with STS's AssumeRoleWithWebIdentity, which needs no credentials itself. AWS_ENDPOINT_URL_STS overrides STS's endpoint.
+func awsWebIdentityCredentials(tokenFile, role string) (awsCredentials, error) {
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_STS")
+	if endpoint == "" {
+		endpoint = "https://sts.amazonaws.com"
+		if region := awsRegion(); region != "" {
+			endpoint = "https://sts." + region + ".amazonaws.com"
+		}
+	}
+	session := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if session == "" {
+		session = "poke"
+	}
+	resp, err := kmsClient.PostForm(strings.TrimSuffix(endpoint, "/")+"/", url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {role},
+		"RoleSessionName":  {session},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	})
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error struct {
+				Code    string
+				Message string
+			}
+		}
+		xml.NewDecoder(resp.Body).Decode(&failure)
+		return awsCredentials{}, xerrors.Errorf("STS: HTTP %v: %v %v", resp.Status, failure.Error.Code, failure.Error.Message)
+	}
+	var response struct {
+		Credentials struct {
+			AccessKeyID     string `xml:"AccessKeyId"`
+			SecretAccessKey string
+			SessionToken    string
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return awsCredentials{}, xerrors.Errorf("reading STS's response: %w", err)
+	}
+	c := response.Credentials
+	return awsCredentials{c.AccessKeyID, c.SecretAccessKey, c.SessionToken}, nil
+}
+
+// awsContainerCredentials returns the credentials of an ECS task's role, from the endpoint
+// AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or AWS_CONTAINER_CREDENTIALS_FULL_URI names, if either is set.
+func awsContainerCredentials() (creds awsCredentials, ok bool, err error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		endpoint = "http://169.254.170.2" + relative
+	}
+	if endpoint == "" {
+		return awsCredentials{}, false, nil
+	}
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return awsCredentials{}, true, err
+	}
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	creds, err = awsRoleCredentials(req)
+	return creds, true, err
+}
+
+// awsInstanceCredentials returns the credentials of an EC2 instance's role, from its metadata service (IMDSv2),
+// unless AWS_EC2_METADATA_DISABLED is true.
+func awsInstanceCredentials() (awsCredentials, error) {
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return awsCredentials{}, xerrors.New("the EC2 metadata service is disabled")
+	}
+	const imds = "http://169.254.169.254/latest"
+	req, err := http.NewRequest("PUT", imds+"/api/token", nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	token, err := metadataGet(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req, err = http.NewRequest("GET", imds+"/meta-data/iam/security-credentials/", nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	role, err := metadataGet(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req, err = http.NewRequest("GET", imds+"/meta-data/iam/security-credentials/"+strings.TrimSpace(strings.SplitN(role, "\n", 2)[0]), nil)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	return awsRoleCredentials(req)
+}
+
+// metadataGet makes req to a metadata service, and returns the body of its response.
+func metadataGet(req *http.Request) (string, error) {
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", xerrors.Errorf("HTTP %v from %v: %s", resp.Status, req.URL, strings.TrimSpace(string(body)))
+	}
+	return string(body), nil
+}
+
+// awsRoleCredentials makes req to an ECS or EC2 credentials endpoint, which respond with the same JSON.
+func awsRoleCredentials(req *http.Request) (awsCredentials, error) {
+	body, err := metadataGet(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	var response struct {
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		Token           string
+	}
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		return awsCredentials{}, xerrors.Errorf("reading credentials from %v: %w", req.URL, err)
+	}
+	if response.AccessKeyID == "" || response.SecretAccessKey == "" {
+		return awsCredentials{}, xerrors.Errorf("%v returned no credentials", req.URL)
+	}
+	return awsCredentials{response.AccessKeyID, response.SecretAccessKey, response.Token}, nil
+}
+
+// awsRegion returns the region from AWS_REGION, AWS_DEFAULT_REGION, or the profile in the shared config file.
+func awsRegion() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	if region := os.Getenv("AWS_DEFAULT_REGION"); region != "" {
+		return region
+	}
+	settings, err := awsProfileSettings(awsProfile())
+	if err != nil {
+		return ""
+	}
+	return settings["region"]
+}
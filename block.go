@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/spf13/viper"
+)
+
+var (
+	// resolvedBlock caches the block from the `block` flag, which may take a request to the node to find.
+	resolvedBlock    *big.Int
+	hasResolvedBlock bool
+)
+
+// callBlock returns the number of the block that calls read the chain's state at, from the `block` flag.
+// That's a block number, a block hash, or a tag: latest, pending, earliest, safe, or finalized.
+// It returns nil for the latest block, and -1 for the pending one, as ethclient expects.
+func callBlock() *big.Int {
+	if !hasResolvedBlock {
+		resolvedBlock = resolveBlock(viper.GetString("block"))
+		hasResolvedBlock = true
+	}
+	return resolvedBlock
+}
+
+// resolveBlock turns the `block` flag's value into a block number, as for callBlock.
+func resolveBlock(block string) *big.Int {
+	switch strings.ToLower(block) {
+	case "", "latest":
+		return nil
+	case "pending":
+		return big.NewInt(-1)
+	case "earliest":
+		return big.NewInt(0)
+	case "safe", "finalized":
+		// ethclient can't ask for these tags itself.
+		var header struct {
+			Number *hexutil.Big
+		}
+		check(getRPC().Call(&header, "eth_getBlockByNumber", strings.ToLower(block), false), "looking up the "+block+" block")
+		if header.Number == nil {
+			fatalf("The node has no %v block\n", block)
+		}
+		return header.Number.ToInt()
+	}
+	if strings.HasPrefix(block, "0x") && len(block) == 2+2*common.HashLength {
+		header, err := getNode().HeaderByHash(context.Background(), common.HexToHash(block))
+		check(err, "looking up block "+block)
+		return header.Number
+	}
+	n, ok := new(big.Int).SetString(block, 0)
+	if !ok || n.Sign() < 0 {
+		fatalf("--block %q isn't a block number, hash, or tag\n", block)
+	}
+	return n
+}
+
+// blockTag returns the block parameter of JSON-RPC methods for blockNumber, as callBlock returns it.
+func blockTag(blockNumber *big.Int) string {
+	switch {
+	case blockNumber == nil:
+		return "latest"
+	case blockNumber.Sign() < 0:
+		return "pending"
+	}
+	return hexutil.EncodeBig(blockNumber)
+}
+
+// callOpts returns the options to call the contract's view functions with, reading state at callBlock.
+func callOpts() *bind.CallOpts {
+	return &bind.CallOpts{BlockNumber: callBlock()}
+}
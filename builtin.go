@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/spf13/cobra"
+)
+
+// builtinSuite is a set of commands for contracts of a standard interface, whose ABI poke bundles.
+// Its name takes the place of the input file, followed by the contract's address: poke erc20 <address> <command>.
+type builtinSuite struct {
+	// abi is the standard interface, as a JSON array.
+	abi string
+	// contract names the contract, e.g. in confirmation prompts.
+	contract string
+	// commands lists the suite's commands, in the usage message.
+	commands string
+	// cmds returns the suite's commands, the calls and the transactions, which take the place of those for each function.
+	cmds func(theABI abi.ABI) (calls []*cobra.Command, transactions []*cobra.Command)
+}
+
+var builtinSuites = map[string]builtinSuite{
+	"erc20":   {abi: erc20ABI, contract: "ERC20", commands: "balance|transfer|approve|allowance|metadata|permit", cmds: erc20Cmds},
+	"erc721":  {abi: erc721ABI, contract: "ERC721", commands: "ownerOf|balanceOf|tokenURI|metadata|safeTransferFrom", cmds: erc721Cmds},
+	"erc1155": {abi: erc1155ABI, contract: "ERC1155", commands: "balanceOf|uri|metadata|safeTransferFrom", cmds: erc1155Cmds},
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/cobra"
+)
+
+// expectedRuntime is the hex-encoded code the contract deploys, from the compiler output,
+// or "" if the output doesn't include it.
+var expectedRuntime string
+
+// bytecodeChecked records the addresses checkBytecode has checked, so it only warns once about each.
+var bytecodeChecked = make(map[common.Address]bool)
+
+// compareBytecode reports whether deployed, the code at an address, is the code that compiledHex,
+// the hex-encoded runtime bytecode from the compiler, deploys. It ignores what can differ between
+// deployments of the same contract: the metadata hash solc appends, immutables, and linked library addresses.
+// If they differ, it also returns the offset of the first difference.
+func compareBytecode(compiledHex string, deployed []byte) (bool, int) {
+	// Library placeholders are 20-byte addresses, filled in at deployment.
+	masked := make(map[int]bool)
+	for _, match := range placeholderRegexp.FindAllStringIndex(compiledHex, -1) {
+		for i := match[0] / 2; i < match[1]/2; i++ {
+			masked[i] = true
+		}
+	}
+	compiled, err := hex.DecodeString(placeholderRegexp.ReplaceAllString(compiledHex, "0000000000000000000000000000000000000000"))
+	check(err, "decoding compiled bytecode")
+	compiled, deployed = stripMetadata(compiled), stripMetadata(deployed)
+
+	// solc leaves immutables as PUSH32 0, and a library's own address, which it checks calls against, as PUSH20 0.
+	for i := 0; i < len(compiled); i++ {
+		op := compiled[i]
+		if op < 0x60 || op > 0x7f {
+			continue
+		}
+		size := int(op) - 0x5f
+		if i+1+size <= len(compiled) && (op == 0x7f || (op == 0x73 && i == 0)) && isZero(compiled[i+1:i+1+size]) {
+			for j := i + 1; j < i+1+size; j++ {
+				masked[j] = true
+			}
+		}
+		i += size
+	}
+
+	for i := 0; i < len(compiled) && i < len(deployed); i++ {
+		if compiled[i] != deployed[i] && !masked[i] {
+			return false, i
+		}
+	}
+	if len(compiled) != len(deployed) {
+		if len(compiled) < len(deployed) {
+			return false, len(compiled)
+		}
+		return false, len(deployed)
+	}
+	return true, 0
+}
+
+// stripMetadata removes the CBOR-encoded metadata solc appends to code, whose length is in its last two bytes.
+func stripMetadata(code []byte) []byte {
+	if len(code) < 2 {
+		return code
+	}
+	length := int(code[len(code)-2])<<8 | int(code[len(code)-1])
+	start := len(code) - 2 - length
+	// The metadata is a CBOR map, of up to a handful of entries.
+	if length == 0 || start < 0 || code[start] < 0xa1 || code[start] > 0xa5 {
+		return code
+	}
+	return code[:start]
+}
+
+// isZero reports whether b is all zeros.
+func isZero(b []byte) bool {
+	for _, x := range b {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bytecodeMatches compares the code at address with expectedRuntime. If address is a proxy, whose code
+// never matches, it compares its implementation's code instead. It returns a description of the result.
+func bytecodeMatches(name string, address common.Address) (bool, string) {
+	code, err := getNode().CodeAt(context.Background(), address, callBlock())
+	check(err, "retrieving code")
+	if len(code) == 0 {
+		return false, fmt.Sprintf("There's no contract at %v.", address.Hex())
+	}
+	matches, offset := compareBytecode(expectedRuntime, code)
+	if matches {
+		return true, fmt.Sprintf("The code at %v matches the compiled %v.", address.Hex(), name)
+	}
+	if implementation, kind, ok := proxyImplementation(address); ok {
+		code, err := getNode().CodeAt(context.Background(), implementation, callBlock())
+		check(err, "retrieving code")
+		if matches, offset := compareBytecode(expectedRuntime, code); !matches {
+			return false, fmt.Sprintf("%v is a proxy (%v), but the code of its implementation at %v doesn't match the compiled %v, from byte %v on.",
+				address.Hex(), kind, implementation.Hex(), name, offset)
+		}
+		return true, fmt.Sprintf("%v is a proxy (%v), and the code of its implementation at %v matches the compiled %v.",
+			address.Hex(), kind, implementation.Hex(), name)
+	}
+	return false, fmt.Sprintf("The code at %v doesn't match the compiled %v, from byte %v on.", address.Hex(), name, offset)
+}
+
+// checkBytecode warns, once, if the code at --address isn't the contract name, before poke sends it a transaction.
+func checkBytecode(name string) {
+	address := getContractAddress()
+	if bytecodeChecked[address] || expectedRuntime == "" {
+		return
+	}
+	bytecodeChecked[address] = true
+	if ok, description := bytecodeMatches(name, address); !ok {
+		fmt.Fprintf(os.Stderr, "Warning: %v Is --address right?\n", description)
+	}
+}
+
+// verifyBytecodeCmd checks that --address holds the compiled contract.
+func verifyBytecodeCmd(name string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify-bytecode",
+		Short: "Check that the code at --address is the compiled contract's",
+		Long: "Compares the code at --address with the compiled contract's deployed bytecode, ignoring the metadata hash, " +
+			"immutables, and linked libraries, which differ between deployments. If --address is a proxy, " +
+			"it compares its implementation's code instead.",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if expectedRuntime == "" {
+				fatal("The compiler output has no deployed bytecode to compare with.")
+			}
+			ok, description := bytecodeMatches(name, getContractAddress())
+			if !ok {
+				fatal(description)
+			}
+			fmt.Println(description)
+		},
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// bytesTypeRegexp matches the names of Solidity's byte array types, bytes and bytes1 through bytes32.
+var bytesTypeRegexp = regexp.MustCompile(`^bytes([0-9]*)$`)
+
+// bytesSolType returns the solType of bytes, or of bytesN if size isn't 0.
+// go-ethereum represents bytes as a []byte, and bytesN as a [N]byte.
+func bytesSolType(size int) solType {
+	if size == 0 {
+		return solType{
+			parser: func(s string) interface{} {
+				return parseBytes(s)
+			},
+			toString: func(i interface{}) string {
+				return hexutil.Encode(*i.(*[]byte))
+			},
+			goType: func() interface{} {
+				return new([]byte)
+			},
+		}
+	}
+	arrayType := reflect.ArrayOf(size, reflect.TypeOf(byte(0)))
+	return solType{
+		parser: func(s string) interface{} {
+			b := parseBytes(s)
+			if len(b) != size {
+				fatalf("bytes%v needs exactly %v bytes, but %q is %v bytes long\n", size, size, s, len(b))
+			}
+			array := reflect.New(arrayType).Elem()
+			reflect.Copy(array, reflect.ValueOf(b))
+			return array.Interface()
+		},
+		toString: func(i interface{}) string {
+			array := reflect.ValueOf(i).Elem()
+			b := make([]byte, size)
+			reflect.Copy(reflect.ValueOf(b), array)
+			return hexutil.Encode(b)
+		},
+		goType: func() interface{} {
+			return reflect.New(arrayType).Interface()
+		},
+	}
+}
+
+// lookupBytesSolType returns the solType of the byte array type named name, if it is one.
+func lookupBytesSolType(name string) (solType, bool) {
+	match := bytesTypeRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return solType{}, false
+	}
+	if match[1] == "" {
+		return bytesSolType(0), true
+	}
+	size, err := strconv.Atoi(match[1])
+	if err != nil || size < 1 || size > 32 {
+		return solType{}, false
+	}
+	return bytesSolType(size), true
+}
+
+// parseBytes parses 0x-prefixed hex into bytes.
+// Alternatively, if s begins with "@", parseBytes reads the raw contents of the file named s[1:].
+func parseBytes(s string) []byte {
+	if strings.HasPrefix(s, "@") {
+		b, err := ioutil.ReadFile(s[1:])
+		check(err, fmt.Sprintf("reading bytes from %v", s[1:]))
+		return b
+	}
+	b, err := hexutil.Decode(s)
+	check(err, fmt.Sprintf("failed to parse %q as 0x-prefixed hex bytes", s))
+	return b
+}
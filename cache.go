@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// compileCacheEntry is a cached compilation: solc's output, and the hash of each source file it read.
+type compileCacheEntry struct {
+	Sources map[string]string
+	Output  json.RawMessage
+}
+
+// cachedCompile returns the output of running solc with args, from ~/.cache/poke if an earlier run with
+// the same solc binary and args read source files that are all unchanged, and otherwise from compile.
+// Which files a compilation read, imports included, comes from the sources in solc's output.
+func cachedCompile(solc string, args []string, compile func() ([]byte, error)) ([]byte, error) {
+	cacheFile := compileCacheFile(solc, args)
+	if cacheFile == "" || viper.GetBool("no-cache") {
+		return compile()
+	}
+
+	if cached, err := ioutil.ReadFile(cacheFile); err == nil {
+		var entry compileCacheEntry
+		if json.Unmarshal(cached, &entry) == nil && sourcesUnchanged(entry.Sources) {
+			return entry.Output, nil
+		}
+	}
+
+	output, err := compile()
+	if err != nil {
+		return nil, err
+	}
+	var parsed combinedJSON
+	if json.Unmarshal(output, &parsed) != nil {
+		return output, nil
+	}
+	entry := compileCacheEntry{Sources: make(map[string]string), Output: output}
+	for source := range parsed.Sources {
+		hash, err := hashFile(source)
+		if err != nil {
+			// A source poke can't find again can't be checked, so don't cache this compilation.
+			return output, nil
+		}
+		entry.Sources[source] = hash
+	}
+	// Failing to cache only makes the next run slower, so ignore errors.
+	if encoded, err := json.Marshal(entry); err == nil && os.MkdirAll(filepath.Dir(cacheFile), 0755) == nil {
+		ioutil.WriteFile(cacheFile, encoded, 0644)
+	}
+	return output, nil
+}
+
+// compileCacheFile returns the file to cache the output of running solc with args in, or "" if there's
+// no cache directory. It depends on the solc binary, down to its size and modification time, on SOLC_VERSION,
+// which picks the real binary behind solc-select's, and on the working directory, which relative source
+// paths are resolved against.
+func compileCacheFile(solc string, args []string) string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	solcPath, err := exec.LookPath(solc)
+	if err != nil {
+		return ""
+	}
+	info, err := os.Stat(solcPath)
+	if err != nil {
+		return ""
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	key := sha256.Sum256([]byte(fmt.Sprintf(
+		"%v\x00%v\x00%v\x00%v\x00%v\x00%v",
+		solcPath, info.Size(), info.ModTime().UnixNano(), os.Getenv("SOLC_VERSION"), wd, strings.Join(args, "\x00"),
+	)))
+	return filepath.Join(cacheDir, "poke", hex.EncodeToString(key[:])+".json")
+}
+
+// sourcesUnchanged reports whether each of sources, a map from file to the hash of its contents, still has that hash.
+func sourcesUnchanged(sources map[string]string) bool {
+	for source, want := range sources {
+		if got, err := hashFile(source); err != nil || got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// hashFile returns the hex-encoded SHA-256 hash of the contents of file.
+func hashFile(file string) (string, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+// fourByteURL is 4byte.directory's API for looking up function signatures by selector.
+const fourByteURL = "https://www.4byte.directory/api/v1/signatures/"
+
+// intAliasRegexp matches uint and int, which are short for uint256 and int256, in a type.
+var intAliasRegexp = regexp.MustCompile(`^(u?int)(\[|$)`)
+
+// encodeCmd prints the calldata of a call to one of theABI's functions, without making it.
+func encodeCmd(theABI abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "encode <method> [arg...]",
+		Short: "Print the ABI-encoded calldata of a call, without making it",
+		Long: "Encodes a call to a function of the contract, named by its name or signature, and prints the calldata as hex, " +
+			"to pass to a multisig or another tool.\nA signature that isn't in the ABI, like \"transfer(address,uint256)\", works too.",
+		Example: "  poke Token.sol encode transfer @1 1e18\n" +
+			"  poke Token.sol encode 'permit(address,address,uint256,uint256,uint8,bytes32,bytes32)' ...",
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			method, err := lookupMethod(theABI, args[0])
+			if err != nil && strings.Contains(args[0], "(") {
+				method, err = parseSignature(args[0])
+			}
+			check(err, "encode")
+			if len(args)-1 != len(method.Inputs) {
+				fatalf("%v takes %v arguments, but got %v\n", method.Sig, len(method.Inputs), len(args)-1)
+			}
+			packed, err := method.Inputs.Pack(parseArgs(method.Sig, method.Inputs, args[1:])...)
+			check(err, "encoding arguments")
+			printResult(hexutil.Encode(append(method.ID, packed...)))
+		},
+	}
+}
+
+// decodeCmd decodes calldata as a call to one of theABI's functions, or, with --4byte,
+// any function 4byte.directory knows.
+func decodeCmd(theABI abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "decode <0xcalldata>",
+		Short: "Decode calldata into the function it calls and its arguments",
+		Long: "Matches the selector of calldata against the contract's ABI, and the --extra-abi files, and prints " +
+			"the function and its decoded arguments.\nWith --4byte, selectors that aren't in any of them are looked up " +
+			"on 4byte.directory. Different functions can share a selector, so check what it finds.",
+		Example: "  poke Token.sol decode 0xa9059cbb0000000000000000000000006ecbe1db9ef729cbe972c83fb886247691fb6beb0000000000000000000000000000000000000000000000000de0b6b3a7640000\n" +
+			"  poke Token.sol decode --4byte 0x3593564c...",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			data, err := hexutil.Decode(args[0])
+			check(err, "decoding calldata")
+			method, values, err := decodeCalldata(theABI, data)
+			check(err, "decode")
+			printCall(method, values)
+		},
+	}
+}
+
+// decodeCalldata decodes data as a call to one of theABI's functions, or the --extra-abi files',
+// or, with --4byte, to one of the functions 4byte.directory knows with its selector.
+func decodeCalldata(theABI abi.ABI, data []byte) (abi.Method, []interface{}, error) {
+	if len(data) < 4 {
+		return abi.Method{}, nil, xerrors.Errorf("calldata starts with a 4-byte selector, but %v is %v bytes", hexutil.Encode(data), len(data))
+	}
+	if otherEventABIs == nil {
+		otherEventABIs = loadOtherEventABIs()
+	}
+	for _, candidateABI := range append([]abi.ABI{theABI}, otherEventABIs...) {
+		method, err := candidateABI.MethodById(data[:4])
+		if err != nil {
+			continue
+		}
+		values, err := method.Inputs.UnpackValues(data[4:])
+		if err != nil {
+			return abi.Method{}, nil, xerrors.Errorf("decoding arguments of %v: %w", method.Sig, err)
+		}
+		return *method, values, nil
+	}
+	if !viper.GetBool("4byte") {
+		return abi.Method{}, nil, xerrors.Errorf("the selector %v isn't in the ABI; look it up on 4byte.directory with --4byte", hexutil.Encode(data[:4]))
+	}
+	signatures, err := lookupSelector(data[:4])
+	if err != nil {
+		return abi.Method{}, nil, xerrors.Errorf("looking up the selector on 4byte.directory: %w", err)
+	}
+	for _, signature := range signatures {
+		method, err := parseSignature(signature)
+		if err != nil {
+			continue
+		}
+		values, err := method.Inputs.UnpackValues(data[4:])
+		if err != nil {
+			continue
+		}
+		// Calldata that decodes as a signature's arguments can still be too long or short for them.
+		if packed, err := method.Inputs.Pack(values...); err != nil || !bytes.Equal(packed, data[4:]) {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Matched %v on 4byte.directory.\n", method.Sig)
+		return method, values, nil
+	}
+	return abi.Method{}, nil, xerrors.Errorf("none of the %v signatures 4byte.directory knows for %v decode this calldata", len(signatures), hexutil.Encode(data[:4]))
+}
+
+// printCall prints a call to method with the decoded arguments values: the function's signature,
+// then each argument, labelled with its name, or its position if it's unnamed.
+func printCall(method abi.Method, values []interface{}) {
+	names := make([]string, len(method.Inputs))
+	for i, input := range method.Inputs {
+		names[i] = input.Name
+		if names[i] == "" {
+			names[i] = fmt.Sprint(i)
+		}
+	}
+	if jsonOutput() {
+		arguments := make(map[string]string)
+		for i, input := range method.Inputs {
+			arguments[names[i]] = displayValue(input.Type, values[i])
+		}
+		printJSON(map[string]interface{}{"function": method.Sig, "arguments": arguments})
+		return
+	}
+	fmt.Println(method.Sig)
+	for i, input := range method.Inputs {
+		fmt.Printf("  %v: %v\n", names[i], displayValue(input.Type, values[i]))
+	}
+}
+
+// lookupSelector returns the text signatures of the functions with selector that 4byte.directory knows, oldest first.
+func lookupSelector(selector []byte) ([]string, error) {
+	resp, err := http.Get(fourByteURL + "?hex_signature=" + hexutil.Encode(selector))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, xerrors.Errorf("HTTP %v", resp.Status)
+	}
+	var response struct {
+		Results []struct {
+			ID            int    `json:"id"`
+			TextSignature string `json:"text_signature"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, xerrors.Errorf("decoding 4byte.directory response: %w", err)
+	}
+	// Later submissions sharing a selector are more likely to be collisions made on purpose.
+	sort.Slice(response.Results, func(i, j int) bool {
+		return response.Results[i].ID < response.Results[j].ID
+	})
+	signatures := make([]string, len(response.Results))
+	for i, result := range response.Results {
+		signatures[i] = result.TextSignature
+	}
+	return signatures, nil
+}
+
+// parseSignature parses a function signature, like "transfer(address,uint256)", into a method
+// with unnamed inputs. Tuples are written as parenthesized lists of types, as in selectors.
+func parseSignature(signature string) (abi.Method, error) {
+	open := strings.Index(signature, "(")
+	if open <= 0 || !strings.HasSuffix(signature, ")") {
+		return abi.Method{}, xerrors.Errorf("%q isn't a function signature like transfer(address,uint256)", signature)
+	}
+	name := strings.TrimSpace(signature[:open])
+	parts, err := splitSignatureTypes(signature[open+1 : len(signature)-1])
+	if err != nil {
+		return abi.Method{}, xerrors.Errorf("parsing %q: %w", signature, err)
+	}
+	var inputs abi.Arguments
+	for _, part := range parts {
+		marshaling, err := signatureComponent(part, "")
+		if err != nil {
+			return abi.Method{}, xerrors.Errorf("parsing %q: %w", signature, err)
+		}
+		t, err := abi.NewType(marshaling.Type, "", marshaling.Components)
+		if err != nil {
+			return abi.Method{}, xerrors.Errorf("parsing %q: %w", signature, err)
+		}
+		inputs = append(inputs, abi.Argument{Type: t})
+	}
+	return abi.NewMethod(name, name, abi.Function, "nonpayable", false, false, inputs, nil), nil
+}
+
+// signatureComponent describes the type s, from a signature, as go-ethereum's ABI JSON parsing would,
+// so that tuples, which abi.NewType can't parse from their signature, get their components.
+func signatureComponent(s string, name string) (abi.ArgumentMarshaling, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return abi.ArgumentMarshaling{}, xerrors.New("missing type")
+	}
+	if !strings.HasPrefix(s, "(") {
+		// Drop anything after the type, like "indexed" or the argument's name, and spell out
+		// uint and int as uint256 and int256, as selectors do.
+		t := intAliasRegexp.ReplaceAllString(strings.Fields(s)[0], "${1}256$2")
+		return abi.ArgumentMarshaling{Name: name, Type: t}, nil
+	}
+	end := strings.LastIndex(s, ")")
+	parts, err := splitSignatureTypes(s[1:end])
+	if err != nil {
+		return abi.ArgumentMarshaling{}, err
+	}
+	suffix := ""
+	if fields := strings.Fields(s[end+1:]); len(fields) > 0 && strings.HasPrefix(fields[0], "[") {
+		suffix = fields[0]
+	}
+	marshaling := abi.ArgumentMarshaling{Name: name, Type: "tuple" + suffix}
+	for i, part := range parts {
+		// Signatures don't name tuple fields, but go-ethereum needs names to decode them into structs.
+		component, err := signatureComponent(part, fmt.Sprintf("field%v", i))
+		if err != nil {
+			return abi.ArgumentMarshaling{}, err
+		}
+		marshaling.Components = append(marshaling.Components, component)
+	}
+	return marshaling, nil
+}
+
+// splitSignatureTypes splits a comma-separated list of types at the commas outside parentheses.
+func splitSignatureTypes(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, xerrors.New("unbalanced parentheses")
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, xerrors.New("unbalanced parentheses")
+	}
+	return append(parts, s[start:]), nil
+}
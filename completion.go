@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/xerrors"
+)
+
+// completionScripts are the scripts `poke completion <shell>` prints. Each has the shell
+// call `poke __complete <word...>` with the words of the command line so far, the last
+// being the one to complete, and falls back to completing file names when poke has nothing to offer.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for poke. Load it with: source <(poke completion bash)
+_poke() {
+	local IFS=$'\n'
+	COMPREPLY=($(poke __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _poke poke
+`,
+	"zsh": `#compdef poke
+# zsh completion for poke. Load it with: source <(poke completion zsh)
+_poke() {
+	local -a completions
+	completions=("${(@f)$(poke __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	if [[ -n "${completions[1]}" ]]; then
+		compadd -a completions
+	else
+		_files
+	fi
+}
+compdef _poke poke
+`,
+	"fish": `# fish completion for poke. Load it with: poke completion fish | source
+function __poke_complete
+	set -l words (commandline -opc)
+	poke __complete $words[2..-1] (commandline -ct) 2>/dev/null
+end
+complete -c poke -a '(__poke_complete)'
+`,
+	"powershell": `# PowerShell completion for poke. Load it with: poke completion powershell | Out-String | Invoke-Expression
+Register-ArgumentCompleter -Native -CommandName poke -ScriptBlock {
+	param($wordToComplete, $commandAst, $cursorPosition)
+	$words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+	if ($wordToComplete) {
+		$words = @($words | Select-Object -SkipLast 1)
+	}
+	# Older PowerShells drop empty arguments to programs, so a blank word stands for an empty one.
+	$word = if ($wordToComplete) { $wordToComplete } else { ' ' }
+	poke __complete @words $word 2>$null | ForEach-Object {
+		[System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+	}
+}
+`,
+}
+
+// printCompletionScript prints the completion script for shell.
+func printCompletionScript(shell string) error {
+	script, ok := completionScripts[shell]
+	if !ok {
+		return withClass(usageError, xerrors.Errorf("poke can complete commands in bash, zsh, fish, and powershell, not %q", shell))
+	}
+	fmt.Print(script)
+	return nil
+}
+
+// completeFlag completes word, which follows words on the command line, if it's a flag, or the value of one.
+// It does so before the command line is parsed, since parsing fails on a flag that's missing its value.
+func completeFlag(words []string, word string) (completions []string, ok bool) {
+	if strings.HasPrefix(word, "-") {
+		pflag.VisitAll(func(flag *pflag.Flag) {
+			completions = append(completions, "--"+flag.Name)
+		})
+		return completions, true
+	}
+	if len(words) == 0 {
+		return nil, false
+	}
+	var flag *pflag.Flag
+	switch last := words[len(words)-1]; {
+	case strings.HasPrefix(last, "--") && !strings.Contains(last, "="):
+		flag = pflag.Lookup(last[2:])
+	case len(last) == 2 && last[0] == '-':
+		flag = pflag.ShorthandLookup(last[1:])
+	}
+	// Boolean flags don't take a value.
+	if flag == nil || flag.NoOptDefVal != "" {
+		return nil, false
+	}
+	switch flag.Name {
+	case "network":
+		return networkNames(), true
+	case "from":
+		return []string{"hardware", walletConnectFrom, "mnemonic", "keystore:", "awskms:", "gcpkms:"}, true
+	case "output":
+		return []string{"text", "json"}, true
+	}
+	// Many flags take files, which the shell completes when poke offers nothing.
+	return nil, true
+}
+
+// completeAlias completes word if it's an alias from the address book, like %treasury.
+func completeAlias(word string) (completions []string, ok bool) {
+	if !strings.HasPrefix(word, "%") {
+		return nil, false
+	}
+	for alias := range loadAddressBook() {
+		completions = append(completions, "%"+alias)
+	}
+	return completions, true
+}
+
+// completeCommand completes word, which follows args, as the name of one of root's commands,
+// or of one of the subcommands of a command like tx.
+func completeCommand(root *cobra.Command, args []string) []string {
+	cmd, rest, err := root.Find(args)
+	if err != nil || len(rest) > 0 || (cmd != root && !cmd.HasSubCommands()) {
+		return nil
+	}
+	var completions []string
+	for _, sub := range cmd.Commands() {
+		if sub.IsAvailableCommand() {
+			completions = append(completions, sub.Name())
+		}
+	}
+	return completions
+}
+
+// printCompletions prints those of completions that start with word, one per line, sorted.
+func printCompletions(completions []string, word string) {
+	sort.Strings(completions)
+	for _, completion := range completions {
+		if strings.HasPrefix(completion, word) {
+			fmt.Println(completion)
+		}
+	}
+}
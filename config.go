@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+// readConfig reads flag values from a config file: the one given by --config, or else
+// .poke.yaml (or .json, .toml, ...) in the working directory, if there is one.
+// Its keys are flag names, like node and gasprice. Flags and environment variables take precedence.
+// The network selected by --network can also have its own settings in the file; see applyNetworkProfile.
+func readConfig() error {
+	if file := viper.GetString("config"); file != "" {
+		viper.SetConfigFile(file)
+		if err := viper.ReadInConfig(); err != nil {
+			return xerrors.Errorf("reading config file %v: %w", file, err)
+		}
+		return applyNetworkProfile()
+	}
+
+	viper.SetConfigName(".poke")
+	viper.AddConfigPath(".")
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			return applyNetworkProfile()
+		}
+		return xerrors.Errorf("reading config file: %w", err)
+	}
+	return applyNetworkProfile()
+}
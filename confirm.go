@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/viper"
+)
+
+// needsConfirmation reports whether the user should confirm a transaction before it's sent:
+// always with --confirm, and by default on the chains in --protected-chains, mainnet unless it's changed.
+// --yes skips confirmation entirely.
+func needsConfirmation() bool {
+	if viper.GetBool("yes") {
+		return false
+	}
+	return viper.GetBool("confirm") || isProtectedChain(getChainID())
+}
+
+// isProtectedChain reports whether chainID is one of --protected-chains, which are chain ids or network preset names.
+func isProtectedChain(chainID *big.Int) bool {
+	for _, chain := range viper.GetStringSlice("protected-chains") {
+		chain = strings.TrimSpace(chain)
+		if preset, ok := networks[chain]; ok {
+			if preset.ChainID == chainID.Int64() {
+				return true
+			}
+			continue
+		}
+		id, ok := new(big.Int).SetString(chain, 10)
+		if !ok {
+			failf(usageError, "--protected-chains takes chain ids and network names, not %q\n", chain)
+		}
+		if id.Cmp(chainID) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// spentValue and spentGas total the ETH sent, and the most the gas could cost, of the transactions sent so far,
+// for --max-value and --max-cost to limit.
+var (
+	spentValue = new(big.Int)
+	spentGas   = new(big.Int)
+)
+
+// checkSpendLimits exits if sending tx would bring the ETH sent by this run over --max-value,
+// or what its gas could cost over --max-cost. Otherwise it counts tx towards them.
+func checkSpendLimits(tx *types.Transaction) {
+	value := new(big.Int).Add(spentValue, tx.Value())
+	gas := new(big.Int).Add(spentGas, new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), tx.GasFeeCap()))
+	for _, limit := range []struct {
+		flag, what string
+		total      *big.Int
+	}{
+		{"max-value", "the ETH this run sends", value},
+		{"max-cost", "the most this run's gas could cost", gas},
+	} {
+		if viper.GetString(limit.flag) == "" {
+			continue
+		}
+		// Like convert's amounts, limits needn't have underscores, and may be in scientific notation, as YAML writes small numbers.
+		max, err := decimal.NewFromString(strings.ReplaceAll(viper.GetString(limit.flag), "_", ""))
+		check(err, "parsing --"+limit.flag)
+		if limit.total.Cmp(truncateDecimal(max.Shift(18))) > 0 {
+			failf(refusedError, "Sending this transaction would bring %v to %v ETH, over --%v %v ETH. Not sending it.\n",
+				limit.what, decimal.NewFromBigInt(limit.total, -18), limit.flag, viper.GetString(limit.flag))
+		}
+	}
+	spentValue, spentGas = value, gas
+}
+
+// approve exits unless tx, from from, is within the spend limits and, where it needs to be, confirmed by the user.
+func (t transactor) approve(from common.Address, tx *types.Transaction) {
+	checkSpendLimits(tx)
+	if needsConfirmation() {
+		t.confirmTransaction(from, tx)
+	}
+}
+
+// confirmTransaction prints a preview of tx and asks the user whether to send it.
+// It exits unless the user answers yes.
+func (t transactor) confirmTransaction(from common.Address, tx *types.Transaction) {
+	if scriptFromStdin {
+		fail(refusedError, "poke can't ask whether to send a transaction while it reads the script from stdin. Pass --yes to send without asking.")
+	}
+	chainID := getChainID()
+	chain := chainID.String()
+	for name, preset := range networks {
+		if preset.ChainID == chainID.Int64() {
+			chain += " (" + name + ")"
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "About to send this transaction:")
+	fmt.Fprintf(os.Stderr, "  Chain:     %v\n", chain)
+	fmt.Fprintf(os.Stderr, "  From:      %v\n", from.Hex())
+	if tx.To() == nil {
+		fmt.Fprintln(os.Stderr, "  To:        (new contract)")
+	} else {
+		fmt.Fprintf(os.Stderr, "  To:        %v\n", describeAddress(*tx.To()))
+	}
+	if lines := t.describeCall(tx); len(lines) > 0 {
+		for _, line := range lines {
+			fmt.Fprintln(os.Stderr, "  "+line)
+		}
+	} else if len(tx.Data()) >= 4 && tx.To() != nil {
+		// Without an ABI for it, at least show what's being called.
+		fmt.Fprintf(os.Stderr, "  Calldata:  %v (%v bytes)\n", hexutil.Encode(tx.Data()[:4]), len(tx.Data()))
+	} else if len(tx.Data()) > 0 && tx.To() != nil {
+		// Too short to have a selector, so show all of it.
+		fmt.Fprintf(os.Stderr, "  Calldata:  %v\n", hexutil.Encode(tx.Data()))
+	}
+	fmt.Fprintf(os.Stderr, "  Value:     %v ETH\n", decimal.NewFromBigInt(tx.Value(), -18))
+	fmt.Fprintf(os.Stderr, "  Gas limit: %v\n", tx.Gas())
+	gasPrice := tx.GasPrice()
+	if tx.Type() == types.DynamicFeeTxType {
+		fmt.Fprintf(os.Stderr, "  Max fee:   %v gwei, including a %v gwei tip\n",
+			decimal.NewFromBigInt(tx.GasFeeCap(), -9), decimal.NewFromBigInt(tx.GasTipCap(), -9))
+		// What the gas will cost depends on the base fee of the block the transaction is mined in; the latest block's is the best guess.
+		if header, err := t.HeaderByNumber(context.Background(), nil); err == nil && header.BaseFee != nil {
+			gasPrice = new(big.Int).Add(header.BaseFee, tx.GasTipCap())
+			if gasPrice.Cmp(tx.GasFeeCap()) > 0 {
+				gasPrice = tx.GasFeeCap()
+			}
+		} else {
+			gasPrice = tx.GasFeeCap()
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "  Gas price: %v gwei\n", decimal.NewFromBigInt(tx.GasPrice(), -9))
+	}
+	estimate := new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), gasPrice)
+	estimate.Add(estimate, tx.Value())
+	cost := new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), tx.GasFeeCap())
+	cost.Add(cost, tx.Value())
+	fmt.Fprintf(os.Stderr, "  Est. cost: %v ETH, with gas at %v gwei\n", decimal.NewFromBigInt(estimate, -18), decimal.NewFromBigInt(gasPrice, -9))
+	fmt.Fprintf(os.Stderr, "  Max cost:  %v ETH\n", decimal.NewFromBigInt(cost, -18))
+	fmt.Fprint(os.Stderr, "Send it? [y/N] ")
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+	default:
+		fail(refusedError, "Transaction cancelled.")
+	}
+}
+
+// describeAddress labels address with its alias in the address book, if it has one, and with whether it has no code,
+// so that a mistyped address stands out.
+func describeAddress(address common.Address) string {
+	var labels []string
+	if alias := addressBookAlias(address); alias != "" {
+		labels = append(labels, "%"+alias)
+	}
+	if code, err := getNode().PendingCodeAt(context.Background(), address); err == nil && len(code) == 0 {
+		labels = append(labels, "not a contract")
+	}
+	if len(labels) == 0 {
+		return address.Hex()
+	}
+	return fmt.Sprintf("%v (%v)", address.Hex(), strings.Join(labels, ", "))
+}
+
+// describeCall decodes the method and arguments of tx using the transactor's ABI,
+// returning one line for the method and one for each argument.
+// It returns nothing if there's no ABI or the calldata doesn't match it.
+func (t transactor) describeCall(tx *types.Transaction) []string {
+	if t.abi == nil {
+		return nil
+	}
+	data := tx.Data()
+	var lines []string
+	if tx.To() == nil {
+		if len(data) < len(t.bytecode) {
+			return nil
+		}
+		data = data[len(t.bytecode):]
+		lines = append(lines, fmt.Sprintf("Method:    constructor(%v)", describeArgs(t.abi.Constructor.Inputs)))
+		values, err := t.abi.Constructor.Inputs.UnpackValues(data)
+		if err != nil {
+			return nil
+		}
+		for i, input := range t.abi.Constructor.Inputs {
+			lines = append(lines, fmt.Sprintf("  %v: %v", input.Name, displayValue(input.Type, values[i])))
+		}
+		return lines
+	}
+	if len(data) < 4 {
+		return nil
+	}
+	method, err := t.abi.MethodById(data[:4])
+	if err != nil {
+		return nil
+	}
+	values, err := method.Inputs.UnpackValues(data[4:])
+	if err != nil {
+		return nil
+	}
+	lines = append(lines, fmt.Sprintf("Method:    %v(%v)", method.Name, describeArgs(method.Inputs)))
+	for i, input := range method.Inputs {
+		lines = append(lines, fmt.Sprintf("  %v: %v", input.Name, displayValue(input.Type, values[i])))
+	}
+	return lines
+}
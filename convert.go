@@ -0,0 +1,57 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// amountRegexp splits an amount like "1.5eth" into its number and its unit, which is optional.
+var amountRegexp = regexp.MustCompile(`(?i)^(.*?\d)\s*(wei|gwei|eth|ether|tokens?)?$`)
+
+// unitDecimals returns how many decimals unit is shifted by from the smallest unit, wei.
+// A token is 10^--decimals of the token's smallest unit.
+func unitDecimals(unit string) int32 {
+	switch unit = strings.ToLower(unit); unit {
+	case "", "wei":
+		return 0
+	case "token", "tokens":
+		if viper.GetString("decimals") == "" {
+			fatal("The token unit needs the token's decimals, from --decimals.")
+		}
+		return tokenDecimals()
+	}
+	decimals, ok := valueUnits[unit]
+	if !ok {
+		fatalf("unknown unit %q; expected wei, gwei, ether, or token\n", unit)
+	}
+	return decimals
+}
+
+var convertCmd = &cobra.Command{
+	Use:   "convert <amount>[unit] <unit>",
+	Short: "Convert an amount between wei, gwei, ether, and a token's units",
+	Long: "Converts an amount, in wei unless it ends in a unit, to another unit. The units are wei, gwei, ether (or eth), " +
+		"and token, which is 10^--decimals of a token's smallest unit. An amount without a unit is in the smallest unit, " +
+		"wei for ETH. Amounts may be in scientific notation, like 1.5e18.",
+	Example: "  poke convert 1.5eth wei\n" +
+		"  poke convert 2000000000 gwei\n" +
+		"  poke convert 2500000 token --decimals 6\n" +
+		"  poke convert 1.5token wei --decimals 6",
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		match := amountRegexp.FindStringSubmatch(strings.TrimSpace(args[0]))
+		if match == nil {
+			fatalf("%q isn't an amount, like 1.5eth or 2000000000\n", args[0])
+		}
+		// Unlike arguments, long amounts don't need underscores: they're usually pasted from elsewhere,
+		// and a misread amount here doesn't get sent anywhere.
+		amount, err := decimal.NewFromString(strings.ReplaceAll(match[1], "_", ""))
+		check(err, "parsing amount")
+		amount = amount.Shift(unitDecimals(match[2]))
+		printResult(amount.Shift(-unitDecimals(args[1])).String())
+	},
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+// decodeTxCmd explains a transaction that's already been sent, decoding its call and events with theABI.
+func decodeTxCmd(theABI abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "decode-tx <txhash>",
+		Short: "Explain a transaction: its call, status, gas, events, and why it reverted, if it did",
+		Long: "Fetches a transaction and its receipt, and prints who sent it, the function it called and its arguments, " +
+			"whether it succeeded, the gas it used and its price, and the events it emitted, all decoded with the contract's ABI " +
+			"and the --extra-abi files. If it reverted, poke replays it to find out why, on the state before its block. " +
+			"That doesn't include the transactions before it in the same block, so the reason might be off if one of them mattered.",
+		Example: "  poke Token.sol decode-tx 0x1234...",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			hash := common.HexToHash(args[0])
+			tx, isPending, err := getNode().TransactionByHash(ctx, hash)
+			check(err, "retrieving transaction "+hash.Hex())
+			var receipt *types.Receipt
+			var from common.Address
+			if isPending {
+				from, err = types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+			} else {
+				receipt, err = getNode().TransactionReceipt(ctx, hash)
+				check(err, "retrieving receipt of "+hash.Hex())
+				from, err = getNode().TransactionSender(ctx, tx, receipt.BlockHash, receipt.TransactionIndex)
+			}
+			check(err, "recovering the sender of "+hash.Hex())
+
+			var method *abi.Method
+			var values []interface{}
+			var decodeErr error
+			if tx.To() != nil && len(tx.Data()) > 0 {
+				var m abi.Method
+				m, values, decodeErr = decodeCalldata(theABI, tx.Data())
+				if decodeErr == nil {
+					method = &m
+				}
+			}
+			var reason string
+			if receipt != nil && receipt.Status != types.ReceiptStatusSuccessful {
+				reason = "unknown; replaying it didn't revert"
+				if explained := xerrors.Unwrap(replayRevert(theABI, tx, receipt)); explained != nil {
+					reason = explained.Error()
+				}
+			}
+
+			if jsonOutput() {
+				printJSON(decodedTxJSON(theABI, tx, receipt, from, method, values, reason))
+				return
+			}
+			fmt.Printf("Hash:      %v\n", hash.Hex())
+			fmt.Printf("From:      %v\n", from.Hex())
+			switch {
+			case tx.To() != nil:
+				fmt.Printf("To:        %v\n", tx.To().Hex())
+			case receipt != nil:
+				fmt.Printf("To:        (new contract at %v)\n", receipt.ContractAddress.Hex())
+			default:
+				fmt.Println("To:        (new contract)")
+			}
+			fmt.Printf("Value:     %v ETH\n", decimal.NewFromBigInt(tx.Value(), -18))
+			switch {
+			case method != nil:
+				fmt.Printf("Function:  %v\n", method.Sig)
+				for i, input := range method.Inputs {
+					name := input.Name
+					if name == "" {
+						name = fmt.Sprint(i)
+					}
+					fmt.Printf("  %v: %v\n", name, displayValue(input.Type, values[i]))
+				}
+			case decodeErr != nil:
+				fmt.Printf("Input:     %v\n  (%v)\n", hexutil.Encode(tx.Data()), decodeErr)
+			}
+			if receipt == nil {
+				fmt.Println("Status:    pending")
+				return
+			}
+			fmt.Printf("Block:     %v\n", receipt.BlockNumber)
+			if receipt.Status == types.ReceiptStatusSuccessful {
+				fmt.Println("Status:    success")
+			} else {
+				fmt.Println("Status:    reverted")
+				fmt.Printf("Reason:    %v\n", reason)
+			}
+			fmt.Printf("Gas Used:  %v of %v\n", receipt.GasUsed, tx.Gas())
+			fmt.Printf("Gas Price: %v gwei\n", decimal.NewFromBigInt(effectiveGasPrice(tx, receipt), -9))
+			if len(receipt.Logs) > 0 {
+				fmt.Println("Events:")
+				for _, log := range receipt.Logs {
+					printEvent(theABI, *log)
+				}
+			}
+		},
+	}
+}
+
+// effectiveGasPrice returns what tx, mined with receipt, paid per unit of gas: since London,
+// the block's base fee plus the tip, which the fee cap limits.
+func effectiveGasPrice(tx *types.Transaction, receipt *types.Receipt) *big.Int {
+	header, err := getNode().HeaderByHash(context.Background(), receipt.BlockHash)
+	check(err, "retrieving block "+receipt.BlockHash.Hex())
+	if header.BaseFee == nil {
+		return tx.GasPrice()
+	}
+	tip, err := tx.EffectiveGasTip(header.BaseFee)
+	check(err, "computing the gas price")
+	return tip.Add(tip, header.BaseFee)
+}
+
+// decodedTxJSON describes a transaction as decode-tx prints it with --output json.
+// receipt and method are nil for a pending transaction, and calldata poke couldn't decode.
+func decodedTxJSON(theABI abi.ABI, tx *types.Transaction, receipt *types.Receipt, from common.Address,
+	method *abi.Method, values []interface{}, reason string) map[string]interface{} {
+	result := map[string]interface{}{
+		"hash":  tx.Hash().Hex(),
+		"from":  from.Hex(),
+		"value": tx.Value().String(),
+		"input": hexutil.Encode(tx.Data()),
+	}
+	if tx.To() != nil {
+		result["to"] = tx.To().Hex()
+	}
+	if method != nil {
+		arguments := make(map[string]string)
+		for i, input := range method.Inputs {
+			name := input.Name
+			if name == "" {
+				name = fmt.Sprint(i)
+			}
+			arguments[name] = displayValue(input.Type, values[i])
+		}
+		result["function"] = method.Sig
+		result["arguments"] = arguments
+	}
+	if receipt == nil {
+		result["status"] = "pending"
+		return result
+	}
+	result["block"] = receipt.BlockNumber.Uint64()
+	result["status"] = "success"
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		result["status"] = "reverted"
+		result["reason"] = reason
+	}
+	if tx.To() == nil {
+		result["contractAddress"] = receipt.ContractAddress.Hex()
+	}
+	result["gasUsed"] = receipt.GasUsed
+	result["effectiveGasPrice"] = effectiveGasPrice(tx, receipt).String()
+	events := []map[string]interface{}{}
+	for _, log := range receipt.Logs {
+		events = append(events, logJSON(theABI, *log))
+	}
+	result["events"] = events
+	return result
+}
+
+// logJSON decodes log like printEvent does, into the form eventJSON gives events.
+// Events poke can't decode keep their raw topics and data.
+func logJSON(theABI abi.ABI, log types.Log) map[string]interface{} {
+	if len(log.Topics) > 0 {
+		if _, err := theABI.EventByID(log.Topics[0]); err == nil {
+			return eventJSON(theABI, log)
+		}
+		if eventABI, _, ok := matchOtherEvent(log); ok {
+			event := eventJSON(eventABI, log)
+			event["address"] = log.Address.Hex()
+			return event
+		}
+	}
+	topics := make([]string, len(log.Topics))
+	for i, topic := range log.Topics {
+		topics[i] = topic.Hex()
+	}
+	return map[string]interface{}{
+		"address": log.Address.Hex(),
+		"topics":  topics,
+		"data":    hexutil.Encode(log.Data),
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/viper"
+)
+
+// deploymentRecord describes one deployment of a contract, as kept in the deployments file.
+type deploymentRecord struct {
+	Address         string `json:"address"`
+	TransactionHash string `json:"transactionHash"`
+	ConstructorArgs string `json:"constructorArgs"`
+	ChainID         int64  `json:"chainId"`
+	// InitCalls counts the initialization calls of a deployment plan that have been made on the contract.
+	InitCalls int `json:"initCalls,omitempty"`
+}
+
+// deploymentRegistry maps each network to the latest deployment of each contract on it, by contract name.
+type deploymentRegistry map[string]map[string]deploymentRecord
+
+// registryContract is the name of the contract poke is wrapping, to look up its deployments.
+var registryContract string
+
+// registryNetwork returns the key of the current network in the deployments file:
+// the --network name if there is one, and otherwise the chain id.
+func registryNetwork() string {
+	if name, _, ok := getNetwork(); ok {
+		return name
+	}
+	return getChainID().String()
+}
+
+// readDeployments reads the deployments file named by --deployments.
+// A missing file is an empty registry.
+func readDeployments() deploymentRegistry {
+	registry := make(deploymentRegistry)
+	file := viper.GetString("deployments")
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return registry
+	}
+	check(err, "reading "+file)
+	check(json.Unmarshal(data, &registry), "parsing "+file)
+	return registry
+}
+
+// recordDeployment adds a deployment of the current contract to the deployments file,
+// replacing any earlier deployment of it on the same network. It does nothing if --deployments is empty.
+func recordDeployment(record deploymentRecord) {
+	file := viper.GetString("deployments")
+	if file == "" {
+		return
+	}
+	registry := readDeployments()
+	network := registryNetwork()
+	if registry[network] == nil {
+		registry[network] = make(map[string]deploymentRecord)
+	}
+	registry[network][registryContract] = record
+	data, err := json.MarshalIndent(registry, "", "  ")
+	check(err, "encoding deployments")
+	check(ioutil.WriteFile(file, append(data, '\n'), 0644), "writing "+file)
+}
+
+// lookupDeployment returns the address of the current contract's latest deployment
+// on the current network, according to the deployments file.
+func lookupDeployment() (common.Address, bool) {
+	record, ok := lookupDeploymentRecord()
+	if !ok {
+		return common.Address{}, false
+	}
+	return hexToAddress(record.Address), true
+}
+
+// lookupDeploymentRecord returns the deployments file's record of the current contract's latest deployment
+// on the current network.
+func lookupDeploymentRecord() (deploymentRecord, bool) {
+	if viper.GetString("deployments") == "" {
+		return deploymentRecord{}, false
+	}
+	record, ok := readDeployments()[registryNetwork()][registryContract]
+	return record, ok
+}
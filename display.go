@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// displayValue formats v, a value of the ABI type t as decoded by go-ethereum,
+// the same way poke displays values of that type elsewhere.
+func displayValue(t abi.Type, v interface{}) string {
+	if i, ok := v.(*big.Int); ok {
+		if t.Size == 256 {
+			return displayAmount(i)
+		}
+		return displayBigInt(i)
+	}
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch t.T {
+	case abi.AddressTy:
+		return rv.Interface().(common.Address).Hex()
+	case abi.BytesTy, abi.FixedBytesTy, abi.FunctionTy:
+		b := make([]byte, rv.Len())
+		reflect.Copy(reflect.ValueOf(b), rv)
+		return hexutil.Encode(b)
+	case abi.SliceTy, abi.ArrayTy:
+		parts := make([]string, rv.Len())
+		for i := range parts {
+			parts[i] = displayValue(*t.Elem, rv.Index(i).Interface())
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case abi.TupleTy:
+		parts := make([]string, len(t.TupleElems))
+		for i, elem := range t.TupleElems {
+			parts[i] = t.TupleRawNames[i] + ": " + displayValue(*elem, rv.Field(i).Interface())
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	}
+	return fmt.Sprint(rv.Interface())
+}
+
+// printOutputs prints the decoded results of a call to a method with the given outputs.
+// A single output is printed on its own; several are printed one per line, labelled
+// with their names, or their positions if they're unnamed. No outputs print nothing.
+// With --output json, several outputs are an object, keyed the same way.
+func printOutputs(outputs abi.Arguments, results []interface{}) {
+	if len(outputs) == 1 {
+		printResult(displayValue(outputs[0].Type, results[0]))
+		return
+	}
+	if jsonOutput() {
+		values := make(map[string]string)
+		for i, output := range outputs {
+			name := output.Name
+			if name == "" {
+				name = fmt.Sprint(i)
+			}
+			values[name] = displayValue(output.Type, results[i])
+		}
+		printJSON(map[string]interface{}{"result": values})
+		return
+	}
+	for i, output := range outputs {
+		name := output.Name
+		if name == "" {
+			name = fmt.Sprint(i)
+		}
+		fmt.Printf("%v: %v\n", name, displayValue(output.Type, results[i]))
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+)
+
+// ensRegistry is the address of the ENS registry, which is the same on mainnet and its testnets.
+var ensRegistry = common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e")
+
+// ensABI declares the methods of the ENS registry and of resolvers that poke uses.
+const ensABI = `[
+	{"constant":true,"inputs":[{"name":"node","type":"bytes32"}],"name":"resolver","outputs":[{"name":"","type":"address"}],"type":"function"},
+	{"constant":true,"inputs":[{"name":"node","type":"bytes32"}],"name":"addr","outputs":[{"name":"","type":"address"}],"type":"function"},
+	{"constant":true,"inputs":[{"name":"node","type":"bytes32"}],"name":"name","outputs":[{"name":"","type":"string"}],"type":"function"}
+]`
+
+// ensAddresses caches resolved ENS names, since an address may be parsed several times.
+var ensAddresses = make(map[string]common.Address)
+
+// isENSName reports whether s looks like an ENS name, like vitalik.eth, rather than an address.
+func isENSName(s string) bool {
+	return strings.Contains(s, ".") && !strings.HasPrefix(s, "0x")
+}
+
+// namehash computes the ENS node of name, as defined by EIP-137.
+func namehash(name string) [32]byte {
+	var node [32]byte
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(strings.ToLower(name), ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		copy(node[:], crypto.Keccak256(node[:], crypto.Keccak256([]byte(labels[i]))))
+	}
+	return node
+}
+
+// ensResolver returns the resolver of node, bound to call its methods.
+// It exits if the name has no resolver.
+func ensResolver(node [32]byte, name string) *bind.BoundContract {
+	theABI, err := abi.JSON(strings.NewReader(ensABI))
+	check(err, "parsing ENS ABI")
+	registry := bind.NewBoundContract(ensRegistry, theABI, getNode(), getNode(), getNode())
+	var resolver common.Address
+	check(registry.Call(nil, &[]interface{}{&resolver}, "resolver", node), "looking up the ENS resolver of "+name+
+		" (is ENS deployed on this chain?)")
+	if resolver == (common.Address{}) {
+		fatalf("The ENS name %v has no resolver\n", name)
+	}
+	return bind.NewBoundContract(resolver, theABI, getNode(), getNode(), getNode())
+}
+
+// resolveENS returns the address that the ENS name resolves to.
+func resolveENS(name string) common.Address {
+	if address, ok := ensAddresses[name]; ok {
+		return address
+	}
+	node := namehash(name)
+	var address common.Address
+	check(ensResolver(node, name).Call(nil, &[]interface{}{&address}, "addr", node), "resolving ENS name "+name)
+	if address == (common.Address{}) {
+		fatalf("The ENS name %v doesn't resolve to an address\n", name)
+	}
+	ensAddresses[name] = address
+	return address
+}
+
+// reverseENS returns the primary ENS name of address, as set in its reverse record.
+// Since anyone can claim any name in their reverse record, the name is only returned
+// if it resolves back to address.
+func reverseENS(address common.Address) (string, bool) {
+	reverseName := strings.ToLower(strings.TrimPrefix(address.Hex(), "0x")) + ".addr.reverse"
+	node := namehash(reverseName)
+	var name string
+	check(ensResolver(node, reverseName).Call(nil, &[]interface{}{&name}, "name", node), "looking up the ENS name of "+address.Hex())
+	if name == "" || resolveENS(name) != address {
+		return "", false
+	}
+	return name, true
+}
+
+var ensCmd = &cobra.Command{
+	Use:     "ens <name>",
+	Short:   "Resolve an ENS name to an address",
+	Example: "  poke ens vitalik.eth",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		printResult(resolveENS(args[0]).Hex())
+	},
+}
+
+var ensReverseCmd = &cobra.Command{
+	Use:     "ens-reverse <address>",
+	Short:   "Look up the primary ENS name of an address",
+	Example: "  poke ens-reverse 0xd8dA6BF26964aF9D7eEd9e03E53415D37aA96045",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		address := parseAddress(args[0])
+		name, ok := reverseENS(address)
+		if !ok {
+			fatalf("%v has no primary ENS name\n", address.Hex())
+		}
+		printResult(name)
+	},
+}
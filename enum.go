@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// enumInputs maps a method signature (or "constructor") to the members of
+// the enum type of each of its inputs. Inputs that aren't enums have nil members.
+// It is only populated when poke can see both the ABI's internalType info and the AST.
+var enumInputs map[string][][]string
+
+// parseEnumDefinitions walks a solc AST, in either the legacy or the compact
+// format, and returns the members of every enum it defines, keyed by the enum's
+// canonical name (e.g. "Token.Status").
+func parseEnumDefinitions(ast json.RawMessage, enums map[string][]string) error {
+	if len(ast) == 0 {
+		return nil
+	}
+	var root interface{}
+	if err := json.Unmarshal(ast, &root); err != nil {
+		return err
+	}
+	var visit func(node interface{})
+	visit = func(node interface{}) {
+		switch node := node.(type) {
+		case []interface{}:
+			for _, child := range node {
+				visit(child)
+			}
+		case map[string]interface{}:
+			if node["nodeType"] == "EnumDefinition" {
+				// Compact AST, produced by solc >= 0.8.10.
+				name, _ := node["canonicalName"].(string)
+				members, _ := node["members"].([]interface{})
+				for _, member := range members {
+					member, _ := member.(map[string]interface{})
+					memberName, _ := member["name"].(string)
+					enums[name] = append(enums[name], memberName)
+				}
+				return
+			}
+			if node["name"] == "EnumDefinition" {
+				// Legacy AST.
+				attributes, _ := node["attributes"].(map[string]interface{})
+				name, _ := attributes["canonicalName"].(string)
+				children, _ := node["children"].([]interface{})
+				for _, child := range children {
+					child, _ := child.(map[string]interface{})
+					attributes, _ := child["attributes"].(map[string]interface{})
+					memberName, _ := attributes["name"].(string)
+					enums[name] = append(enums[name], memberName)
+				}
+				return
+			}
+			for _, child := range node {
+				visit(child)
+			}
+		}
+	}
+	visit(root)
+	return nil
+}
+
+// parseEnumInputs matches the `internalType` of each input in abiJSON against enums,
+// and returns the result in the format of enumInputs.
+// Compilers older than solc 0.5.11 don't output internalType, in which case this finds nothing.
+func parseEnumInputs(abiJSON string, enums map[string][]string) (map[string][][]string, error) {
+	var entries []struct {
+		Type   string
+		Name   string
+		Inputs []struct {
+			Type         string
+			InternalType string
+		}
+	}
+	if err := json.Unmarshal([]byte(abiJSON), &entries); err != nil {
+		return nil, err
+	}
+	result := make(map[string][][]string)
+	for _, entry := range entries {
+		if entry.Type != "function" && entry.Type != "constructor" {
+			continue
+		}
+		var types []string
+		members := make([][]string, len(entry.Inputs))
+		found := false
+		for i, input := range entry.Inputs {
+			types = append(types, input.Type)
+			if strings.HasPrefix(input.InternalType, "enum ") {
+				members[i] = enums[strings.TrimPrefix(input.InternalType, "enum ")]
+				found = found || members[i] != nil
+			}
+		}
+		if !found {
+			continue
+		}
+		sig := "constructor"
+		if entry.Type == "function" {
+			sig = entry.Name + "(" + strings.Join(types, ",") + ")"
+		}
+		result[sig] = members
+	}
+	return result, nil
+}
+
+// parseEnum parses s as one of the given enum members, either by name
+// ("Active" or "Status.Active") or by its index.
+func parseEnum(members []string, s string) uint8 {
+	name := s[strings.LastIndex(s, ".")+1:]
+	for i, member := range members {
+		if member == name {
+			return uint8(i)
+		}
+	}
+	i, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || int(i) >= len(members) {
+		fatalf("%q is not a member of the enum. Expected one of: %v, or an index below %v.\n",
+			s, strings.Join(members, ", "), len(members))
+	}
+	return uint8(i)
+}
+
+// enumUsage describes the members an enum input accepts, for command help.
+func enumUsage(name string, members []string) string {
+	return fmt.Sprintf("%v: one of %v", name, strings.Join(members, ", "))
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// erc20ABI is the standard ERC-20 interface, with its optional metadata functions and EIP-2612's permit.
+const erc20ABI = `[
+	{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"totalSupply","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"allowance","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"approve","stateMutability":"nonpayable","inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"transferFrom","stateMutability":"nonpayable","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"DOMAIN_SEPARATOR","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"bytes32"}]},
+	{"type":"function","name":"nonces","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"permit","stateMutability":"nonpayable","inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"},{"name":"value","type":"uint256"},{"name":"deadline","type":"uint256"},{"name":"v","type":"uint8"},{"name":"r","type":"bytes32"},{"name":"s","type":"bytes32"}],"outputs":[]},
+	{"type":"event","name":"Transfer","anonymous":false,"inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]},
+	{"type":"event","name":"Approval","anonymous":false,"inputs":[{"name":"owner","type":"address","indexed":true},{"name":"spender","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+// permitTypeHash is the EIP-712 type hash of EIP-2612's Permit struct.
+var permitTypeHash = crypto.Keccak256Hash([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)"))
+
+// erc20Token reads a token's metadata once, to show and parse its amounts in whole tokens.
+type erc20Token struct {
+	abi      abi.ABI
+	decimals *int32
+	symbol   *string
+}
+
+// call calls the view function method of the token, and returns its single result.
+func (t *erc20Token) call(method string, args ...interface{}) (interface{}, error) {
+	var results []interface{}
+	err := getDeployment(t.abi).Call(callOpts(), &results, method, args...)
+	if err != nil {
+		return nil, explainRevert(t.abi, err)
+	}
+	return results[0], nil
+}
+
+// getDecimals returns the token's decimals: from --decimals if it's set, and otherwise from decimals().
+// With --raw-amounts, it's 0, so that amounts are in the token's smallest unit.
+func (t *erc20Token) getDecimals() int32 {
+	if viper.GetBool("raw-amounts") {
+		return 0
+	}
+	if t.decimals == nil {
+		decimals := tokenDecimals()
+		if viper.GetString("decimals") == "" {
+			result, err := t.call("decimals")
+			check(err, "calling decimals(); give the token's decimals with --decimals")
+			decimals = int32(result.(uint8))
+		}
+		t.decimals = &decimals
+	}
+	return *t.decimals
+}
+
+// getSymbol returns the token's symbol, or "" if it doesn't have one, which ERC-20 allows.
+func (t *erc20Token) getSymbol() string {
+	if t.symbol == nil {
+		symbol := ""
+		if result, err := t.call("symbol"); err == nil {
+			symbol = result.(string)
+		}
+		t.symbol = &symbol
+	}
+	return *t.symbol
+}
+
+// format shows amount, in the token's smallest unit, in whole tokens, with the token's symbol.
+func (t *erc20Token) format(amount *big.Int) string {
+	return strings.TrimSpace(decimal.NewFromBigInt(amount, -t.getDecimals()).String() + " " + t.getSymbol())
+}
+
+// parseAmount parses s as an amount of whole tokens, and returns it in the token's smallest unit.
+// max is the largest amount there is, for unlimited approvals.
+func (t *erc20Token) parseAmount(s string) *big.Int {
+	if s == "max" {
+		return math.MaxBig256
+	}
+	return parseScaledInteger(s, false, 256, t.getDecimals())
+}
+
+// erc20Cmds returns the commands for the bundled ERC-20 ABI, theABI: the calls and the transactions.
+// Amounts are in whole tokens, scaled by the token's decimals, unless --raw-amounts is set.
+func erc20Cmds(theABI abi.ABI) (calls []*cobra.Command, transactions []*cobra.Command) {
+	token := &erc20Token{abi: theABI}
+	calls = []*cobra.Command{
+		{
+			Use:     "balance <holder>",
+			Short:   "Show the token balance of an address, in whole tokens",
+			Example: "  poke erc20 0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48 balance @1",
+			Args:    cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				balance, err := token.call("balanceOf", parseAddress(args[0]))
+				check(err, "calling balanceOf")
+				printResult(token.format(balance.(*big.Int)))
+			},
+		},
+		{
+			Use:   "allowance <owner> <spender>",
+			Short: "Show how many of the owner's tokens the spender may transfer",
+			Args:  cobra.ExactArgs(2),
+			Run: func(cmd *cobra.Command, args []string) {
+				allowance, err := token.call("allowance", parseAddress(args[0]), parseAddress(args[1]))
+				check(err, "calling allowance")
+				if allowance.(*big.Int).Cmp(math.MaxBig256) == 0 {
+					printResult("unlimited")
+					return
+				}
+				printResult(token.format(allowance.(*big.Int)))
+			},
+		},
+		{
+			Use:   "metadata",
+			Short: "Show the token's name, symbol, decimals, and total supply",
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				// name and symbol are optional, so leave them out if the token doesn't have them.
+				name, err := token.call("name")
+				if err != nil {
+					name = ""
+				}
+				supply, err := token.call("totalSupply")
+				check(err, "calling totalSupply")
+				if jsonOutput() {
+					printJSON(map[string]interface{}{
+						"address":     getContractAddress().Hex(),
+						"name":        name,
+						"symbol":      token.getSymbol(),
+						"decimals":    token.getDecimals(),
+						"totalSupply": decimal.NewFromBigInt(supply.(*big.Int), -token.getDecimals()).String(),
+					})
+					return
+				}
+				fmt.Printf("Name:         %v\n", name)
+				fmt.Printf("Symbol:       %v\n", token.getSymbol())
+				fmt.Printf("Decimals:     %v\n", token.getDecimals())
+				fmt.Printf("Total Supply: %v\n", token.format(supply.(*big.Int)))
+			},
+		},
+		{
+			Use:   "permit <spender> <amount> <deadline>",
+			Short: "Sign an EIP-2612 permit letting the spender transfer an amount of whole tokens from the `from` account",
+			Long: "Signs an EIP-2612 permit with the `from` account, using the token's DOMAIN_SEPARATOR() and the account's nonces(), " +
+				"and prints its signature and the calldata of the permit() call that uses it, which anyone can send. " +
+				"The amount may be max, for unlimited; the deadline is a timestamp, which may be relative, like now+1h.",
+			Example: "  poke erc20 0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48 permit @1 100 now+1h",
+			Args:    cobra.ExactArgs(3),
+			Run: func(cmd *cobra.Command, args []string) {
+				owner, spender, amount := getAddress(), parseAddress(args[0]), token.parseAmount(args[1])
+				deadline := parseInteger(args[2], false, 256)
+				domainSeparator, err := token.call("DOMAIN_SEPARATOR")
+				check(err, "calling DOMAIN_SEPARATOR(); the token may not support EIP-2612")
+				nonce, err := token.call("nonces", owner)
+				check(err, "calling nonces(); the token may not support EIP-2612")
+
+				uint256, _ := abi.NewType("uint256", "", nil)
+				address, _ := abi.NewType("address", "", nil)
+				bytes32, _ := abi.NewType("bytes32", "", nil)
+				encoded, err := abi.Arguments{{Type: bytes32}, {Type: address}, {Type: address}, {Type: uint256}, {Type: uint256}, {Type: uint256}}.
+					Pack(permitTypeHash, owner, spender, amount, nonce, deadline)
+				check(err, "encoding permit")
+				sig := signTypedData(common.Hash(domainSeparator.([32]byte)), crypto.Keccak256Hash(encoded))
+
+				v, r, s := sig[64], common.BytesToHash(sig[:32]), common.BytesToHash(sig[32:64])
+				calldata, err := theABI.Pack("permit", owner, spender, amount, deadline, v, r, s)
+				check(err, "encoding permit()")
+				if jsonOutput() {
+					printJSON(map[string]interface{}{
+						"owner":     owner.Hex(),
+						"spender":   spender.Hex(),
+						"value":     amount.String(),
+						"nonce":     nonce.(*big.Int).String(),
+						"deadline":  deadline.String(),
+						"v":         v,
+						"r":         r.Hex(),
+						"s":         s.Hex(),
+						"signature": hexutil.Encode(sig),
+						"calldata":  hexutil.Encode(calldata),
+					})
+					return
+				}
+				fmt.Printf("Owner:     %v\n", owner.Hex())
+				fmt.Printf("Spender:   %v\n", spender.Hex())
+				fmt.Printf("Value:     %v\n", token.format(amount))
+				fmt.Printf("Nonce:     %v\n", nonce)
+				fmt.Printf("Deadline:  %v\n", deadline)
+				fmt.Printf("v:         %v\n", v)
+				fmt.Printf("r:         %v\n", r.Hex())
+				fmt.Printf("s:         %v\n", s.Hex())
+				fmt.Printf("Signature: %v\n", hexutil.Encode(sig))
+				fmt.Printf("Calldata:  %v\n", hexutil.Encode(calldata))
+			},
+		},
+	}
+	transactions = []*cobra.Command{
+		{
+			Use:     "transfer <to> <amount>",
+			Short:   "Transfer an amount of whole tokens from the `from` account",
+			Example: "  poke erc20 0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48 transfer @1 1.5",
+			Args:    cobra.ExactArgs(2),
+			Run: func(cmd *cobra.Command, args []string) {
+				to, amount := parseAddress(args[0]), token.parseAmount(args[1])
+				tx, err := getDeployment(theABI).Transact(getTxnOpts(), "transfer", to, amount)
+				log(fmt.Sprintf("transfer of %v", token.format(amount)), tx, theABI, err)
+			},
+		},
+		{
+			Use:   "approve <spender> <amount>",
+			Short: "Let the spender transfer an amount of whole tokens, or max for unlimited, from the `from` account",
+			Args:  cobra.ExactArgs(2),
+			Run: func(cmd *cobra.Command, args []string) {
+				spender, amount := parseAddress(args[0]), token.parseAmount(args[1])
+				tx, err := getDeployment(theABI).Transact(getTxnOpts(), "approve", spender, amount)
+				log(fmt.Sprintf("approval of %v", token.format(amount)), tx, theABI, err)
+			},
+		},
+	}
+	return calls, transactions
+}
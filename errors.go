@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+// errorClass is a kind of failure, which poke exits with the code of, so that scripts can tell
+// a transaction that reverted from a node they couldn't reach without reading the message.
+// With --output json, poke also prints the class's category, and the message, as JSON.
+type errorClass struct {
+	category string
+	code     int
+}
+
+var (
+	// generalError is any failure poke doesn't know more about.
+	generalError = errorClass{"error", 1}
+	// usageError is a bad argument, flag, or script line.
+	usageError = errorClass{"usage", 2}
+	// revertError is a call or transaction that reverted, or that simulating shows would.
+	revertError = errorClass{"revert", 3}
+	// rpcError is a node, or another service poke talks to, that couldn't be reached or returned an error.
+	rpcError = errorClass{"rpc", 4}
+	// compileError is a failure to compile the contract, or to read the compiler's output.
+	compileError = errorClass{"compile", 5}
+	// refusedError is a transaction that poke, or the user, wouldn't send or sign:
+	// one declined when confirming it, over a spend limit, or for the wrong chain.
+	refusedError = errorClass{"refused", 6}
+	// timeoutError is a wait that ran out, as for a transaction to be mined.
+	timeoutError = errorClass{"timeout", 7}
+)
+
+// classifiedError is an error known to be of class.
+type classifiedError struct {
+	class errorClass
+	err   error
+}
+
+func (e classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e classifiedError) Unwrap() error {
+	return e.err
+}
+
+// withClass marks err as of class, unless it's nil or already has a class.
+func withClass(class errorClass, err error) error {
+	var classified classifiedError
+	if err == nil || xerrors.As(err, &classified) {
+		return err
+	}
+	return classifiedError{class, err}
+}
+
+// defaultClass is the class of failures that aren't otherwise classified. See failingAs.
+var defaultClass = generalError
+
+// failingAs makes class the class of failures that aren't otherwise classified,
+// like those of parsing arguments, until the function it returns is called.
+func failingAs(class errorClass) (restore func()) {
+	previous := defaultClass
+	defaultClass = class
+	return func() {
+		defaultClass = previous
+	}
+}
+
+// classify returns the class of err: the one it's marked with, if any, or else
+// revertError or rpcError if that's what the node's error says, or else the default class.
+func classify(err error) errorClass {
+	var classified classifiedError
+	if xerrors.As(err, &classified) {
+		return classified.class
+	}
+	if strings.Contains(err.Error(), "execution reverted") {
+		return revertError
+	}
+	var rpcErr rpc.Error
+	var httpErr rpc.HTTPError
+	var netErr net.Error
+	if xerrors.As(err, &rpcErr) || xerrors.As(err, &httpErr) || xerrors.As(err, &netErr) {
+		return rpcError
+	}
+	return defaultClass
+}
+
+// classOf returns the class of the last error among a, as classify does, or the default class if there's none.
+func classOf(a []interface{}) errorClass {
+	class := defaultClass
+	for _, arg := range a {
+		if err, ok := arg.(error); ok {
+			class = classify(err)
+		}
+	}
+	return class
+}
+
+// fail prints a, as fatal does, and exits with class's code.
+// With --output json, it also prints the error, as JSON, to stdout, where the command's result would have gone.
+func fail(class errorClass, a ...interface{}) {
+	message := fmt.Sprintln(a...)
+	fmt.Fprint(os.Stderr, message)
+	// Not jsonOutput, which fails itself on an unknown --output format.
+	if viper.GetBool("json") || viper.GetString("output") == "json" {
+		printJSON(map[string]interface{}{
+			"error": map[string]interface{}{
+				"category": class.category,
+				"code":     class.code,
+				"message":  strings.TrimSpace(message),
+			},
+		})
+	}
+	exit(class.code)
+}
+
+// failf is fail with a format string, as fatalf is to fatal.
+func failf(class errorClass, format string, a ...interface{}) {
+	fail(class, strings.TrimSuffix(fmt.Sprintf(format, a...), "\n"))
+}
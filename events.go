@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// eventsCmd prints the contract's past events of one kind, filtered by block range and indexed arguments.
+func eventsCmd(theABI abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "events <EventName>",
+		Short: "Print past events, between --from-block and --to-block, that match each --topic",
+		Long: "Prints the contract's past events named EventName.\n" +
+			"--topic name=value keeps only the events whose indexed argument `name` is value. " +
+			"Repeat it to filter on several arguments, or to accept several values of the same one.",
+		Example: "  poke Token.sol events Transfer --from-block 17000000 --topic to=@1",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			event, ok := theABI.Events[args[0]]
+			if !ok {
+				fatalf("The contract has no event named %q\n", args[0])
+			}
+			query := ethereum.FilterQuery{
+				FromBlock: parseBlockNumber(viper.GetString("from-block")),
+				ToBlock:   parseBlockNumber(viper.GetString("to-block")),
+				Addresses: []common.Address{getContractAddress()},
+				Topics:    eventTopics(event, viper.GetStringSlice("topic")),
+			}
+			logs, err := getNode().FilterLogs(context.Background(), query)
+			check(err, "fetching events")
+
+			if jsonOutput() {
+				results := make([]map[string]interface{}, 0, len(logs))
+				for _, log := range logs {
+					results = append(results, eventJSON(theABI, log))
+				}
+				printJSON(results)
+				return
+			}
+			for _, log := range logs {
+				fmt.Printf("Block %v, transaction %v:\n", log.BlockNumber, log.TxHash.Hex())
+				printEvent(theABI, log)
+			}
+		},
+	}
+}
+
+// eventJSON decodes log, an event of the contract with theABI, into the form poke prints it in with --output json.
+func eventJSON(theABI abi.ABI, log types.Log) map[string]interface{} {
+	event, err := theABI.EventByID(log.Topics[0])
+	check(err, "decoding event")
+	values, err := eventValues(theABI, event.Name, log)
+	check(err, "decoding event")
+	fields := make(map[string]string)
+	for i, input := range event.Inputs {
+		fields[input.Name] = values[i]
+	}
+	return map[string]interface{}{
+		"event":       event.Name,
+		"block":       log.BlockNumber,
+		"transaction": log.TxHash.Hex(),
+		"logIndex":    log.Index,
+		"args":        fields,
+	}
+}
+
+// parseBlockNumber parses a block number for a log filter: a decimal number, or "latest" or "" for the latest block.
+func parseBlockNumber(s string) *big.Int {
+	if s == "" || s == "latest" {
+		return nil
+	}
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 {
+		fatalf("%q isn't a block number\n", s)
+	}
+	return n
+}
+
+// eventTopics returns the topics to filter logs of event by: its ID, and then the values given for
+// each of its indexed inputs in filters, as name=value. Values for the same input are alternatives.
+func eventTopics(event abi.Event, filters []string) [][]common.Hash {
+	var indexed abi.Arguments
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	topics := make([][]common.Hash, 1+len(indexed))
+	topics[0] = []common.Hash{event.ID}
+	for _, filter := range filters {
+		eq := strings.Index(filter, "=")
+		if eq == -1 {
+			fatalf("--topic %q should look like name=value\n", filter)
+		}
+		name, value := filter[:eq], filter[eq+1:]
+		position := -1
+		for i, input := range indexed {
+			if input.Name == name {
+				position = i
+			}
+		}
+		if position == -1 {
+			fatalf("%v has no indexed argument named %q, so events can't be filtered by it\n", event.Name, name)
+		}
+		topic, err := abi.MakeTopics([]interface{}{parseArg(indexed[position].Type, value)})
+		check(err, "encoding --topic "+filter)
+		topics[1+position] = append(topics[1+position], topic[0][0])
+	}
+	return topics
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/spf13/viper"
+)
+
+// feeHistoryBlocks is how many recent blocks estimatePriorityFee looks at.
+const feeHistoryBlocks = 10
+
+// useDynamicFees reports whether to send EIP-1559 dynamic-fee transactions, rather than legacy ones.
+// That's the default wherever the latest block has a base fee, unless --legacy or --gasprice is given.
+// Hardware wallets only sign legacy transactions.
+func useDynamicFees() bool {
+	dynamicFlags := isExplicitlySet("max-fee") || isExplicitlySet("priority-fee")
+	legacy := viper.GetBool("legacy") || isExplicitlySet("gasprice") || getFrom() == "hardware"
+	if legacy {
+		if dynamicFlags {
+			fatal("--max-fee and --priority-fee only apply to dynamic-fee transactions, but --legacy, --gasprice, or a hardware wallet forces a legacy one")
+		}
+		return false
+	}
+	if getBaseFee() == nil {
+		if dynamicFlags {
+			fatal("--max-fee and --priority-fee need a node with EIP-1559, but the latest block has no base fee")
+		}
+		return false
+	}
+	return true
+}
+
+// getBaseFee returns the base fee of the latest block, or nil if the chain doesn't have EIP-1559.
+func getBaseFee() *big.Int {
+	header, err := getNode().HeaderByNumber(context.Background(), nil)
+	check(err, "retrieving the latest block")
+	return header.BaseFee
+}
+
+// getFees returns the fee cap and the priority fee (tip) for dynamic-fee transactions.
+// They default to twice the current base fee plus the tip, and estimatePriorityFee.
+func getFees() (feeCap, tipCap *big.Int) {
+	if s := viper.GetString("priority-fee"); s != "" {
+		tipCap = parseGwei(s)
+	} else {
+		tipCap = estimatePriorityFee()
+	}
+	if s := viper.GetString("max-fee"); s != "" {
+		feeCap = parseGwei(s)
+	} else {
+		feeCap = new(big.Int).Add(new(big.Int).Mul(getBaseFee(), big.NewInt(2)), tipCap)
+	}
+	if feeCap.Cmp(tipCap) < 0 {
+		fatalf("the max fee (%v wei) is less than the priority fee (%v wei)\n", feeCap, tipCap)
+	}
+	return feeCap, tipCap
+}
+
+// estimatePriorityFee estimates a priority fee from the median of the tips paid in recent blocks,
+// according to eth_feeHistory. It falls back to the node's eth_maxPriorityFeePerGas suggestion.
+func estimatePriorityFee() *big.Int {
+	ctx := context.Background()
+	history, err := getNode().FeeHistory(ctx, feeHistoryBlocks, nil, []float64{50})
+	if err == nil {
+		var tips []*big.Int
+		for _, reward := range history.Reward {
+			if len(reward) > 0 {
+				tips = append(tips, reward[0])
+			}
+		}
+		if len(tips) > 0 {
+			sort.Slice(tips, func(i, j int) bool {
+				return tips[i].Cmp(tips[j]) < 0
+			})
+			return tips[len(tips)/2]
+		}
+	}
+	tip, err := getNode().SuggestGasTipCap(ctx)
+	check(err, "retrieving priority fee suggestion")
+	return tip
+}
+
+// parseGwei parses an amount in gwei, in the notation parseUint256 accepts, and returns it in wei.
+func parseGwei(s string) *big.Int {
+	return truncateDecimal(parseDecimal(s).Shift(9))
+}
+
+// newTransaction builds an unsigned transaction to `to`, or a contract creation if `to` is nil,
+// with fees according to useDynamicFees.
+func newTransaction(nonce uint64, to *common.Address, value *big.Int, gas uint64, data []byte) *types.Transaction {
+	if useDynamicFees() {
+		feeCap, tipCap := getFees()
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   getChainID(),
+			Nonce:     nonce,
+			GasTipCap: tipCap,
+			GasFeeCap: feeCap,
+			Gas:       gas,
+			To:        to,
+			Value:     value,
+			Data:      data,
+		})
+	}
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: getGasPrice(),
+		Gas:      gas,
+		To:       to,
+		Value:    value,
+		Data:     data,
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// findFoundryRoot returns the directory of the foundry.toml nearest to solFile, looking in its
+// directory and each parent. It returns "" if solFile isn't part of a Foundry project.
+func findFoundryRoot(solFile string) string {
+	dir, err := filepath.Abs(filepath.Dir(solFile))
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "foundry.toml")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// foundryRemappings returns the import remappings of the Foundry project at root, as solc
+// takes them on the command line: those in remappings.txt, and then those in the default profile
+// of foundry.toml. Targets are made absolute, since forge resolves them against the project root.
+func foundryRemappings(root string) []string {
+	var remappings []string
+	if file, err := os.Open(filepath.Join(root, "remappings.txt")); err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				remappings = append(remappings, line)
+			}
+		}
+		check(scanner.Err(), "reading remappings.txt")
+	}
+
+	v := viper.New()
+	v.SetConfigFile(filepath.Join(root, "foundry.toml"))
+	check(v.ReadInConfig(), "reading foundry.toml")
+	remappings = append(remappings, v.GetStringSlice("profile.default.remappings")...)
+
+	for i, remapping := range remappings {
+		eq := strings.Index(remapping, "=")
+		if eq == -1 {
+			fatalf("The remapping %q should look like prefix=target\n", remapping)
+		}
+		target := remapping[eq+1:]
+		if !filepath.IsAbs(target) {
+			// filepath.Join drops the trailing slash that solc matches prefixes with, so put it back.
+			abs := filepath.Join(root, target)
+			if strings.HasSuffix(target, "/") {
+				abs += "/"
+			}
+			remappings[i] = remapping[:eq+1] + abs
+		}
+	}
+	return remappings
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+)
+
+// gasUsage is the gas used by one mined transaction, for --report-gas.
+type gasUsage struct {
+	name string
+	gas  uint64
+}
+
+// gasReport lists the gas used by each transaction mined so far, in order.
+var gasReport []gasUsage
+
+// recordGas adds a mined transaction to the gas report, if there is one.
+// The report is printed when poke exits.
+func recordGas(name string, gas uint64) {
+	if !viper.GetBool("report-gas") {
+		return
+	}
+	if len(gasReport) == 0 {
+		atExit(printGasReport)
+	}
+	gasReport = append(gasReport, gasUsage{name, gas})
+}
+
+// printGasReport prints a table of the gas used by each transaction,
+// and appends it to the CSV file given by --gas-report-file.
+func printGasReport() {
+	out := humanOutput()
+	fmt.Fprintln(out, "Gas report:")
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	var total uint64
+	for _, usage := range gasReport {
+		fmt.Fprintf(w, "  %v\t%v\t\n", usage.name, usage.gas)
+		total += usage.gas
+	}
+	fmt.Fprintf(w, "  total\t%v\t\n", total)
+	w.Flush()
+
+	file := viper.GetString("gas-report-file")
+	if file == "" {
+		return
+	}
+	_, err := os.Stat(file)
+	isNew := os.IsNotExist(err)
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		// We're already exiting, so just report the problem.
+		fmt.Fprintf(os.Stderr, "opening gas report file: %v\n", err)
+		return
+	}
+	defer f.Close()
+	csvWriter := csv.NewWriter(f)
+	if isNew {
+		csvWriter.Write([]string{"transaction", "gasUsed"})
+	}
+	for _, usage := range gasReport {
+		csvWriter.Write([]string{usage.name, strconv.FormatUint(usage.gas, 10)})
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		fmt.Fprintf(os.Stderr, "writing gas report file: %v\n", err)
+	}
+}
@@ -3,49 +3,22 @@ module github.com/reserve-protocol/poke
 go 1.13
 
 require (
-	github.com/allegro/bigcache v1.2.0 // indirect
-	github.com/apilayer/freegeoip v3.5.0+incompatible // indirect
-	github.com/aristanetworks/goarista v0.0.0-20190522184417-004c259faaeb // indirect
-	github.com/btcsuite/btcd v0.0.0-20190523000118-16327141da8c // indirect
 	github.com/cespare/cp v1.1.1 // indirect
-	github.com/deckarep/golang-set v1.7.1 // indirect
-	github.com/docker/docker v1.13.1 // indirect
-	github.com/edsrzf/mmap-go v1.0.0 // indirect
-	github.com/elastic/gosigar v0.10.5 // indirect
-	github.com/ethereum/go-ethereum v1.8.22-0.20190527102806-a184ab7a6188
-	github.com/fjl/memsize v0.0.0-20190710130421-bcb5799ab5e5 // indirect
+	github.com/ethereum/go-ethereum v1.10.26
 	github.com/gballet/go-libpcsclite v0.0.0-20191108122812-4678299bea08 // indirect
-	github.com/google/go-cmp v0.3.1 // indirect
-	github.com/hashicorp/golang-lru v0.5.3 // indirect
-	github.com/howeyc/fsnotify v0.9.0 // indirect
-	github.com/huin/goupnp v1.0.0 // indirect
-	github.com/influxdata/influxdb v1.7.9 // indirect
-	github.com/jackpal/go-nat-pmp v1.0.2 // indirect
-	github.com/julienschmidt/httprouter v1.3.0 // indirect
-	github.com/karalabe/hid v0.0.0-20190507082517-9e0a1cda7275 // indirect
-	github.com/mattn/go-colorable v0.1.4 // indirect
-	github.com/mattn/go-isatty v0.0.11 // indirect
-	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826 // indirect
-	github.com/olekukonko/tablewriter v0.0.4 // indirect
-	github.com/oschwald/maxminddb-golang v1.5.0 // indirect
-	github.com/pborman/uuid v1.2.0 // indirect
+	github.com/gorilla/websocket v1.4.2
+	github.com/karalabe/usb v0.0.2
 	github.com/prometheus/tsdb v0.10.0 // indirect
 	github.com/reserve-protocol/trezor v0.0.0-20190523013055-07b8dfd25bd5
 	github.com/rjeczalik/notify v0.9.2 // indirect
-	github.com/rs/cors v1.6.0 // indirect
 	github.com/shopspring/decimal v0.0.0-20180709203117-cd690d0c9e24
+	github.com/spf13/cast v1.3.0
 	github.com/spf13/cobra v0.0.4
 	github.com/spf13/pflag v1.0.3
 	github.com/spf13/viper v1.3.2
 	github.com/status-im/keycard-go v0.0.0-20191119114148-6dd40a46baa0 // indirect
-	github.com/steakknife/bloomfilter v0.0.0-20180922174646-6819c0d2a570 // indirect
-	github.com/steakknife/hamming v0.0.0-20180906055917-c99c65617cd3 // indirect
-	github.com/syndtr/goleveldb v1.0.0 // indirect
-	github.com/tyler-smith/go-bip39 v1.0.2 // indirect
-	github.com/wsddn/go-ecdh v0.0.0-20161211032359-48726bab9208 // indirect
-	golang.org/x/net v0.0.0-20190522155817-f3200d17e092 // indirect
-	golang.org/x/xerrors v0.0.0-20190528162220-0421b64034aa
-	gopkg.in/natefinch/npipe.v2 v2.0.0-20160621034901-c1b8fa8bdcce // indirect
-	gopkg.in/olebedev/go-duktape.v3 v3.0.0-20190709231704-1e4459ed25ff // indirect
-	gopkg.in/urfave/cli.v1 v1.20.0 // indirect
+	github.com/tyler-smith/go-bip39 v1.0.2
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
+	golang.org/x/xerrors v0.0.0-20220517211312-f3a8303e98df
+	gopkg.in/yaml.v2 v2.4.0
 )
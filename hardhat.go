@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// hardhatBuildInfo is the part of a Hardhat build-info file (artifacts/build-info/<hash>.json) that poke uses.
+// Its output is solc's standard-json output for the whole compilation.
+type hardhatBuildInfo struct {
+	Format string `json:"_format"`
+	Output json.RawMessage
+}
+
+// isBuildInfo reports whether compiled is a Hardhat build-info file.
+func isBuildInfo(compiled []byte) bool {
+	var info hardhatBuildInfo
+	if json.Unmarshal(compiled, &info) != nil {
+		return false
+	}
+	return strings.HasPrefix(info.Format, "hh-sol-build-info")
+}
+
+// buildInfoOutput returns the solc standard-json output held in a Hardhat build-info file.
+func buildInfoOutput(compiled []byte) ([]byte, error) {
+	var info hardhatBuildInfo
+	if err := json.Unmarshal(compiled, &info); err != nil {
+		return nil, xerrors.Errorf("failed to decode build-info: %w", err)
+	}
+	return info.Output, nil
+}
+
+// readArtifactBuildInfo reads the build-info file that the Hardhat artifact in artifactFile was compiled in,
+// as found through the debug file Hardhat writes next to the artifact, X.dbg.json.
+// It returns nil if there's no debug file, as for Foundry artifacts.
+func readArtifactBuildInfo(artifactFile string) (*combinedJSON, error) {
+	dbgFile := strings.TrimSuffix(artifactFile, ".json") + ".dbg.json"
+	dbgJSON, err := ioutil.ReadFile(dbgFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, xerrors.Errorf("reading %v: %w", dbgFile, err)
+	}
+	var dbg struct {
+		BuildInfo string
+	}
+	if err := json.Unmarshal(dbgJSON, &dbg); err != nil {
+		return nil, xerrors.Errorf("failed to decode %v: %w", dbgFile, err)
+	}
+	if dbg.BuildInfo == "" {
+		return nil, nil
+	}
+
+	// The build-info path is relative to the debug file.
+	compiled, err := ioutil.ReadFile(filepath.Join(filepath.Dir(dbgFile), dbg.BuildInfo))
+	if err != nil {
+		return nil, xerrors.Errorf("reading build-info: %w", err)
+	}
+	output, err := buildInfoOutput(compiled)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := parseStandardJSON(output)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
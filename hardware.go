@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/spf13/cobra"
+)
+
+// derivationSchemes are the shorthands --derivation-path accepts for the paths
+// wallets use for their accounts, each taking the account number.
+var derivationSchemes = map[string]func(n int) string{
+	// Ledger Live, MetaMask and most other software wallets put each account on its own hardened branch.
+	"ledger-live": func(n int) string {
+		return fmt.Sprintf("m/44'/60'/%v'/0/0", n)
+	},
+	// The Ledger Chrome app and MyEtherWallet counted accounts in the last, unhardened element.
+	"legacy": func(n int) string {
+		return fmt.Sprintf("m/44'/60'/0'/%v", n)
+	},
+}
+
+// expandDerivationScheme expands a shorthand like ledger-live:3 into the derivation path it stands for.
+// Other paths are returned as they are.
+func expandDerivationScheme(path string) string {
+	parts := strings.SplitN(path, ":", 2)
+	scheme, ok := derivationSchemes[parts[0]]
+	if !ok || len(parts) != 2 {
+		return path
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil || n < 0 {
+		fatalf("got invalid derivation-path: %q. The account number after %q must be a whole number.\n", path, parts[0]+":")
+	}
+	return scheme(n)
+}
+
+var hwAccountsCmd = &cobra.Command{
+	Use:   "hw-accounts [n]",
+	Short: "List the first n addresses of the connected hardware wallet, on both Ledger Live and legacy paths",
+	Long: "Lists the addresses of the first n accounts (default 5) of the connected hardware wallet, with their balances, " +
+		"under both the Ledger Live scheme (m/44'/60'/x'/0/0) and the legacy one (m/44'/60'/0'/x).\n" +
+		"Use one with --from hardware by passing its path, or its shorthand like ledger-live:1, as --derivation-path.",
+	Example: "  poke hw-accounts\n  poke hw-accounts 10",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		n := 5
+		if len(args) == 1 {
+			var err error
+			n, err = strconv.Atoi(args[0])
+			check(err, "parsing the number of accounts")
+		}
+		wallet := openHardwareDevice()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "Shorthand\tPath\tAddress\tBalance (wei)")
+		for _, scheme := range []string{"ledger-live", "legacy"} {
+			for i := 0; i < n; i++ {
+				path := derivationSchemes[scheme](i)
+				parsed, err := accounts.ParseDerivationPath(path)
+				check(err, "parsing derivation path "+path)
+				account, err := wallet.Derive(parsed, false)
+				check(err, "deriving account "+path)
+				balance, err := getNode().BalanceAt(context.Background(), account.Address, nil)
+				check(err, "retrieving wei balance")
+				fmt.Fprintf(w, "%v:%v\t%v\t%v\t%v\n", scheme, i, path, account.Address.Hex(), balance)
+			}
+		}
+		w.Flush()
+	},
+}
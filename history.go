@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// historySamples is about how many blocks history samples without --step.
+const historySamples = 100
+
+// deploymentBlock returns the block the contract at --address was deployed in, according to the deployments file,
+// or nil if its deployment isn't there.
+func deploymentBlock() *big.Int {
+	record, ok := lookupDeploymentRecord()
+	if !ok || hexToAddress(record.Address) != getContractAddress() {
+		return nil
+	}
+	receipt, err := getNode().TransactionReceipt(context.Background(), common.HexToHash(record.TransactionHash))
+	if err != nil {
+		return nil
+	}
+	return receipt.BlockNumber
+}
+
+// historyCmd evaluates a view function at blocks sampled from a range, to chart how its value changed.
+func historyCmd(theABI abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "history <method> [arg...]",
+		Short: "Print a view function's value at every --step blocks from --from-block to --to-block, as CSV",
+		Long: "Calls a view function at every --step-th block between --from-block and --to-block, and prints " +
+			"the block, its time, and the value, as CSV, or as JSON with --output json.\n" +
+			"--from-block defaults to the block the contract was deployed in, if it's in the deployments file, " +
+			"and --step to one that makes about 100 samples.\n" +
+			"Reading old state needs an archive node.",
+		Example: "  poke Token.sol history totalSupply --from-block 17000000 --to-block 17100000 --step 1000 > supply.csv",
+		Args:    cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			method, err := lookupViewMethod(theABI, args[0])
+			check(err, "history")
+			if len(args)-1 != len(method.Inputs) {
+				fatalf("%v takes %v arguments, but got %v\n", method.Sig, len(method.Inputs), len(args)-1)
+			}
+			inputs := parseArgs(method.Sig, method.Inputs, args[1:])
+			step := viper.GetInt64("step")
+			if step < 0 {
+				fatal("--step must be positive.")
+			}
+			ctx := context.Background()
+			// --from-block's default, the genesis block, is long before most contracts existed,
+			// and would take a call for every block since.
+			var from *big.Int
+			if isExplicitlySet("from-block") {
+				from = parseBlockNumber(viper.GetString("from-block"))
+			} else if from = deploymentBlock(); from == nil {
+				failf(usageError, "history needs --from-block, the first block to sample, since the contract's deployment isn't in the deployments file\n")
+			}
+			to := parseBlockNumber(viper.GetString("to-block"))
+			if from == nil || to == nil {
+				header, err := getNode().HeaderByNumber(ctx, nil)
+				check(err, "looking up the latest block")
+				if from == nil {
+					from = header.Number
+				}
+				if to == nil {
+					to = header.Number
+				}
+			}
+
+			if step == 0 {
+				step = new(big.Int).Div(new(big.Int).Sub(to, from), big.NewInt(historySamples)).Int64()
+				if step < 1 {
+					step = 1
+				}
+			}
+
+			names := make([]string, len(method.Outputs))
+			for i, output := range method.Outputs {
+				names[i] = output.Name
+				if names[i] == "" {
+					names[i] = fmt.Sprint(i)
+				}
+			}
+			var rows []map[string]interface{}
+			csvOut := csv.NewWriter(os.Stdout)
+			if !jsonOutput() {
+				check(csvOut.Write(append([]string{"block", "timestamp"}, names...)), "writing CSV")
+			}
+			for block := new(big.Int).Set(from); block.Cmp(to) <= 0; block.Add(block, big.NewInt(step)) {
+				header, err := getNode().HeaderByNumber(ctx, block)
+				check(err, fmt.Sprintf("looking up block %v", block))
+				var results []interface{}
+				err = getDeployment(theABI).Call(&bind.CallOpts{BlockNumber: block}, &results, method.Name, inputs...)
+				if err == bind.ErrNoCode {
+					fatalf("The contract has no code at block %v. Was it deployed later?\n", block)
+				}
+				check(explainRevert(theABI, err), fmt.Sprintf("calling %v at block %v", method.RawName, block))
+
+				timestamp := time.Unix(int64(header.Time), 0).UTC().Format(time.RFC3339)
+				values := make([]string, len(method.Outputs))
+				for i, output := range method.Outputs {
+					values[i] = displayValue(output.Type, results[i])
+				}
+				if jsonOutput() {
+					fields := make(map[string]string)
+					for i, name := range names {
+						fields[name] = values[i]
+					}
+					rows = append(rows, map[string]interface{}{
+						"block":     block.Uint64(),
+						"timestamp": timestamp,
+						"result":    fields,
+					})
+					continue
+				}
+				check(csvOut.Write(append([]string{block.String(), timestamp}, values...)), "writing CSV")
+				csvOut.Flush()
+			}
+			if jsonOutput() {
+				if rows == nil {
+					rows = []map[string]interface{}{}
+				}
+				printJSON(rows)
+			}
+		},
+	}
+}
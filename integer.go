@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/pflag"
+)
+
+var (
+	// intTypeRegexp matches the names of Solidity's integer types, like uint8 and int256.
+	intTypeRegexp = regexp.MustCompile(`^(u?)int([0-9]+)$`)
+	// negativeNumberRegexp matches command-line arguments that are negative numbers, like -5 or -1.5e18.
+	negativeNumberRegexp = regexp.MustCompile(`^-[0-9.]`)
+)
+
+// negativeNumberEscape marks an escaped negative number. It can't appear in a real argument.
+const negativeNumberEscape = "\x00"
+
+// escapeNegativeNumbers escapes the negative numbers in args, so that flag parsing
+// doesn't mistake them for shorthand flags. unescapeNegativeNumber reverses it.
+func escapeNegativeNumbers(args []string) []string {
+	escaped := make([]string, len(args))
+	for i, arg := range args {
+		escaped[i] = arg
+		if negativeNumberRegexp.MatchString(arg) {
+			escaped[i] = negativeNumberEscape + arg
+		}
+	}
+	return escaped
+}
+
+// unescapeNegativeNumber reverses escapeNegativeNumbers for a single argument.
+func unescapeNegativeNumber(arg string) string {
+	return strings.TrimPrefix(arg, negativeNumberEscape)
+}
+
+// protectNegativeNumbers rearranges args so that cobra doesn't parse negative numbers as flags.
+// It moves the flags in args, which are looked up in flags to know which take a value,
+// ahead of the other arguments, then puts "--" before the first negative number.
+func protectNegativeNumbers(args []string, flags *pflag.FlagSet) []string {
+	var flagArgs, otherArgs []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			otherArgs = append(otherArgs, args[i:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || negativeNumberRegexp.MatchString(arg) {
+			otherArgs = append(otherArgs, arg)
+			continue
+		}
+		flagArgs = append(flagArgs, arg)
+		var flag *pflag.Flag
+		if strings.HasPrefix(arg, "--") {
+			flag = flags.Lookup(strings.TrimPrefix(arg, "--"))
+		} else if len(arg) == 2 {
+			flag = flags.ShorthandLookup(arg[1:])
+		}
+		if flag != nil && flag.NoOptDefVal == "" && i+1 < len(args) {
+			// The flag's value is the next argument.
+			i++
+			flagArgs = append(flagArgs, args[i])
+		}
+	}
+	for i, arg := range otherArgs {
+		if arg == "--" {
+			break
+		}
+		if negativeNumberRegexp.MatchString(arg) {
+			// A "--" further on is now redundant, and would otherwise become an argument itself.
+			rest := []string{}
+			for j, later := range otherArgs[i:] {
+				if later == "--" {
+					rest = append(rest, otherArgs[i+j+1:]...)
+					break
+				}
+				rest = append(rest, later)
+			}
+			otherArgs = append(append(append([]string{}, otherArgs[:i]...), "--"), rest...)
+			break
+		}
+	}
+	return append(flagArgs, otherArgs...)
+}
+
+// lookupSolType finds how to parse and display values of the Solidity type named name.
+// Integer and byte array types of every width are handled generically, rather than listed in solTypes.
+func lookupSolType(name string) (solType, bool) {
+	if t, ok := solTypes[name]; ok {
+		return t, true
+	}
+	if t, ok := lookupBytesSolType(name); ok {
+		return t, true
+	}
+	match := intTypeRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return solType{}, false
+	}
+	bits, err := strconv.Atoi(match[2])
+	if err != nil || bits < 8 || bits > 256 || bits%8 != 0 {
+		return solType{}, false
+	}
+	return intSolType(match[1] == "", bits), true
+}
+
+// intSolType returns the solType of a signed or unsigned integer of the given width.
+// Like go-ethereum's ABI encoder, it uses Go's sized integers for widths of 8, 16, 32,
+// and 64 bits, and *big.Int for every other width.
+func intSolType(signed bool, bits int) solType {
+	name := fmt.Sprintf("int%v", bits)
+	if !signed {
+		name = "u" + name
+	}
+	return solType{
+		parser: func(s string) interface{} {
+			var i *big.Int
+			if bits == 256 {
+				i = parseAmount(s, signed)
+			} else {
+				i = parseInteger(s, signed, bits)
+			}
+			switch {
+			case bits == 8 && signed:
+				return int8(i.Int64())
+			case bits == 8:
+				return uint8(i.Uint64())
+			case bits == 16 && signed:
+				return int16(i.Int64())
+			case bits == 16:
+				return uint16(i.Uint64())
+			case bits == 32 && signed:
+				return int32(i.Int64())
+			case bits == 32:
+				return uint32(i.Uint64())
+			case bits == 64 && signed:
+				return i.Int64()
+			case bits == 64:
+				return i.Uint64()
+			}
+			return i
+		},
+		toString: func(i interface{}) string {
+			switch v := i.(type) {
+			case *int8:
+				return strconv.FormatInt(int64(*v), 10)
+			case *uint8:
+				return strconv.FormatUint(uint64(*v), 10)
+			case *int16:
+				return strconv.FormatInt(int64(*v), 10)
+			case *uint16:
+				return strconv.FormatUint(uint64(*v), 10)
+			case *int32:
+				return strconv.FormatInt(int64(*v), 10)
+			case *uint32:
+				return strconv.FormatUint(uint64(*v), 10)
+			case *int64:
+				return strconv.FormatInt(*v, 10)
+			case *uint64:
+				return strconv.FormatUint(*v, 10)
+			}
+			if bits == 256 {
+				return displayAmount(*(i.(**big.Int)))
+			}
+			return displayBigInt(*(i.(**big.Int)))
+		},
+		goType: func() interface{} {
+			switch {
+			case bits == 8 && signed:
+				return new(int8)
+			case bits == 8:
+				return new(uint8)
+			case bits == 16 && signed:
+				return new(int16)
+			case bits == 16:
+				return new(uint16)
+			case bits == 32 && signed:
+				return new(int32)
+			case bits == 32:
+				return new(uint32)
+			case bits == 64 && signed:
+				return new(int64)
+			case bits == 64:
+				return new(uint64)
+			}
+			return new(*big.Int)
+		},
+	}
+}
+
+// parseInteger parses s as an integer of the given signedness and width, in any
+// notation parseUint256 accepts or as a timestamp like now+1h, and exits if the value doesn't fit.
+// Only 256-bit values, which are usually token amounts, may have digits past
+// the decimal point, which are truncated; smaller integers must be whole.
+func parseInteger(s string, signed bool, bits int) *big.Int {
+	return parseScaledInteger(s, signed, bits, 0)
+}
+
+// parseScaledInteger parses s like parseInteger, but as a number of units with the given decimals,
+// like a token amount under --decimals. Timestamps aren't scaled.
+func parseScaledInteger(s string, signed bool, bits int, decimals int32) *big.Int {
+	defer failingAs(usageError)()
+	name := fmt.Sprintf("int%v", bits)
+	if !signed {
+		name = "u" + name
+	}
+
+	var i *big.Int
+	if isTimestamp(s) {
+		i = parseTimestamp(s)
+	} else {
+		negative := strings.HasPrefix(s, "-")
+		if negative && !signed {
+			fatalf("%v is an unsigned integer type, so it can't hold %q\n", name, s)
+		}
+		d := parseDecimal(strings.TrimPrefix(s, "-")).Shift(decimals)
+		i = truncateDecimal(d)
+		if bits < 256 && !d.Equal(decimal.NewFromBigInt(i, 0)) {
+			fatalf("%q isn't a whole number, so it can't be a %v\n", s, name)
+		}
+		// Truncating an amount with more decimals than the token has would quietly change it.
+		if decimals > 0 && !d.Equal(decimal.NewFromBigInt(i, 0)) {
+			fatalf("%q has more than the %v decimals the token has\n", s, decimals)
+		}
+		if negative {
+			i.Neg(i)
+		}
+	}
+
+	min, max := new(big.Int), new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	if signed {
+		max.Rsh(max, 1)
+		min.Neg(max)
+	}
+	max.Sub(max, big.NewInt(1))
+	if i.Cmp(min) < 0 || i.Cmp(max) > 0 {
+		fatalf("%q is out of range for %v, which holds %v to %v\n", s, name, min, max)
+	}
+	return i
+}
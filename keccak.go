@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+)
+
+var keccakCmd = &cobra.Command{
+	Use:   "keccak <data>",
+	Short: "Print the Keccak-256 hash of hex data, or of text",
+	Long:  "Hashes data with Keccak-256, as Solidity's keccak256 does. 0x-prefixed data is hex-decoded first; anything else is hashed as UTF-8 text.",
+	Example: "  poke keccak 'Transfer(address,address,uint256)'\n" +
+		"  poke keccak 0x1234",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		printResult(crypto.Keccak256Hash(parseMessage(args[0])).Hex())
+	},
+}
+
+// selectorCmd prints the 4-byte selectors of theABI's functions and errors, and of any function signature.
+func selectorCmd(theABI abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "selector <function-or-signature>",
+		Short: "Print the 4-byte selector of a function or custom error",
+		Long: "Prints the selector of a function or custom error of the contract, named by its name or signature, " +
+			"or of any signature, like \"transfer(address,uint256)\".",
+		Example: "  poke Token.sol selector transfer\n" +
+			"  poke Token.sol selector 'approve(address,uint256)'",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if method, err := lookupMethod(theABI, args[0]); err == nil {
+				printResult(hexutil.Encode(method.ID))
+				return
+			}
+			for name, customError := range theABI.Errors {
+				if name == args[0] || customError.Sig == args[0] {
+					printResult(hexutil.Encode(customError.ID[:4]))
+					return
+				}
+			}
+			if !strings.Contains(args[0], "(") {
+				fatalf("The contract has no function or error named %q\n", args[0])
+			}
+			// Parsing the signature puts it in canonical form, e.g. without spaces or argument names.
+			method, err := parseSignature(args[0])
+			check(err, "selector")
+			printResult(hexutil.Encode(method.ID))
+		},
+	}
+}
+
+// topicCmd prints the topics that identify theABI's events, and any event signature, in logs.
+func topicCmd(theABI abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "topic <event-or-signature>",
+		Short: "Print the topic that identifies an event in logs",
+		Long: "Prints the first topic of the logs of an event of the contract, named by its name or signature, " +
+			"or of any event signature, like \"Transfer(address,address,uint256)\": the hash of its signature.",
+		Example: "  poke Token.sol topic Transfer",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			for name, event := range theABI.Events {
+				if name == args[0] || event.Sig == args[0] {
+					printResult(event.ID.Hex())
+					return
+				}
+			}
+			if !strings.Contains(args[0], "(") {
+				fatalf("The contract has no event named %q\n", args[0])
+			}
+			method, err := parseSignature(args[0])
+			check(err, "topic")
+			printResult(crypto.Keccak256Hash([]byte(method.Sig)).Hex())
+		},
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/console/prompt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// keystorePrefix marks a --from value as the path of a geth-style encrypted JSON keystore file.
+const keystorePrefix = "keystore:"
+
+// keystoreKeys caches decrypted keystore keys by path, so the password is only asked for once.
+var keystoreKeys = make(map[string]*ecdsa.PrivateKey)
+
+// isKeystore reports whether from names a keystore file, as in keystore:/path/to/UTC--...json.
+func isKeystore(from string) bool {
+	return strings.HasPrefix(from, keystorePrefix)
+}
+
+// loadKeystore decrypts the key in the keystore file named by from, as in keystore:/path/to/UTC--...json.
+// The password comes from the POKE_KEYSTORE_PASSWORD environment variable, or failing that, a prompt.
+func loadKeystore(from string) *ecdsa.PrivateKey {
+	path := strings.TrimPrefix(from, keystorePrefix)
+	if key, ok := keystoreKeys[path]; ok {
+		return key
+	}
+	keyJSON, err := ioutil.ReadFile(path)
+	check(err, "reading keystore file")
+
+	password, ok := os.LookupEnv("POKE_KEYSTORE_PASSWORD")
+	if !ok {
+		password, err = prompt.Stdin.PromptPassword(fmt.Sprintf("Password for %v: ", path))
+		check(err, "reading keystore password")
+	}
+	key, err := keystore.DecryptKey(keyJSON, password)
+	check(err, "decrypting keystore file "+path)
+	keystoreKeys[path] = key.PrivateKey
+	return key.PrivateKey
+}
+
+// newKeystorePassword returns the password to encrypt a new keystore file with:
+// POKE_KEYSTORE_PASSWORD, or failing that, one typed twice at a prompt.
+func newKeystorePassword() string {
+	if password, ok := os.LookupEnv("POKE_KEYSTORE_PASSWORD"); ok {
+		return password
+	}
+	password, err := prompt.Stdin.PromptPassword("Password for the new keystore file: ")
+	check(err, "reading keystore password")
+	again, err := prompt.Stdin.PromptPassword("Repeat the password: ")
+	check(err, "reading keystore password")
+	if password != again {
+		fatal("The passwords don't match")
+	}
+	return password
+}
+
+// writeKeystore encrypts key into a new keystore file in dir, named like geth names them, and returns its path.
+func writeKeystore(dir string, key *ecdsa.PrivateKey) string {
+	password := newKeystorePassword()
+	ks := keystore.NewKeyStore(dir, keystore.StandardScryptN, keystore.StandardScryptP)
+	account, err := ks.ImportECDSA(key, password)
+	check(err, "writing keystore file")
+	return account.URL.Path
+}
+
+// printKey prints a key's address, and either the keystore file it was written to or, if it wasn't, the key itself.
+func printKey(key *ecdsa.PrivateKey, path string) {
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	if jsonOutput() {
+		out := map[string]string{"address": address}
+		if path != "" {
+			out["keystore"] = path
+		} else {
+			out["privateKey"] = keyToHex(key)
+		}
+		printJSON(out)
+		return
+	}
+	fmt.Printf("Address: %v\n", address)
+	if path != "" {
+		fmt.Printf("Keystore: %v\n", path)
+	} else {
+		fmt.Printf("Private key: %v\n", keyToHex(key))
+	}
+}
+
+var newKeyCmd = &cobra.Command{
+	Use:   "new-key",
+	Short: "Generate a new account, printing its address and private key, or writing the key to a keystore file",
+	Long: "Generates a new random private key. With --keystore, it's encrypted into a new keystore file in that directory, " +
+		"with a password from POKE_KEYSTORE_PASSWORD or a prompt, and only the address and file are printed. " +
+		"Sign with it with --from keystore:<file>.",
+	Example: "  poke new-key\n  poke new-key --keystore ~/.poke/keys",
+	Args:    cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		key, err := crypto.GenerateKey()
+		check(err, "generating key")
+		path := ""
+		if dir := viper.GetString("keystore"); dir != "" {
+			path = writeKeystore(dir, key)
+		}
+		printKey(key, path)
+	},
+}
+
+var importKeyCmd = &cobra.Command{
+	Use:   "import-key [hex-key]",
+	Short: "Encrypt a hex private key into a keystore file, in --keystore or the current directory",
+	Long: "Encrypts a hex-encoded private key into a new keystore file, with a password from POKE_KEYSTORE_PASSWORD or a prompt. " +
+		"Without the key as an argument, where it would end up in your shell history, it's read from a prompt.",
+	Example: "  poke import-key --keystore ~/.poke/keys\n  poke import-key 0x1234... --keystore ~/.poke/keys",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var hexKey string
+		if len(args) == 1 {
+			hexKey = args[0]
+		} else {
+			var err error
+			hexKey, err = prompt.Stdin.PromptPassword("Private key: ")
+			check(err, "reading private key")
+		}
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(hexKey), "0x"))
+		check(err, "parsing private key")
+		dir := viper.GetString("keystore")
+		if dir == "" {
+			dir = "."
+		}
+		printKey(key, writeKeystore(dir, key))
+	},
+}
+
+var exportKeyCmd = &cobra.Command{
+	Use:     "export-key <keystore-file>",
+	Short:   "Decrypt a keystore file and print its hex private key",
+	Long:    "Decrypts a keystore file, with its password from POKE_KEYSTORE_PASSWORD or a prompt, and prints its address and hex-encoded private key.",
+	Example: "  poke export-key ~/.poke/keys/UTC--2024-01-01T00-00-00.000000000Z--5409ed021d9299bf6814279a6a1411a7e866a631",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		printKey(loadKeystore(keystorePrefix+args[0]), "")
+	},
+}
@@ -0,0 +1,520 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/xerrors"
+)
+
+// awsKMSPrefix and gcpKMSPrefix mark a --from value as a secp256k1 key held by AWS KMS, named by its ARN, id, or alias,
+// or by Google Cloud KMS, named by its key version's resource name. The key never leaves the KMS, which signs digests with it.
+const (
+	awsKMSPrefix = "awskms:"
+	gcpKMSPrefix = "gcpkms:"
+)
+
+// kmsClient makes the requests to the KMS APIs and their token endpoints, giving up on ones that hang.
+var kmsClient = &http.Client{Timeout: 30 * time.Second}
+
+// isKMS reports whether from names a key in a cloud KMS, as in awskms:arn:aws:kms:... or gcpkms:projects/....
+func isKMS(from string) bool {
+	return strings.HasPrefix(from, awsKMSPrefix) || strings.HasPrefix(from, gcpKMSPrefix)
+}
+
+// kmsPublicKeys caches the public keys of KMS keys by --from value, so each is only fetched once.
+var kmsPublicKeys = make(map[string][]byte)
+
+// subjectPublicKeyInfo is the DER structure both KMSes return public keys in.
+// crypto/x509 can't parse it, since it doesn't know the secp256k1 curve.
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// kmsPublicKey returns the uncompressed public key of the KMS key from.
+func kmsPublicKey(from string) []byte {
+	if key, ok := kmsPublicKeys[from]; ok {
+		return key
+	}
+	var der []byte
+	var err error
+	if strings.HasPrefix(from, awsKMSPrefix) {
+		der, err = awsKMSPublicKey(strings.TrimPrefix(from, awsKMSPrefix))
+	} else {
+		der, err = gcpKMSPublicKey(strings.TrimPrefix(from, gcpKMSPrefix))
+	}
+	check(err, "getting the public key of "+from)
+	var info subjectPublicKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		fatalf("The KMS returned a public key poke can't parse: %v\n", err)
+	}
+	if _, err := ethcrypto.UnmarshalPubkey(info.PublicKey.Bytes); err != nil {
+		fatalf("%v isn't a secp256k1 key, which Ethereum needs: %v\n", from, err)
+	}
+	kmsPublicKeys[from] = info.PublicKey.Bytes
+	return info.PublicKey.Bytes
+}
+
+// kmsAddress returns the address of the KMS key from.
+func kmsAddress(from string) common.Address {
+	return common.BytesToAddress(ethcrypto.Keccak256(kmsPublicKey(from)[1:])[12:])
+}
+
+// kmsSign signs hash with the KMS key from. Like crypto.Sign, it returns a 65-byte signature, [R || S || V], with V being 0 or 1.
+func kmsSign(from string, hash []byte) ([]byte, error) {
+	var der []byte
+	var err error
+	if strings.HasPrefix(from, awsKMSPrefix) {
+		der, err = awsKMSSign(strings.TrimPrefix(from, awsKMSPrefix), hash)
+	} else {
+		der, err = gcpKMSSign(strings.TrimPrefix(from, gcpKMSPrefix), hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rs struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &rs); err != nil {
+		return nil, xerrors.Errorf("parsing the KMS's signature: %w", err)
+	}
+	// Ethereum only accepts the lower of the two S values that make a signature valid, which KMSes don't pick.
+	n := ethcrypto.S256().Params().N
+	if rs.S.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		rs.S.Sub(n, rs.S)
+	}
+	// KMSes don't say which of the two candidate public keys recovers, so try both.
+	sig := append(common.LeftPadBytes(rs.R.Bytes(), 32), common.LeftPadBytes(rs.S.Bytes(), 32)...)
+	for v := byte(0); v < 2; v++ {
+		recovered, err := ethcrypto.Ecrecover(hash, append(sig, v))
+		if err == nil && bytes.Equal(recovered, kmsPublicKey(from)) {
+			return append(sig, v), nil
+		}
+	}
+	return nil, xerrors.Errorf("the KMS's signature doesn't match its public key")
+}
+
+// kmsSignTx signs tx for the chain chainID with the KMS key from.
+func kmsSignTx(from string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signer := types.LatestSignerForChainID(chainID)
+	sig, err := kmsSign(from, signer.Hash(tx).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(signer, sig)
+}
+
+// kmsTransactOpts returns transaction options that sign with the KMS key from.
+func kmsTransactOpts(from string) *bind.TransactOpts {
+	address := kmsAddress(from)
+	return &bind.TransactOpts{
+		From: address,
+		Signer: func(signer common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if signer != address {
+				return nil, bind.ErrNotAuthorized
+			}
+			return kmsSignTx(from, tx, getChainID())
+		},
+	}
+}
+
+// awsKMSPublicKey returns the public key of the AWS KMS key keyID, in DER.
+func awsKMSPublicKey(keyID string) ([]byte, error) {
+	var response struct {
+		PublicKey []byte
+	}
+	err := awsKMSRequest(keyID, "GetPublicKey", map[string]interface{}{"KeyId": keyID}, &response)
+	return response.PublicKey, err
+}
+
+// awsKMSSign signs digest with the AWS KMS key keyID, and returns the signature in DER.
+func awsKMSSign(keyID string, digest []byte) ([]byte, error) {
+	var response struct {
+		Signature []byte
+	}
+	err := awsKMSRequest(keyID, "Sign", map[string]interface{}{
+		"KeyId":            keyID,
+		"Message":          digest,
+		"MessageType":      "DIGEST",
+		"SigningAlgorithm": "ECDSA_SHA_256",
+	}, &response)
+	return response.Signature, err
+}
+
+// awsKMSRequest calls the AWS KMS API action for keyID, with the credentials awsCredentialChain finds.
+// The region is the ARN's, or else AWS_REGION's, or the profile's, and AWS_ENDPOINT_URL_KMS overrides the endpoint.
+func awsKMSRequest(keyID, action string, body, result interface{}) error {
+	creds, err := awsCredentialChain()
+	if err != nil {
+		return err
+	}
+	region := awsRegion()
+	// arn:aws:kms:<region>:<account>:key/<id>
+	if parts := strings.Split(keyID, ":"); len(parts) >= 6 && parts[0] == "arn" {
+		region = parts[3]
+	}
+	if region == "" {
+		return xerrors.New("give the key's ARN, or set AWS_REGION, to use AWS KMS")
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_KMS")
+	if endpoint == "" {
+		endpoint = "https://kms." + region + ".amazonaws.com"
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return xerrors.Errorf("parsing AWS_ENDPOINT_URL_KMS: %w", err)
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	headers := map[string]string{
+		"content-type": "application/x-amz-json-1.1",
+		"host":         endpointURL.Host,
+		"x-amz-date":   now.Format("20060102T150405Z"),
+		"x-amz-target": "TrentService." + action,
+	}
+	if creds.SessionToken != "" {
+		headers["x-amz-security-token"] = creds.SessionToken
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Authorization", awsSignatureV4(headers, payload, creds.AccessKeyID, creds.SecretAccessKey, region, now))
+
+	resp, err := kmsClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return xerrors.Errorf("AWS KMS %v: HTTP %v: %v %v", action, resp.Status, failure.Type, failure.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// awsSignatureV4 returns the Authorization header that signs a POST to / of the KMS API, with headers and payload,
+// following AWS's Signature Version 4.
+func awsSignatureV4(headers map[string]string, payload []byte, accessKey, secretKey, region string, now time.Time) string {
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		"POST", "/", "", canonicalHeaders.String(), signedHeaders, hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	date := now.Format("20060102")
+	scope := date + "/" + region + "/kms/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", now.Format("20060102T150405Z"), scope, hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	hmacSHA256 := func(key []byte, data string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(data))
+		return mac.Sum(nil)
+	}
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, "kms", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v", accessKey, scope, signedHeaders, signature)
+}
+
+// gcpKMSEndpoint returns the URL of the Google Cloud KMS API, which CLOUDSDK_API_ENDPOINT_OVERRIDES_CLOUDKMS overrides, as for gcloud.
+func gcpKMSEndpoint() string {
+	if endpoint := os.Getenv("CLOUDSDK_API_ENDPOINT_OVERRIDES_CLOUDKMS"); endpoint != "" {
+		return strings.TrimSuffix(strings.TrimSuffix(endpoint, "/"), "/v1")
+	}
+	return "https://cloudkms.googleapis.com"
+}
+
+// gcpKMSPublicKey returns the public key of the Google Cloud KMS key version resource, in DER.
+func gcpKMSPublicKey(resource string) ([]byte, error) {
+	var response struct {
+		PEM string `json:"pem"`
+	}
+	if err := gcpKMSRequest("GET", gcpKMSEndpoint()+"/v1/"+resource+"/publicKey", nil, &response); err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode([]byte(response.PEM))
+	if block == nil {
+		return nil, xerrors.New("the public key isn't PEM-encoded")
+	}
+	return block.Bytes, nil
+}
+
+// gcpKMSSign signs digest with the Google Cloud KMS key version resource, and returns the signature in DER.
+func gcpKMSSign(resource string, digest []byte) ([]byte, error) {
+	var response struct {
+		Signature []byte `json:"signature"`
+	}
+	body := map[string]interface{}{"digest": map[string]interface{}{"sha256": digest}}
+	err := gcpKMSRequest("POST", gcpKMSEndpoint()+"/v1/"+resource+":asymmetricSign", body, &response)
+	return response.Signature, err
+}
+
+// gcpKMSRequest calls the Google Cloud KMS API at url, with body as JSON if it isn't nil.
+func gcpKMSRequest(method, url string, body, result interface{}) error {
+	token, err := gcpAccessToken()
+	if err != nil {
+		return xerrors.Errorf("getting a Google Cloud access token: %w", err)
+	}
+	var payload []byte
+	if body != nil {
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := kmsClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return xerrors.Errorf("Google Cloud KMS: HTTP %v: %v", resp.Status, failure.Error.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// gcpToken caches the Google Cloud access token.
+var gcpToken string
+
+// gcpAccessToken returns an OAuth access token for Google Cloud KMS, from where Google's client libraries find one:
+// GOOGLE_OAUTH_ACCESS_TOKEN, the credentials file GOOGLE_APPLICATION_CREDENTIALS names, the one
+// `gcloud auth application-default login` writes, or, failing those, the metadata server of the Google Cloud machine
+// poke is running on.
+func gcpAccessToken() (string, error) {
+	if gcpToken != "" {
+		return gcpToken, nil
+	}
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		gcpToken = token
+		return token, nil
+	}
+
+	var token string
+	var err error
+	if file := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); file != "" {
+		token, err = gcpFileToken(file)
+	} else if file := gcpWellKnownFile(); file != "" {
+		token, err = gcpFileToken(file)
+	} else {
+		token, err = gcpMetadataToken()
+	}
+	if err != nil {
+		return "", err
+	}
+	gcpToken = token
+	return gcpToken, nil
+}
+
+// gcpMetadataToken returns the access token of the service account of the Google Cloud machine poke is running on,
+// from its metadata server.
+func gcpMetadataToken() (string, error) {
+	req, err := http.NewRequest("GET", "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	body, err := metadataGet(req)
+	if err != nil {
+		return "", xerrors.Errorf("no GOOGLE_OAUTH_ACCESS_TOKEN, GOOGLE_APPLICATION_CREDENTIALS, or application default credentials, "+
+			"and no metadata server: %w", err)
+	}
+	return gcpTokenFromResponse([]byte(body))
+}
+
+// gcpWellKnownFile returns the credentials file `gcloud auth application-default login` writes, if it exists.
+func gcpWellKnownFile() string {
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" {
+		if runtime.GOOS == "windows" {
+			dir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config", "gcloud")
+		} else {
+			return ""
+		}
+	}
+	file := filepath.Join(dir, "application_default_credentials.json")
+	if _, err := os.Stat(file); err != nil {
+		return ""
+	}
+	return file
+}
+
+// gcpFileToken trades the credentials in file, a service account key or a user's refresh token, for an access token.
+func gcpFileToken(file string) (string, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	var credentials struct {
+		Type         string `json:"type"`
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(contents, &credentials); err != nil {
+		return "", xerrors.Errorf("reading Google Cloud credentials %v: %w", file, err)
+	}
+	switch credentials.Type {
+	case "service_account":
+		assertion, tokenURI, err := gcpServiceAccountAssertion(file)
+		if err != nil {
+			return "", err
+		}
+		return gcpTokenRequest(tokenURI, url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		})
+	case "authorized_user":
+		return gcpTokenRequest("https://oauth2.googleapis.com/token", url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {credentials.ClientID},
+			"client_secret": {credentials.ClientSecret},
+			"refresh_token": {credentials.RefreshToken},
+		})
+	default:
+		return "", xerrors.Errorf("Google Cloud credentials %v are of type %q; only service_account and authorized_user are supported",
+			file, credentials.Type)
+	}
+}
+
+// gcpTokenRequest posts form to the OAuth token endpoint tokenURI, and returns the access token it responds with.
+func gcpTokenRequest(tokenURI string, form url.Values) (string, error) {
+	resp, err := kmsClient.PostForm(tokenURI, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", xerrors.Errorf("HTTP %v from %v: %s", resp.Status, tokenURI, bytes.TrimSpace(body))
+	}
+	return gcpTokenFromResponse(body)
+}
+
+// gcpTokenFromResponse returns the access token in an OAuth token response.
+func gcpTokenFromResponse(body []byte) (string, error) {
+	var response struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", xerrors.Errorf("reading the access token: %w", err)
+	}
+	if response.AccessToken == "" {
+		return "", xerrors.New("the response has no access token")
+	}
+	return response.AccessToken, nil
+}
+
+// gcpServiceAccountAssertion returns a JWT, signed with the service account key in file, to trade for an access token
+// at the returned token URI.
+func gcpServiceAccountAssertion(file string) (string, string, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", "", err
+	}
+	var account struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(contents, &account); err != nil {
+		return "", "", xerrors.Errorf("reading service account key %v: %w", file, err)
+	}
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return "", "", xerrors.Errorf("service account key %v has no private key", file)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", "", xerrors.Errorf("parsing the private key in %v: %w", file, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", "", xerrors.Errorf("the private key in %v isn't an RSA key", file)
+	}
+
+	now := time.Now().Unix()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   account.ClientEmail,
+		"scope": "https://www.googleapis.com/auth/cloudkms",
+		"aud":   account.TokenURI,
+		"iat":   now,
+		"exp":   now + 3600,
+	})
+	encode := base64.RawURLEncoding.EncodeToString
+	unsigned := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", "", err
+	}
+	return unsigned + "." + encode(sig), account.TokenURI, nil
+}
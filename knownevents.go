@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/spf13/viper"
+)
+
+// commonEventABIs hold events that many contracts emit, to decode events from contracts other than
+// the one poke is calling, like the tokens it moves. They're separate ABIs because some share a
+// signature: ERC-20 and ERC-721 Transfer events differ only in which arguments are indexed.
+var commonEventABIs = []string{
+	// ERC-20
+	`[
+		{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]},
+		{"type":"event","name":"Approval","inputs":[{"name":"owner","type":"address","indexed":true},{"name":"spender","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}
+	]`,
+	// ERC-721
+	`[
+		{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"tokenId","type":"uint256","indexed":true}]},
+		{"type":"event","name":"Approval","inputs":[{"name":"owner","type":"address","indexed":true},{"name":"approved","type":"address","indexed":true},{"name":"tokenId","type":"uint256","indexed":true}]},
+		{"type":"event","name":"ApprovalForAll","inputs":[{"name":"owner","type":"address","indexed":true},{"name":"operator","type":"address","indexed":true},{"name":"approved","type":"bool","indexed":false}]}
+	]`,
+	// ERC-1155
+	`[
+		{"type":"event","name":"TransferSingle","inputs":[{"name":"operator","type":"address","indexed":true},{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"id","type":"uint256","indexed":false},{"name":"value","type":"uint256","indexed":false}]},
+		{"type":"event","name":"TransferBatch","inputs":[{"name":"operator","type":"address","indexed":true},{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"ids","type":"uint256[]","indexed":false},{"name":"values","type":"uint256[]","indexed":false}]}
+	]`,
+	// WETH
+	`[
+		{"type":"event","name":"Deposit","inputs":[{"name":"dst","type":"address","indexed":true},{"name":"wad","type":"uint256","indexed":false}]},
+		{"type":"event","name":"Withdrawal","inputs":[{"name":"src","type":"address","indexed":true},{"name":"wad","type":"uint256","indexed":false}]}
+	]`,
+	// OpenZeppelin's Ownable, AccessControl, Pausable, and proxies
+	`[
+		{"type":"event","name":"OwnershipTransferred","inputs":[{"name":"previousOwner","type":"address","indexed":true},{"name":"newOwner","type":"address","indexed":true}]},
+		{"type":"event","name":"RoleGranted","inputs":[{"name":"role","type":"bytes32","indexed":true},{"name":"account","type":"address","indexed":true},{"name":"sender","type":"address","indexed":true}]},
+		{"type":"event","name":"RoleRevoked","inputs":[{"name":"role","type":"bytes32","indexed":true},{"name":"account","type":"address","indexed":true},{"name":"sender","type":"address","indexed":true}]},
+		{"type":"event","name":"Paused","inputs":[{"name":"account","type":"address","indexed":false}]},
+		{"type":"event","name":"Unpaused","inputs":[{"name":"account","type":"address","indexed":false}]},
+		{"type":"event","name":"Upgraded","inputs":[{"name":"implementation","type":"address","indexed":true}]},
+		{"type":"event","name":"AdminChanged","inputs":[{"name":"previousAdmin","type":"address","indexed":false},{"name":"newAdmin","type":"address","indexed":false}]}
+	]`,
+	// Safe
+	`[
+		{"type":"event","name":"ExecutionSuccess","inputs":[{"name":"txHash","type":"bytes32","indexed":false},{"name":"payment","type":"uint256","indexed":false}]},
+		{"type":"event","name":"ExecutionFailure","inputs":[{"name":"txHash","type":"bytes32","indexed":false},{"name":"payment","type":"uint256","indexed":false}]}
+	]`,
+}
+
+// otherEventABIs is the ABIs to decode events from other contracts with: those in the --extra-abi files,
+// and then commonEventABIs. It's loaded by matchOtherEvent on first use.
+var otherEventABIs []abi.ABI
+
+// loadOtherEventABIs reads the --extra-abi files, which may hold bare ABIs or any compiler output with an
+// "abi" field, and parses commonEventABIs.
+func loadOtherEventABIs() []abi.ABI {
+	var abis []abi.ABI
+	for _, file := range viper.GetStringSlice("extra-abi") {
+		contents, err := ioutil.ReadFile(file)
+		check(err, "reading --extra-abi file")
+		var wrapped struct {
+			ABI json.RawMessage
+		}
+		if json.Unmarshal(contents, &wrapped) == nil && len(wrapped.ABI) > 0 {
+			contents = wrapped.ABI
+		}
+		parsed, err := abi.JSON(strings.NewReader(string(contents)))
+		check(err, "parsing the ABI in "+file)
+		abis = append(abis, parsed)
+	}
+	for _, common := range commonEventABIs {
+		parsed, err := abi.JSON(strings.NewReader(common))
+		check(err, "parsing common events")
+		abis = append(abis, parsed)
+	}
+	return abis
+}
+
+// matchesEvent reports whether log could be an instance of event: it has the event's ID,
+// and a topic for each of its indexed arguments.
+func matchesEvent(event abi.Event, log types.Log) bool {
+	if len(log.Topics) == 0 || log.Topics[0] != event.ID {
+		return false
+	}
+	indexed := 0
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed++
+		}
+	}
+	return len(log.Topics) == 1+indexed
+}
+
+// matchOtherEvent finds the event that log, which isn't from the contract poke is calling, is an instance of.
+// It returns the ABI it's in and its name, or ok false if it's in none of otherEventABIs.
+func matchOtherEvent(log types.Log) (eventABI abi.ABI, name string, ok bool) {
+	if otherEventABIs == nil {
+		otherEventABIs = loadOtherEventABIs()
+	}
+	for _, candidate := range otherEventABIs {
+		for name, event := range candidate.Events {
+			if matchesEvent(event, log) {
+				return candidate, name, true
+			}
+		}
+	}
+	return abi.ABI{}, "", false
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/karalabe/usb"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+// go-ethereum's Ledger driver can't ask the device to show an address, so hw-verify talks to
+// the Ledger directly, using the same USB framing and APDUs as go-ethereum's accounts/usbwallet.
+const (
+	ledgerVendorID  = 0x2c97
+	ledgerUsagePage = 0xffa0
+
+	ledgerOpRetrieveAddress   = 0x02
+	ledgerP1FetchAddress      = 0x00 // Return the address directly
+	ledgerP1ConfirmAddress    = 0x01 // Show the address on the device, and return it once the user approves
+	ledgerStatusOK            = 0x9000
+	ledgerStatusUserRejected  = 0x6985
+	ledgerStatusAppNotRunning = 0x6e00
+)
+
+// openLedger opens the first connected Ledger.
+func openLedger() usb.Device {
+	infos, err := usb.Enumerate(ledgerVendorID, 0)
+	check(err, "listing USB devices")
+	for _, info := range infos {
+		// Windows and macOS identify the Ledger's interface by usage page, Linux by interface number.
+		if info.UsagePage == ledgerUsagePage || info.Interface == 0 {
+			device, err := info.Open()
+			check(err, "opening Ledger")
+			return device
+		}
+	}
+	fatal("No Ledger found. hw-verify only supports Ledgers; is one plugged in and unlocked?")
+	return nil
+}
+
+// ledgerExchange sends an APDU with the given instruction, parameters, and data to the Ledger,
+// and returns the data of its reply.
+func ledgerExchange(device io.ReadWriter, ins, p1, p2 byte, data []byte) ([]byte, error) {
+	apdu := make([]byte, 2, 7+len(data))
+	binary.BigEndian.PutUint16(apdu, uint16(5+len(data)))
+	apdu = append(apdu, 0xe0, ins, p1, p2, byte(len(data)))
+	apdu = append(apdu, data...)
+
+	// Send the APDU in 64-byte chunks, each with a header of the channel, tag, and sequence number.
+	header := []byte{0x01, 0x01, 0x05, 0x00, 0x00}
+	chunk := make([]byte, 64)
+	space := len(chunk) - len(header)
+	for i := 0; len(apdu) > 0; i++ {
+		chunk = append(chunk[:0], header...)
+		binary.BigEndian.PutUint16(chunk[3:], uint16(i))
+		n := len(apdu)
+		if n > space {
+			n = space
+		}
+		chunk = append(chunk, apdu[:n]...)
+		apdu = apdu[n:]
+		if _, err := device.Write(chunk); err != nil {
+			return nil, err
+		}
+	}
+
+	// The reply comes the same way; its first chunk also has the reply's total length.
+	var reply []byte
+	chunk = chunk[:64]
+	for {
+		if _, err := io.ReadFull(device, chunk); err != nil {
+			return nil, err
+		}
+		if chunk[0] != 0x01 || chunk[1] != 0x01 || chunk[2] != 0x05 {
+			return nil, xerrors.New("invalid reply header from Ledger")
+		}
+		payload := chunk[5:]
+		if chunk[3] == 0x00 && chunk[4] == 0x00 {
+			reply = make([]byte, 0, int(binary.BigEndian.Uint16(chunk[5:7])))
+			payload = chunk[7:]
+		}
+		if left := cap(reply) - len(reply); left > len(payload) {
+			reply = append(reply, payload...)
+		} else {
+			reply = append(reply, payload[:left]...)
+			break
+		}
+	}
+	if len(reply) < 2 {
+		return nil, xerrors.New("reply from Ledger is too short")
+	}
+
+	switch status := binary.BigEndian.Uint16(reply[len(reply)-2:]); status {
+	case ledgerStatusOK:
+		return reply[:len(reply)-2], nil
+	case ledgerStatusUserRejected:
+		return nil, xerrors.New("rejected on the Ledger")
+	case ledgerStatusAppNotRunning:
+		return nil, xerrors.New("the Ethereum app isn't open on the Ledger")
+	default:
+		return nil, xerrors.Errorf("Ledger replied with status %#04x", status)
+	}
+}
+
+// ledgerAddress retrieves the address at path from the Ledger.
+// With confirm, the Ledger shows the address and waits for the user to approve it.
+func ledgerAddress(device io.ReadWriter, path accounts.DerivationPath, confirm bool) (common.Address, error) {
+	data := make([]byte, 1+4*len(path))
+	data[0] = byte(len(path))
+	for i, component := range path {
+		binary.BigEndian.PutUint32(data[1+4*i:], component)
+	}
+	p1 := byte(ledgerP1FetchAddress)
+	if confirm {
+		p1 = ledgerP1ConfirmAddress
+	}
+	reply, err := ledgerExchange(device, ledgerOpRetrieveAddress, p1, 0, data)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	// The reply is the public key and then the hex-encoded address, each preceded by its length.
+	if len(reply) < 1 || len(reply) < 1+int(reply[0]) {
+		return common.Address{}, xerrors.New("Ledger's reply lacks the public key")
+	}
+	reply = reply[1+int(reply[0]):]
+	if len(reply) < 1 || len(reply) < 1+int(reply[0]) {
+		return common.Address{}, xerrors.New("Ledger's reply lacks the address")
+	}
+	var address common.Address
+	if _, err := hex.Decode(address[:], reply[1:1+int(reply[0])]); err != nil {
+		return common.Address{}, xerrors.Errorf("decoding address from Ledger: %w", err)
+	}
+	return address, nil
+}
+
+var hwVerifyCmd = &cobra.Command{
+	Use:   "hw-verify",
+	Short: "Show the --derivation-path address on the Ledger's screen, to check it against poke's",
+	Long: "Derives the address at --derivation-path, then asks the Ledger to show that address on its screen.\n" +
+		"Check that the two match before sending real transactions with --from hardware, " +
+		"and approve the address on the Ledger. Trezors aren't supported.",
+	Example: "  poke hw-verify\n  poke hw-verify --derivation-path ledger-live:1",
+	Args:    cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := getDerivationPath("a hardware wallet")
+		device := openLedger()
+		defer device.Close()
+
+		computed, err := ledgerAddress(device, path, false)
+		check(err, "deriving address")
+		fmt.Printf("Address at %v: %v\n", path, computed.Hex())
+		fmt.Println("Check that the Ledger shows the same address, and approve it there.")
+
+		shown, err := ledgerAddress(device, path, true)
+		check(err, "showing address on the Ledger")
+		if shown != computed {
+			fatalf("The Ledger showed %v, not %v! Don't sign with it until you know why.\n", shown.Hex(), computed.Hex())
+		}
+		fmt.Println("The Ledger confirmed the address.")
+	},
+}
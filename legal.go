@@ -44,28 +44,65 @@ var dependencyLicenses = []licenseInfo{
 		bsd3,
 	},
 	{
-		"github.com/karalabe/hid",
+		"github.com/google/uuid",
+		"Copyright (c) 2009,2014 Google Inc. All rights reserved.",
+		bsd3,
+	},
+	{
+		"github.com/gorilla/websocket",
+		"Copyright (c) 2013 The Gorilla WebSocket Authors. All rights reserved.",
+		bsd2,
+	},
+	{
+		"github.com/karalabe/usb",
 		"Copyright (c) 2017 Péter Szilágyi. All rights reserved.",
-		hidLicense, // Depends on the OS.
+		lgplV3,
 	},
 	{
-		// Dependency of github.com/karalabe/hid
+		// Dependency of github.com/karalabe/usb
+		"github.com/libusb/libusb",
+		"Copyright © 2001 Johannes Erdfelt <johannes@erdfelt.com>\n" +
+			"Copyright © 2007-2009 Daniel Drake <dsd@gentoo.org>\n" +
+			"Copyright © 2010-2012 Peter Stuge <peter@stuge.se>\n" +
+			"Copyright © 2008-2016 Nathan Hjelm <hjelmn@users.sourceforge.net>\n" +
+			"Copyright © 2009-2013 Pete Batard <pete@akeo.ie>\n" +
+			"Copyright © 2009-2013 Ludovic Rousseau <ludovic.rousseau@gmail.com>\n" +
+			"Copyright © 2010-2012 Michael Plante <michael.plante@gmail.com>\n" +
+			"Copyright © 2011-2013 Hans de Goede <hdegoede@redhat.com>\n" +
+			"Copyright © 2012-2013 Martin Pieuchot <mpi@openbsd.org>\n" +
+			"Copyright © 2012-2013 Toby Gray <toby.gray@realvnc.com>\n" +
+			"Copyright © 2013-2018 Chris Dickens <christopher.a.dickens@gmail.com>",
+		lgplV3,
+	},
+	{
+		// Dependency of github.com/karalabe/usb
 		"github.com/signal11/hidapi",
 		"Copyright (c) 2010, Alan Ott, Signal 11 Software",
 		bsd3,
 	},
 	{
-		// Dependency of github.com/karalabe/hid
+		// Dependency of github.com/karalabe/usb
 		"github.com/GeertJohan/go.hid",
 		"Copyright (c) 2012, Geert-Johan Riemer",
 		bsd2,
 	},
 	{
-		// Dependency of github.com/karalabe/hid
+		// Dependency of github.com/karalabe/usb
 		"github.com/orofarne/gowchar",
 		"Copyright (c) 2013, Maxim Dementyev. All rights reserved.",
 		bsd3,
 	},
+	{
+		"github.com/peterh/liner",
+		"Copyright © 2012 Peter Harris",
+		mit,
+	},
+	{
+		// Dependency of github.com/peterh/liner
+		"github.com/mattn/go-runewidth",
+		"Copyright (c) 2016 Yasuhiro Matsumoto",
+		mit,
+	},
 	{
 		"github.com/pborman/uuid",
 		"Copyright (c) 2009,2014 Google Inc. All rights reserved.",
@@ -86,6 +123,11 @@ var dependencyLicenses = []licenseInfo{
 		"Copyright (c) 2014 Olivier Poitrey <rs@dailymotion.com>",
 		mit,
 	},
+	{
+		"github.com/shirou/gopsutil",
+		"Copyright (c) 2014, WAKAYAMA Shirou",
+		bsd3,
+	},
 	{
 		"github.com/shopspring/decimal",
 		"Copyright (c) 2015 Spring, Inc.",
@@ -118,6 +160,21 @@ var dependencyLicenses = []licenseInfo{
 		"Copyright 2012 Suryandaru Triandana <syndtr@gmail.com>  All rights reserved.",
 		bsd2,
 	},
+	{
+		"github.com/tklauser/go-sysconf",
+		"Copyright (c) 2018-2021, Tobias Klauser",
+		bsd3,
+	},
+	{
+		"github.com/tklauser/numcpus",
+		"",
+		apacheV2,
+	},
+	{
+		"github.com/tyler-smith/go-bip39",
+		"Copyright (c) 2014-2018 Tyler Smith and contributors",
+		mit,
+	},
 	{
 		"github.com/golang/net",
 		"Copyright (c) 2009 The Go Authors. All rights reserved.",
@@ -135,8 +192,6 @@ var dependencyLicenses = []licenseInfo{
 	},
 }
 
-var hidLicense = bsd3 // overridden in Linux builds to lgpl3
-
 type license [2]string
 
 var (
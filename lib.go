@@ -1,26 +1,37 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math/big"
+	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/shopspring/decimal"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
 
 	"github.com/reserve-protocol/trezor"
 )
@@ -55,93 +66,208 @@ func exit(code int) {
 	os.Exit(code)
 }
 
+// fatal prints a, and exits with the code of the class of the error among a, if there is one.
 func fatal(a ...interface{}) {
-	fmt.Fprintln(os.Stderr, a...)
-	exit(1)
+	fail(classOf(a), a...)
 }
 
 func fatalf(format string, a ...interface{}) {
-	fmt.Fprintf(os.Stderr, format, a...)
-	exit(1)
+	failf(classOf(a), format, a...)
 }
 
-var client *ethclient.Client
+var (
+	client    *ethclient.Client
+	rpcClient *rpc.Client
+)
 
 func getNode() *ethclient.Client {
 	if client == nil {
-		var err error
-		nodeAddr := viper.GetString("node")
-		client, err = ethclient.Dial(nodeAddr)
-		check(err, fmt.Sprintf("Failed to connect to Ethereum node (is there a node running at %q?)", nodeAddr))
+		client = ethclient.NewClient(getRPC())
 	}
 	return client
 }
 
-var (
-	singletonAccount accounts.Account
-	singletonWallet  accounts.Wallet
-)
-
-func openHardwareWallet() (accounts.Wallet, accounts.Account) {
-	if singletonWallet != nil {
-		return singletonWallet, singletonAccount
+// getRPC returns the raw RPC connection to the node, for the methods ethclient doesn't wrap.
+func getRPC() *rpc.Client {
+	if rpcClient == nil {
+		var err error
+		nodeAddr := getNodeURL()
+		if strings.HasPrefix(nodeAddr, "http://") || strings.HasPrefix(nodeAddr, "https://") {
+			rpcClient, err = rpc.DialHTTPWithClient(nodeAddr, &http.Client{
+				Transport: retryTransport{RoundTripper: http.DefaultTransport, retries: getRetries()},
+			})
+		} else {
+			rpcClient, err = rpc.Dial(nodeAddr)
+		}
+		check(err, fmt.Sprintf("Failed to connect to Ethereum node (is there a node running at %q?)", nodeAddr))
 	}
+	return rpcClient
+}
 
-	// Open hardware wallet.
-	{
-		// Check for connected Ledgers and Trezors.
-		ledgerHub, err := usbwallet.NewLedgerHub()
-		check(err, "calling usbwallet.NewLedgerHub()")
-		trezorHub, err := usbwallet.NewTrezorHub()
-		check(err, "calling usbwallet.NewTrezorHub()")
+// transactor is the backend poke sends transactions through.
+// It behaves like the node, except that it intercepts transactions
+// before broadcast when flags like --offline ask it to.
+type transactor struct {
+	*ethclient.Client
 
-		// Collect them into a single list.
-		wallets := accounts.NewManager(nil, ledgerHub, trezorHub).Wallets()
+	// abi, if set, is used to describe transactions to the contract.
+	// bytecode is the deployed bytecode, to find constructor arguments in deployments.
+	abi      *abi.ABI
+	bytecode []byte
+}
 
-		// Don't proceed unless there is exactly one hardware wallet available.
-		if len(wallets) == 0 {
-			fatal("No hardware wallets found. Is a hardware wallet plugged in? If it's a Ledger, is it unlocked?")
+// SendTransaction broadcasts tx to the node, unless simulating it shows it would revert.
+// With --dry-run, it instead prints the transaction's target, value, calldata, and estimated cost, and exits.
+// With --safe, it instead proposes the transaction to the Safe, and exits.
+// With --offline, it instead prints the RLP-encoded transaction as hex and exits.
+// Under `tx build`, it prints the unsigned transaction as a transaction file and exits.
+// With --from walletconnect, a wallet that can't sign transactions without sending them gets tx unsigned, and sends it.
+func (t transactor) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if viper.GetBool("dry-run") {
+		cost := new(big.Int).Mul(new(big.Int).SetUint64(tx.Gas()), tx.GasFeeCap())
+		if jsonOutput() {
+			to := ""
+			if tx.To() != nil {
+				to = tx.To().Hex()
+			}
+			printJSON(map[string]interface{}{
+				"to":            to,
+				"value":         tx.Value().String(),
+				"data":          hexutil.Encode(tx.Data()),
+				"gas":           tx.Gas(),
+				"estimatedCost": cost.String(),
+			})
+			exit(0)
 		}
-		if len(wallets) > 1 {
-			fatalf("%v hardware wallets found, I don't know which to use", len(wallets))
+		fmt.Println("Dry run: transaction not sent.")
+		if tx.To() == nil {
+			fmt.Println("To: (new contract)")
+		} else {
+			fmt.Printf("To: %v\n", tx.To().Hex())
 		}
+		fmt.Printf("Value: %v wei\n", tx.Value())
+		fmt.Printf("Data: %v\n", hexutil.Encode(tx.Data()))
+		fmt.Printf("Estimated gas: %v\n", tx.Gas())
+		if tx.Type() == types.DynamicFeeTxType {
+			fmt.Printf("Max fee: %v wei\n", tx.GasFeeCap())
+			fmt.Printf("Priority fee: %v wei\n", tx.GasTipCap())
+		} else {
+			fmt.Printf("Gas price: %v wei\n", tx.GasPrice())
+		}
+		fmt.Printf("Estimated cost: %v ETH (%v wei)\n", decimal.NewFromBigInt(cost, -18), cost)
+		exit(0)
+	}
+	if viper.GetString("safe") != "" {
+		proposeSafeTransaction(tx)
+		exit(0)
+	}
+	if buildingTx {
+		printTxFile(getChainID(), getAddress(), tx, false)
+		exit(0)
+	}
+	if viper.GetBool("offline") {
+		// Typed transactions, like those with access lists, are encoded as their type followed by RLP.
+		raw, err := tx.MarshalBinary()
+		check(err, "encoding transaction")
+		fmt.Fprintln(os.Stderr, "Offline mode: transaction not sent.")
+		printResult(hexutil.Encode(raw))
+		exit(0)
+	}
+	// A transaction the wallet is to send is unsigned, so it has no sender yet.
+	walletSends := getFrom() == walletConnectFrom && !walletConnectSignsTransactions(getChainID())
+	from := getAddress()
+	if !walletSends {
+		var err error
+		if from, err = types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err != nil {
+			return err
+		}
+	}
+	if !viper.GetBool("force") {
+		if err := t.simulate(ctx, from, tx); err != nil {
+			return xerrors.Errorf("the transaction would fail, so it wasn't sent (--force sends it anyway): %w", err)
+		}
+	}
+	t.approve(from, tx)
+	if walletSends {
+		sent, err := walletConnectSendTransaction(tx)
+		if err != nil {
+			return err
+		}
+		nextNonces[from] = sent.Nonce() + 1
+		// Whoever called us holds tx, and goes on to wait for it to be mined, so make it the transaction the wallet sent.
+		*tx = *sent
+		scriptResult = tx.Hash().Hex()
+		return nil
+	}
+	if err := t.Client.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	nextNonces[from] = tx.Nonce() + 1
+	scriptResult = tx.Hash().Hex()
+	return nil
+}
 
-		wallet := wallets[0]
-
-		// "Open" the wallet.
-		// This exchanges initial handshake messages with the wallet.
-		// On a Trezor, this may require PIN entry.
-		err = wallet.Open("")
-		if err == usbwallet.ErrTrezorPINNeeded {
-			pin, pinErr := trezor.GetPIN("enter PIN")
-			check(pinErr, "getting PIN input")
-			err = wallet.Open(pin)
+// simulate runs tx, sent from from, as a call against the pending state, and returns why it reverts, if it does.
+func (t transactor) simulate(ctx context.Context, from common.Address, tx *types.Transaction) error {
+	msg := ethereum.CallMsg{
+		From:       from,
+		To:         tx.To(),
+		Gas:        tx.Gas(),
+		Value:      tx.Value(),
+		Data:       tx.Data(),
+		AccessList: tx.AccessList(),
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		msg.GasFeeCap, msg.GasTipCap = tx.GasFeeCap(), tx.GasTipCap()
+	} else {
+		msg.GasPrice = tx.GasPrice()
+	}
+	if _, err := t.Client.PendingCallContract(ctx, msg); err != nil {
+		var theABI abi.ABI
+		if t.abi != nil {
+			theABI = *t.abi
 		}
-		check(err, "opening hardware wallet")
+		return explainRevert(theABI, err)
+	}
+	return nil
+}
 
-		// Notify the wallet when the program exits.
-		atExit(func() {
-			wallet.Close()
-		})
+func getTransactor() transactor {
+	return transactor{Client: getNode()}
+}
+
+var (
+	singletonAccount accounts.Account
+	singletonWallet  accounts.Wallet
+)
+
+// getDerivationPath parses the --derivation-path flag. user describes what needs it, for error messages.
+func getDerivationPath(user string) accounts.DerivationPath {
+	path := expandDerivationScheme(viper.GetString("derivation-path"))
+	if path == "" {
+		fatalf("`derivation-path` flag is empty, but `from` is set to %v. I can't use it without a derivation path.\n", user)
+	}
+	if !strings.HasPrefix(path, "m/") {
+		fatalf("got invalid derivation-path: %q. Derivation path must start with \"m/\".", path)
+	}
+	parsed, err := accounts.ParseDerivationPath(path)
+	if err != nil {
+		fatalf("got invalid derivation-path: %q. %v", path, err)
+	}
+	return parsed
+}
 
-		singletonWallet = wallet
+func openHardwareWallet() (accounts.Wallet, accounts.Account) {
+	if singletonWallet != nil {
+		return singletonWallet, singletonAccount
 	}
+	singletonWallet = openHardwareDevice()
 
 	// Open account.
 	{
-		path := viper.GetString("derivation-path")
-		if path == "" {
-			fatal("`derivation-path` flag is empty, but `from` is set to \"hardware\". I can't use a hardware wallet without a derivation path.")
-		}
-		if !strings.HasPrefix(path, "m/") {
-			fatalf("got invalid derivation-path: %q. Derivation path must start with \"m/\".", path)
-		}
-		parsed, err := accounts.ParseDerivationPath(path)
-		if err != nil {
-			fatalf("got invalid derivation-path: %q. %v", path, err)
-		}
+		var err error
 		singletonAccount, err = singletonWallet.Derive(
-			parsed,
+			getDerivationPath("a hardware wallet"),
 			true, // "pin" this account -- needed for the wallet object to recognize it later in "wallet.SignTx"
 		)
 		if err != nil && err.Error() == "reply lacks public key entry" {
@@ -153,10 +279,54 @@ func openHardwareWallet() (accounts.Wallet, accounts.Account) {
 	return singletonWallet, singletonAccount
 }
 
-func getNetID() *big.Int {
-	netID, err := getNode().NetworkID(context.Background())
-	check(err, "Failed to get Ethereum network id")
-	return netID
+// openHardwareDevice opens the one connected hardware wallet, without deriving any account from it.
+func openHardwareDevice() accounts.Wallet {
+	// Check for connected Ledgers and Trezors.
+	ledgerHub, err := usbwallet.NewLedgerHub()
+	check(err, "calling usbwallet.NewLedgerHub()")
+	trezorHIDHub, err := usbwallet.NewTrezorHubWithHID()
+	check(err, "calling usbwallet.NewTrezorHubWithHID()")
+	trezorWebUSBHub, err := usbwallet.NewTrezorHubWithWebUSB()
+	check(err, "calling usbwallet.NewTrezorHubWithWebUSB()")
+
+	// Collect them into a single list.
+	wallets := accounts.NewManager(nil, ledgerHub, trezorHIDHub, trezorWebUSBHub).Wallets()
+
+	// Don't proceed unless there is exactly one hardware wallet available.
+	if len(wallets) == 0 {
+		fatal("No hardware wallets found. Is a hardware wallet plugged in? If it's a Ledger, is it unlocked?")
+	}
+	if len(wallets) > 1 {
+		fatalf("%v hardware wallets found, I don't know which to use", len(wallets))
+	}
+
+	wallet := wallets[0]
+
+	// "Open" the wallet.
+	// This exchanges initial handshake messages with the wallet.
+	// On a Trezor, this may require PIN entry.
+	err = wallet.Open("")
+	if err == usbwallet.ErrTrezorPINNeeded {
+		pin, pinErr := trezor.GetPIN("enter PIN")
+		check(pinErr, "getting PIN input")
+		err = wallet.Open(pin)
+	}
+	check(err, "opening hardware wallet")
+
+	// Notify the wallet when the program exits.
+	atExit(func() {
+		wallet.Close()
+	})
+
+	return wallet
+}
+
+// getNodeChainID returns the chain id the node reports with eth_chainId.
+// Unlike its network id, which some chains share, it's the id signatures commit to.
+func getNodeChainID() *big.Int {
+	chainID, err := getNode().ChainID(context.Background())
+	check(err, "Failed to get the node's chain id")
+	return chainID
 }
 
 func getGasPrice() *big.Int {
@@ -173,28 +343,98 @@ func getGasPrice() *big.Int {
 	return price
 }
 
+// getGasLimit returns the gas limit for a transaction like msg: the --gas-limit flag,
+// or if that isn't set, the node's estimate of the gas msg uses.
+func getGasLimit(msg ethereum.CallMsg) uint64 {
+	if gasLimit := uint64(viper.GetInt64("gas-limit")); gasLimit != 0 {
+		return gasLimit
+	}
+	gas, err := getNode().EstimateGas(context.Background(), msg)
+	check(err, "estimating gas")
+	return gas
+}
+
+// nextNonces maps each account poke has sent transactions from to the nonce after its last one,
+// in case the node's pending nonce lags behind, e.g. when a script sends several transactions.
+var nextNonces = make(map[common.Address]uint64)
+
+// getNonce returns the nonce for the next transaction from `from`: the --nonce flag, or if that
+// isn't set, the account's pending nonce, skipping past any transactions poke has already sent.
+func getNonce(from common.Address) uint64 {
+	if isExplicitlySet("nonce") {
+		return uint64(viper.GetInt64("nonce"))
+	}
+	nonce, err := getNode().PendingNonceAt(context.Background(), from)
+	check(err, "retrieving nonce")
+	if next := nextNonces[from]; next > nonce {
+		nonce = next
+	}
+	return nonce
+}
+
+// getFrom returns the account to send transactions from, as accepted by --from.
+// --from-index picks one of the default keys instead.
+func getFrom() string {
+	if !isExplicitlySet("from-index") {
+		return viper.GetString("from")
+	}
+	if isExplicitlySet("from") {
+		fatal("--from and --from-index both pick an account to send from, so only give one of them")
+	}
+	index := viper.GetInt("from-index")
+	if index < 0 || index >= len(defaultKeys) {
+		fatalf("--from-index must be between 0 and %v, not %v\n", len(defaultKeys)-1, index)
+	}
+	return defaultKeys[index]
+}
+
+// getOptimizeRuns returns the number of runs to optimize solc compilation for,
+// or "none" if the optimizer should be off.
 func getOptimizeRuns() string {
 	optimizeRunsFlag := viper.GetString("optimize-runs")
 
 	if optimizeRunsFlag == "" {
 		optimizeRunsFlag = "1"
 	}
+	if optimizeRunsFlag != "none" {
+		_, err := strconv.ParseUint(optimizeRunsFlag, 10, 64)
+		check(err, fmt.Sprintf("--optimize-runs must be a number of runs or \"none\", not %q", optimizeRunsFlag))
+	}
 
 	return optimizeRunsFlag
 }
 
 func getTxnOpts() *bind.TransactOpts {
-	from := viper.GetString("from")
+	from := getFrom()
 	var txnOpts *bind.TransactOpts
 
-	if from != "hardware" {
-		txnOpts = bind.NewKeyedTransactor(parseKey(from))
+	if common.IsHexAddress(from) || buildingTx {
+		// With only an address and no key, all we can do is build an unsigned transaction.
+		if !viper.GetBool("offline") && !buildingTx {
+			fatalf("`from` is set to the address %v, which I can't sign with. Use --offline or `tx build` to output an unsigned transaction.\n", from)
+		}
+		txnOpts = &bind.TransactOpts{
+			From: getAddress(),
+			Signer: func(
+				from common.Address,
+				tx *types.Transaction,
+			) (*types.Transaction, error) {
+				return tx, nil
+			},
+		}
+	} else if isKMS(from) {
+		txnOpts = kmsTransactOpts(from)
+	} else if from == walletConnectFrom {
+		txnOpts = walletConnectTransactOpts()
+	} else if from != "hardware" {
+		var err error
+		txnOpts, err = bind.NewKeyedTransactorWithChainID(parseKey(from), getChainID())
+		check(err, "creating transactor")
 	} else {
 		wallet, account := openHardwareWallet()
 		txnOpts = &bind.TransactOpts{
 			From: account.Address,
 			Signer: func(
-				protocolSigner types.Signer,
 				from common.Address,
 				tx *types.Transaction,
 			) (*types.Transaction, error) {
@@ -205,29 +445,159 @@ func getTxnOpts() *bind.TransactOpts {
 						account.Address.Hex(),
 					)
 				}
-				fmt.Println("Waiting for you to confirm on the hardware wallet...")
-				return wallet.SignTx(account, tx, getNetID())
+				fmt.Fprintln(os.Stderr, "Waiting for you to confirm on the hardware wallet...")
+				return wallet.SignTx(account, tx, getChainID())
 			},
 		}
 	}
 
-	txnOpts.GasPrice = getGasPrice()
+	if safe, ok := getSafeAddress(); ok {
+		// The transaction is the Safe's, so it's estimated as sent from the Safe, and signed as a Safe transaction instead.
+		txnOpts.From = safe
+	}
+	if viper.GetBool("dry-run") || viper.GetString("safe") != "" {
+		// There's no need to sign a transaction that won't be sent.
+		txnOpts.Signer = func(
+			from common.Address,
+			tx *types.Transaction,
+		) (*types.Transaction, error) {
+			return tx, nil
+		}
+	}
+
+	if viper.GetString("access-list") != "" || viper.GetBool("auto-access-list") {
+		txnOpts.Signer = withAccessList(txnOpts.Signer)
+	}
+
+	if useDynamicFees() {
+		txnOpts.GasFeeCap, txnOpts.GasTipCap = getFees()
+	} else {
+		txnOpts.GasPrice = getGasPrice()
+	}
+	if value := viper.GetString("value"); value != "" {
+		txnOpts.Value = parseValue(value)
+	}
+	// Zero leaves the gas limit to be estimated.
+	txnOpts.GasLimit = uint64(viper.GetInt64("gas-limit"))
+	txnOpts.Nonce = new(big.Int).SetUint64(getNonce(txnOpts.From))
 
-	// TODO: options for bumping or setting the gas limit, maybe the eth value, and maybe even the nonce.
 	return txnOpts
 }
 
+// checkPayable exits if --value gives a nonzero amount of ETH to send to method, but method isn't payable.
+// The transaction would only revert.
+func checkPayable(method abi.Method, name string) {
+	value := viper.GetString("value")
+	if value != "" && parseValue(value).Sign() != 0 && !method.IsPayable() {
+		fatalf("%v isn't payable, so it can't be sent ETH with --value\n", name)
+	}
+}
+
+// valueUnits maps the units accepted by --value-unit to their number of decimals.
+var valueUnits = map[string]int32{
+	"wei":   0,
+	"gwei":  9,
+	"ether": 18,
+	"eth":   18,
+}
+
+// parseValue parses an amount of ETH to send, in the unit given by --value-unit,
+// and returns it in wei. It accepts the same notation as parseUint256.
+func parseValue(s string) *big.Int {
+	unit := viper.GetString("value-unit")
+	decimals, ok := valueUnits[unit]
+	if !ok {
+		fatalf("unknown --value-unit %q; expected wei, gwei, or ether (eth)\n", unit)
+	}
+	return truncateDecimal(parseDecimal(s).Shift(decimals))
+}
+
+// signMessage signs text with the `from` account, following EIP-191's personal_sign scheme.
+// The returned signature is 65 bytes, [R || S || V], with V being 27 or 28.
+func signMessage(text []byte) []byte {
+	from := getFrom()
+	var sig []byte
+	var err error
+	if common.IsHexAddress(from) {
+		fatalf("`from` is set to the address %v, which I can't sign with.\n", from)
+	} else if isKMS(from) {
+		sig, err = kmsSign(from, accounts.TextHash(text))
+	} else if from == walletConnectFrom {
+		sig, err = walletConnectSignMessage(text)
+	} else if from != "hardware" {
+		sig, err = crypto.Sign(accounts.TextHash(text), parseKey(from))
+	} else {
+		wallet, account := openHardwareWallet()
+		fmt.Fprintln(os.Stderr, "Waiting for you to confirm on the hardware wallet...")
+		sig, err = wallet.SignText(account, text)
+	}
+	check(err, "signing message")
+	if sig[64] < 27 {
+		sig[64] += 27
+	}
+	return sig
+}
+
+// signTypedData signs EIP-712 typed data, given its domain separator and the hash of its struct, with the `from` account.
+// The returned signature is 65 bytes, [R || S || V], with V being 27 or 28.
+func signTypedData(domainSeparator, structHash common.Hash) []byte {
+	data := append([]byte{0x19, 0x01}, append(domainSeparator.Bytes(), structHash.Bytes()...)...)
+	from := getFrom()
+	var sig []byte
+	var err error
+	if common.IsHexAddress(from) {
+		fatalf("`from` is set to the address %v, which I can't sign with.\n", from)
+	} else if isKMS(from) {
+		sig, err = kmsSign(from, crypto.Keccak256(data))
+	} else if from == walletConnectFrom {
+		// Wallets sign typed data given in full, to show it to the user, and poke only has its hash.
+		fatal("Signing typed data over WalletConnect isn't supported yet. Sign with another --from.")
+	} else if from != "hardware" {
+		sig, err = crypto.Sign(crypto.Keccak256(data), parseKey(from))
+	} else {
+		wallet, account := openHardwareWallet()
+		fmt.Fprintln(os.Stderr, "Waiting for you to confirm on the hardware wallet...")
+		sig, err = wallet.SignData(account, accounts.MimetypeTypedData, data)
+	}
+	check(err, "signing typed data")
+	if sig[64] < 27 {
+		sig[64] += 27
+	}
+	return sig
+}
+
 var deployment *bind.BoundContract
 
+// getContractAddress returns the address of the deployed contract, from the `address` flag.
+func getContractAddress() common.Address {
+	address := viper.GetString("address")
+	if address == "" {
+		if deployed, ok := lookupDeployment(); ok {
+			return deployed
+		}
+		fmt.Fprintln(os.Stderr, "No address specified for the contract.")
+		fmt.Fprintln(os.Stderr, "To specify an address, set the --address flag or the POKE_ADDRESS environment variable,")
+		fmt.Fprintln(os.Stderr, "or deploy the contract to record its address in the --deployments file.")
+		exit(1)
+	}
+	if isAlias(address) {
+		return lookupAlias(address)
+	}
+	if isENSName(address) {
+		return resolveENS(address)
+	}
+	return hexToAddress(address)
+}
+
 func getDeployment(abi abi.ABI) *bind.BoundContract {
 	if deployment == nil {
-		address := viper.GetString("address")
-		if address == "" {
-			fmt.Fprintln(os.Stderr, "No address specified for the contract.")
-			fmt.Fprintln(os.Stderr, "To specify an address, set the --address flag or the POKE_ADDRESS environment variable.")
-			exit(1)
-		}
-		deployment = bind.NewBoundContract(hexToAddress(address), abi, getNode(), getNode(), getNode())
+		deployment = bind.NewBoundContract(
+			getContractAddress(),
+			abi,
+			caller{getNode()},
+			transactor{Client: getNode(), abi: &abi},
+			getNode(),
+		)
 	}
 	return deployment
 }
@@ -250,6 +620,12 @@ func init() {
 // a hex-encoded private key from the environment variable
 // named "POKE_<s[1:]>".
 func parseKey(s string) *ecdsa.PrivateKey {
+	if isKeystore(s) {
+		return loadKeystore(s)
+	}
+	if isMnemonic(s) {
+		return deriveMnemonicKey(s)
+	}
 	origS := s
 	if strings.HasPrefix(s, "@") {
 		env := os.Getenv("POKE_" + s[1:])
@@ -299,9 +675,16 @@ func hexToAddress(s string) common.Address {
 // named "POKE_<s[1:]>", then returns the address corresponding
 // to that key.
 func parseAddress(s string) common.Address {
+	defer failingAs(usageError)()
 	if strings.HasPrefix(s, "@") {
 		return toAddress(parseKey(s))
 	}
+	if isAlias(s) {
+		return lookupAlias(s)
+	}
+	if isENSName(s) {
+		return resolveENS(s)
+	}
 	return hexToAddress(s)
 }
 
@@ -320,6 +703,12 @@ func parseAddressArray(s string) []common.Address {
 // For example, ".33e4" -> 3300. However, "3300" is perfectly acceptable as well.
 // It also requires that long numbers use commas.
 func parseUint256(s string) *big.Int {
+	return truncateDecimal(parseDecimal(s))
+}
+
+// parseDecimal parses a number in the notation parseUint256 accepts, without truncating it.
+func parseDecimal(s string) decimal.Decimal {
+	defer failingAs(usageError)()
 	exp := 0
 	var err error
 	index := strings.Index(s, "e")
@@ -341,16 +730,16 @@ func parseUint256(s string) *big.Int {
 
 	base, err := decimal.NewFromString(strings.ReplaceAll(s, "_", ""))
 	check(err, fmt.Sprintf("Expected a decimal number, but got %q instead.\n", s))
-	return truncateDecimal(base.Shift(int32(exp)))
+	return base.Shift(int32(exp))
 }
 
-// parseUint256Array parses an array of Uint256's according to `parseUint256`.
+// parseUint256Array parses an array of Uint256's as uint256 arguments are parsed, scaled by --decimals.
 // All arrays are assumed to start with "[" and end with "]", and are comma-separated.
 func parseUint256Array(s string) []*big.Int {
 	parts := parseArray(s)
 	asBigs := make([]*big.Int, len(parts))
 	for i, part := range parts {
-		asBigs[i] = parseUint256(part)
+		asBigs[i] = parseAmount(part, false)
 	}
 	return asBigs
 }
@@ -370,6 +759,21 @@ func parseBoolArray(s string) []bool {
 	return asBools
 }
 
+// parseArgs parses command-line args as the inputs of the method with the given
+// signature. Use the signature "constructor" for the constructor.
+func parseArgs(sig string, inputs abi.Arguments, args []string) []interface{} {
+	defer failingAs(usageError)()
+	parsed := make([]interface{}, len(args))
+	for i, arg := range args {
+		if members := enumInputs[sig]; members != nil && members[i] != nil {
+			parsed[i] = parseEnum(members[i], arg)
+			continue
+		}
+		parsed[i] = parseArg(inputs[i].Type, arg)
+	}
+	return parsed
+}
+
 // truncateDecimal truncates d to an integer and returns it as a *big.Int.
 func truncateDecimal(d decimal.Decimal) *big.Int {
 	coeff := d.Coefficient()
@@ -410,7 +814,7 @@ func displayBigInt(i *big.Int) string {
 func displayBigIntArray(arr *[]*big.Int) string {
 	strArr := make([]string, len(*arr))
 	for i, a := range *arr {
-		strArr[i] = displayBigInt(a)
+		strArr[i] = displayAmount(a)
 	}
 	return "[" + strings.Join(strArr, ", ") + "]"
 }
@@ -439,62 +843,274 @@ func keyToHex(key *ecdsa.PrivateKey) string {
 	return hex.EncodeToString(crypto.FromECDSA(key))
 }
 
-func parseArray(s string) []string {
-	s = strings.ReplaceAll(s, "[", "")
-	s = strings.ReplaceAll(s, "]", "")
-	s = strings.ReplaceAll(s, " ", "")
-	parts := strings.Split(s, ",")
-	return parts
+// miningContext returns a context that expires after the duration given by the
+// `wait-timeout` flag. A zero timeout means wait indefinitely.
+func miningContext() (context.Context, context.CancelFunc) {
+	timeout := waitTimeout()
+	if timeout == 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
+// waitTimeout returns the `wait-timeout` flag's duration, or that of `timeout`, its old name, if only that is set.
+func waitTimeout() time.Duration {
+	if isExplicitlySet("timeout") && !isExplicitlySet("wait-timeout") {
+		return viper.GetDuration("timeout")
+	}
+	return viper.GetDuration("wait-timeout")
+}
+
+// waitMined waits for tx to be mined, and then for --confirmations more blocks,
+// giving up after the `wait-timeout` flag's duration.
+// If the deadline passes, the transaction is left as-is; it may still be mined later.
+func waitMined(name string, tx *types.Transaction) *types.Receipt {
+	ctx, cancel := miningContext()
+	defer cancel()
+	receipt, err := bind.WaitMined(ctx, getNode(), tx)
+	if err == nil {
+		receipt, err = waitConfirmations(ctx, tx, receipt)
+	}
+	if err == context.DeadlineExceeded {
+		waitingFor := "mined"
+		if viper.GetInt64("confirmations") > 0 {
+			waitingFor = "mined and confirmed"
+		}
+		failf(
+			timeoutError,
+			"Timed out after %v waiting for %v to be %v.\n"+
+				"The transaction %v was sent and may still be mined later.\n",
+			waitTimeout(),
+			name,
+			waitingFor,
+			tx.Hash().Hex(),
+		)
+	}
+	check(err, "waiting for "+name+" to be mined")
+	return receipt
+}
+
+// waitConfirmations waits until --confirmations blocks have been mined on top of receipt's, for tx, and returns it.
+// If a reorg drops tx from its block in the meantime, it waits for tx to be mined again.
+func waitConfirmations(ctx context.Context, tx *types.Transaction, receipt *types.Receipt) (*types.Receipt, error) {
+	confirmations := viper.GetInt64("confirmations")
+	if confirmations <= 0 {
+		return receipt, nil
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		header, err := getNode().HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		if new(big.Int).Sub(header.Number, receipt.BlockNumber).Int64() >= confirmations {
+			// Check the transaction is still where it was.
+			current, err := getNode().TransactionReceipt(ctx, tx.Hash())
+			if err == nil && current.BlockHash == receipt.BlockHash {
+				return current, nil
+			}
+			if receipt, err = bind.WaitMined(ctx, getNode(), tx); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
 }
 
 // log logs the result of a mutator txn to stdout, including that txn's events.
+// With --no-wait, it only prints the transaction's hash, without waiting for it to be mined.
+// With --output json, it prints them as one JSON object; see sentTxJSON.
 func log(name string, tx *types.Transaction, abi abi.ABI, err error) {
-	check(err, name+" failed")
-	receipt, err := bind.WaitMined(context.Background(), getNode(), tx)
-	check(err, "waiting for "+name+" to be mined")
+	receipt := waitSent(name, tx, abi, err)
+	if jsonOutput() {
+		printJSON(sentTxJSON(abi, tx, receipt))
+		return
+	}
+	printReceipt(abi, receipt)
+}
+
+// waitSent checks that tx, sent with err, is mined without reverting, and returns its receipt.
+// With --no-wait, it only prints the transaction's hash, and returns nil.
+func waitSent(name string, tx *types.Transaction, abi abi.ABI, err error) *types.Receipt {
+	check(explainRevert(abi, err), name+" failed")
+	if viper.GetBool("no-wait") {
+		fmt.Fprintln(humanOutput(), "Sent transaction "+tx.Hash().Hex())
+		return nil
+	}
+	receipt := waitMined(name, tx)
 	if receipt.Status != types.ReceiptStatusSuccessful {
-		fatal("transaction reverted")
+		fatal(withClass(revertError, replayRevert(abi, tx, receipt)))
+	}
+	recordGas(name, receipt.GasUsed)
+	return receipt
+}
+
+// sentTxJSON describes tx, which poke sent, as it prints it with --output json: its hash,
+// and, unless receipt is nil because of --no-wait, where it was mined, the gas it used, and its events.
+func sentTxJSON(abi abi.ABI, tx *types.Transaction, receipt *types.Receipt) map[string]interface{} {
+	result := map[string]interface{}{"transactionHash": tx.Hash().Hex()}
+	if receipt == nil {
+		return result
+	}
+	result["receipt"] = map[string]interface{}{
+		"status":            "success",
+		"blockNumber":       receipt.BlockNumber.Uint64(),
+		"blockHash":         receipt.BlockHash.Hex(),
+		"gasUsed":           receipt.GasUsed,
+		"effectiveGasPrice": effectiveGasPrice(tx, receipt).String(),
 	}
-	fmt.Printf("Gas Used: %v\n", receipt.GasUsed)
-	deployment := getDeployment(abi)
+	events := []map[string]interface{}{}
+	for _, log := range receipt.Logs {
+		events = append(events, logJSON(abi, *log))
+	}
+	result["events"] = events
+	return result
+}
+
+// printReceipt prints the gas a mined transaction used, and its events, decoded with abi.
+// A nil receipt, of a transaction sent with --no-wait, prints nothing.
+func printReceipt(abi abi.ABI, receipt *types.Receipt) {
+	if receipt == nil {
+		return
+	}
+	out := humanOutput()
+	fmt.Fprintf(out, "Gas Used: %v\n", receipt.GasUsed)
 	if len(receipt.Logs) > 0 {
-		fmt.Println("Done. Events:")
+		fmt.Fprintln(out, "Done. Events:")
 		for _, log := range receipt.Logs {
-			// TODO: handle logs from dependencies
-			for name, event := range abi.Events {
-				if log.Topics[0] == event.Id() {
-					m := make(map[string]interface{})
-					err := deployment.UnpackLogIntoMap(m, name, *log)
-					if err == nil {
-						fmt.Println("\t" + name)
-						for key, value := range m {
-							if addr, ok := value.(common.Address); ok {
-								value = addr.Hex()
-							}
-							fmt.Printf("\t\t%v: %v\n", key, value)
-						}
-					} else {
-						fmt.Println("\t" + err.Error())
-					}
-					break
-				}
-			}
+			printEvent(abi, *log)
 		}
 	} else {
-		fmt.Println("< Done. No events generated >")
+		fmt.Fprintln(out, "< Done. No events generated >")
 	}
 }
 
+// jsonOutput reports whether --json or the `output` flag asks for JSON output.
+func jsonOutput() bool {
+	if viper.GetBool("json") {
+		return true
+	}
+	switch output := viper.GetString("output"); output {
+	case "json":
+		return true
+	case "text", "":
+		return false
+	default:
+		fatalf("unknown --output format %q; expected \"text\" or \"json\"\n", output)
+		return false
+	}
+}
+
+// humanOutput returns where to write output that's meant for people rather than scripts.
+// That's stdout, unless stdout is reserved for JSON by --output json.
+func humanOutput() io.Writer {
+	if jsonOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// printJSON prints v to stdout as indented JSON.
+func printJSON(v interface{}) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	check(err, "encoding JSON output")
+	fmt.Println(string(out))
+}
+
+// printResult prints a command's result on its own line, or, with --output json, as {"result": result}.
+// A script can save it in a variable.
+func printResult(result string) {
+	scriptResult = result
+	if jsonOutput() {
+		printJSON(map[string]string{"result": result})
+		return
+	}
+	fmt.Println(result)
+}
+
+// printEvent decodes and prints log, if it matches one of the events in abi.
+// Other events, like those of the contracts abi's calls, are decoded with the --extra-abi files
+// and the common events poke knows, and labelled with the address that emitted them.
+func printEvent(abi abi.ABI, log types.Log) {
+	if len(log.Topics) == 0 {
+		return
+	}
+	out := humanOutput()
+	eventABI, label := abi, ""
+	name, ok := "", false
+	for eventName, event := range abi.Events {
+		if log.Topics[0] == event.ID {
+			name, ok = eventName, true
+		}
+	}
+	if !ok {
+		eventABI, name, ok = matchOtherEvent(log)
+		if !ok {
+			fmt.Fprintf(out, "\tUnknown event %v from %v\n", log.Topics[0].Hex(), log.Address.Hex())
+			return
+		}
+		label = " (from " + log.Address.Hex() + ")"
+	}
+
+	values, err := eventValues(eventABI, name, log)
+	if err != nil {
+		fmt.Fprintln(out, "\t"+err.Error())
+		return
+	}
+	fmt.Fprintln(out, "\t"+name+label)
+	for i, input := range eventABI.Events[name].Inputs {
+		fmt.Fprintf(out, "\t\t%v: %v\n", input.Name, values[i])
+	}
+}
+
+// eventValues decodes log as an instance of the event name in abi,
+// and returns the display form of each of the event's inputs, in order.
+func eventValues(abi abi.ABI, name string, log types.Log) ([]string, error) {
+	m := make(map[string]interface{})
+	// Decoding doesn't need the node, nor the contract's address, which might not be known.
+	contract := bind.NewBoundContract(log.Address, abi, nil, nil, nil)
+	if err := contract.UnpackLogIntoMap(m, name, log); err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, input := range abi.Events[name].Inputs {
+		// Indexed structs, arrays, strings, and bytes are only logged as their hash.
+		display := fmt.Sprint(m[input.Name])
+		if hash, ok := m[input.Name].(common.Hash); ok {
+			display = hash.Hex()
+		} else if m[input.Name] != nil {
+			display = displayValue(input.Type, m[input.Name])
+		}
+		values = append(values, display)
+	}
+	return values, nil
+}
+
 func getAddress() common.Address {
-	from := viper.GetString("from")
+	from := getFrom()
+	if common.IsHexAddress(from) {
+		return common.HexToAddress(from)
+	}
 	if from == "hardware" {
 		_, account := openHardwareWallet()
 		return account.Address
 	}
+	if isKMS(from) {
+		return kmsAddress(from)
+	}
+	if from == walletConnectFrom {
+		return walletConnectAddress(getChainID())
+	}
 	return toAddress(parseKey(from))
 }
 
-func deployCmd(name string, abi abi.ABI, bytecode []byte) *cobra.Command {
+func deployCmd(name string, abi abi.ABI, bin string, contracts []string, metadata string) *cobra.Command {
 	parts := []string{"deploy"}
 	for _, input := range abi.Constructor.Inputs {
 		if input.Name == "" {
@@ -503,36 +1119,229 @@ func deployCmd(name string, abi abi.ABI, bytecode []byte) *cobra.Command {
 			parts = append(parts, "<"+input.Name+">")
 		}
 	}
+	var long []string
+	for i, members := range enumInputs["constructor"] {
+		if members != nil {
+			long = append(long, enumUsage(abi.Constructor.Inputs[i].Name, members))
+		}
+	}
 	return &cobra.Command{
 		Use:   strings.Join(parts, " "),
 		Short: "Deploy a new copy of " + name,
+		Long:  strings.Join(long, "\n"),
 		Args:  cobra.ExactArgs(len(abi.Constructor.Inputs)),
 		Run: func(cmd *cobra.Command, args []string) {
-			inputs := make([]interface{}, len(args))
-			for i, arg := range args {
-				inputs[i] = solTypes[abi.Constructor.Inputs[i].Type.String()].parser(arg)
-			}
-			address, tx, _, err := bind.DeployContract(
-				getTxnOpts(),
-				abi,
-				bytecode,
-				getNode(),
-				inputs...,
-			)
+			inputs := parseArgs("constructor", abi.Constructor.Inputs, args)
+			address, constructorArgs, tx, receipt := deployContract(abi, bin, contracts, inputs)
 			viper.Set("address", address.Hex())
-			log("deployment", tx, abi, err)
-			fmt.Println("export POKE_ADDRESS=" + address.Hex())
+			scriptResult = address.Hex()
+			if !jsonOutput() {
+				printReceipt(abi, receipt)
+			}
+			fmt.Fprintln(humanOutput(), "Constructor arguments: "+constructorArgs)
+			if file := viper.GetString("export-file"); file != "" {
+				exports := fmt.Sprintf(
+					"export POKE_ADDRESS=%v\nexport POKE_DEPLOY_TX=%v\nexport POKE_CONSTRUCTOR_ARGS=%v\n",
+					address.Hex(),
+					tx.Hash().Hex(),
+					constructorArgs,
+				)
+				check(ioutil.WriteFile(file, []byte(exports), 0644), "writing "+file)
+			}
+			if viper.GetBool("verify") {
+				verifyContract(metadata, address, constructorArgs)
+			}
+			if jsonOutput() {
+				result := sentTxJSON(abi, tx, receipt)
+				result["address"] = address.Hex()
+				result["constructorArgs"] = constructorArgs
+				printJSON(result)
+			} else {
+				fmt.Println("export POKE_ADDRESS=" + address.Hex())
+			}
+		},
+	}
+}
+
+// deployContract deploys a new copy of the contract with abi and bin, linked with the --link libraries,
+// with the constructor arguments inputs, waits for it to be mined, and records it in the deployments file.
+// It returns the new contract's address, its constructor arguments, hex-encoded, and the deployment and its receipt.
+func deployContract(abi abi.ABI, bin string, contracts []string, inputs []interface{}) (
+	address common.Address, constructorArgs string, tx *types.Transaction, receipt *types.Receipt) {
+	checkPayable(abi.Constructor, "The constructor")
+	bytecode, err := linkBytecode(bin, contracts, viper.GetStringSlice("link"))
+	check(err, "linking libraries")
+	address, tx, _, err = bind.DeployContract(
+		getTxnOpts(),
+		abi,
+		bytecode,
+		transactor{Client: getNode(), abi: &abi, bytecode: bytecode},
+		inputs...,
+	)
+	receipt = waitSent("deployment", tx, abi, err)
+
+	// Block explorers want the encoded constructor arguments to verify the contract.
+	packedArgs, err := abi.Pack("", inputs...)
+	check(err, "encoding constructor arguments")
+	constructorArgs = hexutil.Encode(packedArgs)
+
+	recordDeployment(deploymentRecord{
+		Address:         address.Hex(),
+		TransactionHash: tx.Hash().Hex(),
+		ConstructorArgs: constructorArgs,
+		ChainID:         getChainID().Int64(),
+	})
+	return address, constructorArgs, tx, receipt
+}
+
+// describeCmd prints a reference of the contract's interface: its methods, split
+// into calls and transactions as in the usage message, and its events.
+func describeCmd(name string, theABI abi.ABI, abiJSON string, devDoc DevDoc, userDoc UserDoc) *cobra.Command {
+	return &cobra.Command{
+		Use:     "describe",
+		Aliases: []string{"functions"},
+		Short:   "Describe the methods and events of " + name,
+		Args:    cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			mutability, err := parseStateMutability(abiJSON)
+			check(err, "reading state mutability from ABI")
+
+			var calls, transactions []abi.Method
+			for _, method := range theABI.Methods {
+				if method.IsConstant() {
+					calls = append(calls, method)
+				} else {
+					transactions = append(transactions, method)
+				}
+			}
+			for _, block := range []struct {
+				title   string
+				methods []abi.Method
+			}{
+				{"State Reading Calls", calls},
+				{"State-Changing Transactions", transactions},
+			} {
+				fmt.Println(block.title + ":")
+				sort.Slice(block.methods, func(i, j int) bool {
+					return block.methods[i].Name < block.methods[j].Name
+				})
+				for _, method := range block.methods {
+					fmt.Printf("  %v(%v) %v", method.Name, describeArgs(method.Inputs), mutability[method.Sig])
+					if len(method.Outputs) > 0 {
+						fmt.Printf(" returns (%v)", describeArgs(method.Outputs))
+					}
+					fmt.Println()
+					doc := userDoc.Methods[method.Sig].Notice
+					if dev := devDoc.Methods[method.Sig].Details; dev != "" {
+						if doc != "" {
+							doc += "\n"
+						}
+						doc += dev
+					}
+					if doc != "" {
+						fmt.Println("      " + strings.ReplaceAll(doc, "\n", "\n      "))
+					}
+				}
+				fmt.Println()
+			}
+
+			var events []abi.Event
+			for _, event := range theABI.Events {
+				events = append(events, event)
+			}
+			sort.Slice(events, func(i, j int) bool {
+				return events[i].Name < events[j].Name
+			})
+			fmt.Println("Events:")
+			for _, event := range events {
+				fmt.Printf("  %v(%v)", event.Name, describeArgs(event.Inputs))
+				if event.Anonymous {
+					fmt.Print(" anonymous")
+				}
+				fmt.Println()
+			}
 		},
 	}
 }
 
+// abiCmd prints the contract's ABI as indented JSON, for use with other tools.
+func abiCmd(name string, abiJSON string) *cobra.Command {
+	return &cobra.Command{
+		Use:     "abi",
+		Short:   "Print the ABI of " + name + " as JSON",
+		Example: "  poke Token.sol abi > Token.abi.json",
+		Args:    cobra.ExactArgs(0),
+		Run: func(cmd *cobra.Command, args []string) {
+			var out bytes.Buffer
+			check(json.Indent(&out, []byte(abiJSON), "", "  "), "formatting ABI")
+			fmt.Println(out.String())
+		},
+	}
+}
+
+// describeArgs formats args like a Solidity parameter list, e.g. "address indexed from, uint256 value".
+func describeArgs(args abi.Arguments) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = arg.Type.String()
+		if arg.Indexed {
+			parts[i] += " indexed"
+		}
+		if arg.Name != "" {
+			parts[i] += " " + arg.Name
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseStateMutability reads the state mutability of each function in abiJSON, keyed by signature.
+// ABIs from before solc 0.4.16 only have the `constant` and `payable` fields, so it falls back to those.
+func parseStateMutability(abiJSON string) (map[string]string, error) {
+	var entries []struct {
+		Type            string
+		Name            string
+		Constant        bool
+		Payable         bool
+		StateMutability string
+		Inputs          []struct {
+			Type string
+		}
+	}
+	if err := json.Unmarshal([]byte(abiJSON), &entries); err != nil {
+		return nil, err
+	}
+	result := make(map[string]string)
+	for _, entry := range entries {
+		if entry.Type != "function" && entry.Type != "" {
+			continue
+		}
+		mutability := entry.StateMutability
+		if mutability == "" {
+			switch {
+			case entry.Constant:
+				mutability = "view"
+			case entry.Payable:
+				mutability = "payable"
+			default:
+				mutability = "nonpayable"
+			}
+		}
+		types := make([]string, len(entry.Inputs))
+		for i, input := range entry.Inputs {
+			types[i] = input.Type
+		}
+		result[entry.Name+"("+strings.Join(types, ",")+")"] = mutability
+	}
+	return result, nil
+}
+
 var addressCmd = &cobra.Command{
 	Use:     "address",
 	Short:   "Get the address corresponding to the `from` account",
 	Example: "  poke address\n  poke address -F @1",
 	Args:    cobra.ExactArgs(0),
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println(getAddress().Hex())
+		printResult(getAddress().Hex())
 	},
 }
 
@@ -544,7 +1353,7 @@ var showGasCmd = &cobra.Command{
 		ctx := context.Background()
 		gasPrice, err := getNode().SuggestGasPrice(ctx)
 		check(err, "retrieving gas price suggestion")
-		fmt.Println(gasPrice)
+		printResult(gasPrice.String())
 	},
 }
 
@@ -555,40 +1364,223 @@ var showWeiCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
 		address := parseAddress(args[0])
-		wei, err := getNode().BalanceAt(ctx, address, nil)
+		wei, err := getNode().BalanceAt(ctx, address, callBlock())
 		check(err, "retrieving wei balance")
-		fmt.Printf("%v wei\n", wei)
+		if jsonOutput() {
+			printJSON(map[string]interface{}{"wei": wei.String(), "eth": decimal.NewFromBigInt(wei, -18).String()})
+			return
+		}
+		fmt.Printf("%v wei (%v ETH)\n", wei, decimal.NewFromBigInt(wei, -18))
 	},
 }
 
 var sendWeiCmd = &cobra.Command{
 	Use:   "send-wei <address> <value>",
 	Short: "Send WEI (1e18 WEI = 1 ETH) to an address.",
-	Args:  cobra.ExactArgs(2),
+	Long: "Send WEI (1e18 WEI = 1 ETH) to an address.\n" +
+		"The value is in wei, unless it ends in a unit, wei, gwei, or eth, or a different unit is given with --value-unit.",
+	Example: "  poke send-wei @1 1e18\n" +
+		"  poke send-wei @1 1.5eth\n" +
+		"  poke send-wei @1 1.5 --value-unit ether",
+	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 		ctx := context.Background()
-		nonce, err := getNode().PendingNonceAt(ctx, getAddress())
-		check(err, "retrieving nonce")
+		nonce := getNonce(getAddress())
 		address := parseAddress(args[0])
-		attoTokens := parseUint256(args[1])
+		var attoTokens *big.Int
+		if match := amountRegexp.FindStringSubmatch(args[1]); match != nil && match[2] != "" {
+			if strings.HasPrefix(strings.ToLower(match[2]), "token") {
+				fatal("send-wei sends ETH, so its value can't be in tokens.")
+			}
+			attoTokens = truncateDecimal(parseDecimal(match[1]).Shift(unitDecimals(match[2])))
+		} else if isExplicitlySet("value-unit") {
+			attoTokens = parseValue(args[1])
+		} else {
+			attoTokens = parseUint256(args[1])
+		}
 		tx, err := getTxnOpts().Signer(
-			types.NewEIP155Signer(getNetID()),
 			getAddress(),
-			types.NewTransaction(
-				nonce,
-				address,
-				attoTokens,
-				21000,
-				getGasPrice(),
-				nil,
-			),
+			// Sending to a contract can take more than the usual 21000 gas.
+			newTransaction(nonce, &address, attoTokens, getGasLimit(ethereum.CallMsg{
+				From:  getAddress(),
+				To:    &address,
+				Value: attoTokens,
+			}), nil),
 		)
 		check(err, "signing transaction")
-		check(getNode().SendTransaction(ctx, tx), "sending transaction")
-		fmt.Printf("Sent %v WEI to %v.\n", attoTokens, address.Hex())
+		check(getTransactor().SendTransaction(ctx, tx), "sending transaction")
+		if jsonOutput() {
+			printJSON(map[string]interface{}{"transactionHash": tx.Hash().Hex(), "to": address.Hex(), "value": attoTokens.String()})
+			return
+		}
+		fmt.Printf("Sent %v WEI (%v ETH) to %v.\n", attoTokens, decimal.NewFromBigInt(attoTokens, -18), address.Hex())
 	},
 }
 
+// sendRawCmd broadcasts a transaction signed elsewhere, and decodes its events with theABI once it's mined.
+func sendRawCmd(theABI abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:     "send-raw <0xtx>",
+		Short:   "Broadcast a signed, RLP-encoded transaction, and wait for it to be mined",
+		Example: "  poke send-raw 0xf86b...",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			raw, err := hexutil.Decode(args[0])
+			check(err, "decoding transaction hex")
+			tx := new(types.Transaction)
+			check(tx.UnmarshalBinary(raw), "decoding RLP-encoded transaction")
+			checkReplayProtection(tx)
+			sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+			check(err, "recovering sender")
+			transactor{Client: getNode(), abi: &theABI}.approve(sender, tx)
+			err = getNode().SendTransaction(context.Background(), tx)
+			if err == nil {
+				fmt.Fprintln(humanOutput(), "Sent transaction "+tx.Hash().Hex())
+			}
+			log("transaction", tx, theABI, err)
+		},
+	}
+}
+
+var signMessageCmd = &cobra.Command{
+	Use:   "sign-message <text|0xhex>",
+	Short: "Sign a message with the `from` account, EIP-191 personal_sign style",
+	Long: "Signs a message with the `from` account, prefixed as personal_sign does. 0x-prefixed messages are hex-decoded first, " +
+		"to sign bytes, like a hash; anything else is signed as UTF-8 text. Check signatures with verify-sig.",
+	Example: "  poke sign-message 'hello world'\n  poke sign-message 'hello world' -F hardware\n  poke sign-message 0x1234",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sig := signMessage(parseMessage(args[0]))
+		if jsonOutput() {
+			printJSON(map[string]interface{}{
+				"r":         hexutil.Encode(sig[:32]),
+				"s":         hexutil.Encode(sig[32:64]),
+				"v":         sig[64],
+				"signature": hexutil.Encode(sig),
+			})
+			return
+		}
+		fmt.Printf("r: %v\n", hexutil.Encode(sig[:32]))
+		fmt.Printf("s: %v\n", hexutil.Encode(sig[32:64]))
+		fmt.Printf("v: %v\n", sig[64])
+		fmt.Printf("signature: %v\n", hexutil.Encode(sig))
+	},
+}
+
+var replaceCmd = &cobra.Command{
+	Use:   "replace <txhash>",
+	Short: "Replace a stuck pending transaction with one paying a higher gas price",
+	Long: "Resends a pending transaction from the `from` account with the same nonce, recipient, value, and data, " +
+		"but with a gas price raised by --fee-multiplier, or the --gasprice flag's price if that is higher still. " +
+		"For EIP-1559 transactions, both the max fee and the priority fee are raised, with --max-fee and --priority-fee as floors.",
+	Example: "  poke replace 0x1234...\n  poke replace 0x1234... -g 40",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		replaceTransaction(common.HexToHash(args[0]), false)
+	},
+}
+
+// replaceTransaction resends the pending transaction hash, from the `from` account, with higher fees.
+// If cancel is set, the replacement is an empty transfer to the `from` account instead, to cancel it.
+func replaceTransaction(hash common.Hash, cancel bool) {
+	ctx := context.Background()
+	old, isPending, err := getNode().TransactionByHash(ctx, hash)
+	check(err, "retrieving transaction "+hash.Hex())
+	if !isPending {
+		fatalf("transaction %v has already been mined, so it can't be replaced\n", hash.Hex())
+	}
+
+	from := getAddress()
+	sender, err := types.Sender(types.LatestSignerForChainID(old.ChainId()), old)
+	check(err, "recovering the sender of "+hash.Hex())
+	if sender != from {
+		fatalf("transaction %v was sent by %v, not by the `from` account %v\n", hash.Hex(), sender.Hex(), from.Hex())
+	}
+
+	to, value, gas, data, accessList := old.To(), old.Value(), old.Gas(), old.Data(), old.AccessList()
+	if cancel {
+		to, value, gas, data, accessList = &from, new(big.Int), 21000, nil, nil
+	}
+
+	// Nodes only accept a replacement with a sufficiently higher gas price, usually 10% higher.
+	// Dynamic-fee transactions need both their fee cap and their tip raised.
+	var replacement *types.Transaction
+	if old.Type() == types.DynamicFeeTxType {
+		feeCap, tipCap := bumpFee(old.GasFeeCap()), bumpFee(old.GasTipCap())
+		if isExplicitlySet("max-fee") {
+			feeCap = maxBig(feeCap, parseGwei(viper.GetString("max-fee")))
+		}
+		if isExplicitlySet("priority-fee") {
+			tipCap = maxBig(tipCap, parseGwei(viper.GetString("priority-fee")))
+		}
+		replacement = types.NewTx(&types.DynamicFeeTx{
+			ChainID:    old.ChainId(),
+			Nonce:      old.Nonce(),
+			GasTipCap:  tipCap,
+			GasFeeCap:  feeCap,
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: accessList,
+		})
+	} else {
+		gasPrice := bumpFee(old.GasPrice())
+		if viper.GetInt64("gasprice") != 0 {
+			gasPrice = maxBig(gasPrice, getGasPrice())
+		}
+		replacement = types.NewTx(&types.LegacyTx{
+			Nonce:    old.Nonce(),
+			GasPrice: gasPrice,
+			Gas:      gas,
+			To:       to,
+			Value:    value,
+			Data:     data,
+		})
+	}
+	replacement, err = getTxnOpts().Signer(
+		from, replacement)
+	check(err, "signing transaction")
+	check(getTransactor().SendTransaction(ctx, replacement), "sending transaction")
+	fmt.Fprintf(humanOutput(), "Sent replacement transaction %v with nonce %v and gas price %v wei.\n",
+		replacement.Hash().Hex(), replacement.Nonce(), replacement.GasFeeCap())
+	var receipt *types.Receipt
+	if !viper.GetBool("no-wait") {
+		receipt = waitMined("replacement", replacement)
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			fail(revertError, "transaction reverted")
+		}
+	}
+	if jsonOutput() {
+		printJSON(sentTxJSON(abi.ABI{}, replacement, receipt))
+	} else if receipt != nil {
+		fmt.Printf("Gas Used: %v\n", receipt.GasUsed)
+	}
+}
+
+// bumpFee raises a gas price or fee by --fee-multiplier, rounding up,
+// for nodes to accept a replacement transaction. They usually need at least 1.1.
+func bumpFee(fee *big.Int) *big.Int {
+	multiplier, err := decimal.NewFromString(viper.GetString("fee-multiplier"))
+	check(err, "parsing --fee-multiplier")
+	if multiplier.Cmp(decimal.New(1, 0)) <= 0 {
+		fatalf("--fee-multiplier must be more than 1, not %v\n", multiplier)
+	}
+	bumped, ok := new(big.Int).SetString(decimal.NewFromBigInt(fee, 0).Mul(multiplier).Ceil().String(), 10)
+	if !ok {
+		fatalf("can't raise the fee %v by %v\n", fee, multiplier)
+	}
+	return bumped
+}
+
+// maxBig returns the larger of a and b.
+func maxBig(a, b *big.Int) *big.Int {
+	if a.Cmp(b) < 0 {
+		return b
+	}
+	return a
+}
+
 var codeAtCmd = &cobra.Command{
 	Use:   "code-at <address>",
 	Short: "Get contract code at an address",
@@ -597,10 +1589,10 @@ var codeAtCmd = &cobra.Command{
 		code, err := getNode().CodeAt(
 			context.Background(),
 			parseAddress(args[0]),
-			nil,
+			callBlock(),
 		)
 		check(err, "retrieving code")
-		fmt.Println(hex.EncodeToString(code))
+		printResult(hex.EncodeToString(code))
 	},
 }
 
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/xerrors"
+)
+
+// placeholderRegexp matches the 40-character library placeholders solc leaves in bytecode.
+// Since solc 0.5.0 they look like __$<34 hex chars of the library's name hash>$__,
+// and before that like __<library name, padded with underscores>__.
+var placeholderRegexp = regexp.MustCompile(`__.{36}__`)
+
+// libraryPlaceholders returns every placeholder solc may use for the library
+// with the fully-qualified name fqName (e.g. "contracts/Math.sol:Math").
+func libraryPlaceholders(fqName string) []string {
+	hash := hex.EncodeToString(crypto.Keccak256([]byte(fqName)))
+	legacy := fqName
+	if len(legacy) > 36 {
+		legacy = legacy[:36]
+	}
+	return []string{
+		"__$" + hash[:34] + "$__",
+		"__" + legacy + strings.Repeat("_", 36-len(legacy)) + "__",
+	}
+}
+
+// linkBytecode substitutes library addresses into the hex-encoded bytecode bin,
+// and returns the decoded result. Each link is formatted "Name:0xaddress", where Name
+// is either a bare library name or the fully-qualified "path:Name". contracts lists the
+// fully-qualified names of all the contracts solc compiled alongside bin, which is how
+// bare library names get resolved to placeholders.
+func linkBytecode(bin string, contracts []string, links []string) ([]byte, error) {
+	for _, link := range links {
+		i := strings.LastIndex(link, ":")
+		if i == -1 || !common.IsHexAddress(link[i+1:]) {
+			return nil, xerrors.Errorf("invalid library link %q; expected Name:0xaddress", link)
+		}
+		library := link[:i]
+		address := strings.ToLower(common.HexToAddress(link[i+1:]).Hex()[2:])
+
+		// Very old versions of solc used the bare library name in placeholders.
+		placeholders := libraryPlaceholders(library)
+		for _, contract := range contracts {
+			if contract == library || strings.HasSuffix(contract, ":"+library) {
+				placeholders = append(placeholders, libraryPlaceholders(contract)...)
+			}
+		}
+		for _, placeholder := range placeholders {
+			bin = strings.ReplaceAll(bin, placeholder, address)
+		}
+	}
+
+	if unlinked := placeholderRegexp.FindAllString(bin, -1); len(unlinked) > 0 {
+		var names []string
+		seen := make(map[string]bool)
+		for _, placeholder := range unlinked {
+			name := placeholder
+			for _, contract := range contracts {
+				for _, p := range libraryPlaceholders(contract) {
+					if p == placeholder {
+						name = contract
+					}
+				}
+			}
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		return nil, xerrors.Errorf(
+			"the bytecode needs these libraries linked: %v. Link them with --link Name:0xaddress",
+			strings.Join(names, ", "),
+		)
+	}
+
+	bytecode, err := hex.DecodeString(bin)
+	if err != nil {
+		return nil, xerrors.Errorf("decoding bytecode: %w", err)
+	}
+	return bytecode, nil
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"bytes"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -14,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
@@ -26,26 +26,47 @@ import (
 func main() {
 	err := mainErr()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		fatal(err)
 	}
 }
 
 // cacheObject: the output of a compilation unit.
-// Given that we're not really using a cache, this is increasingly badly named.
+// The name predates the compilation cache, which stores solc's raw output instead. See cachedCompile.
 type cacheObject struct {
-	ABI      string
-	DevDoc   DevDoc
-	UserDoc  UserDoc
-	Name     string
-	Bytecode []byte
+	ABI     string
+	DevDoc  DevDoc
+	UserDoc UserDoc
+	Name    string
+
+	// Bin is the hex-encoded bytecode, which may still contain library placeholders.
+	// Contracts lists the fully-qualified names of every contract compiled alongside it,
+	// for resolving those placeholders.
+	Bin       string
+	Contracts []string
+
+	// EnumInputs lists the enum members of each method's inputs. See enumInputs.
+	EnumInputs map[string][][]string
+
+	// Metadata is solc's JSON metadata of the contract, if the compiler output includes it.
+	Metadata string
+
+	// StorageLayout is solc's JSON storage layout of the contract, if the compiler output includes it.
+	StorageLayout string
+
+	// BinRuntime is the hex-encoded code the contract deploys, if the compiler output includes it,
+	// to check the code at --address against.
+	BinRuntime string
 }
 
-var solTypes = map[string]struct {
+// solType describes how to parse arguments of a Solidity type from the command line,
+// and how to display results of that type.
+type solType struct {
 	parser   func(string) interface{}
 	toString func(interface{}) string
 	goType   func() interface{}
-}{
+}
+
+var solTypes = map[string]solType{
 	"address": {
 		parser: func(s string) interface{} {
 			return parseAddress(s)
@@ -68,17 +89,6 @@ var solTypes = map[string]struct {
 			return &[]common.Address{}
 		},
 	},
-	"uint256": {
-		parser: func(s string) interface{} {
-			return parseUint256(s)
-		},
-		toString: func(i interface{}) string {
-			return displayBigInt(*(i.(**big.Int)))
-		},
-		goType: func() interface{} {
-			return new(*big.Int)
-		},
-	},
 	"uint256[]": {
 		parser: func(s string) interface{} {
 			return parseUint256Array(s)
@@ -202,24 +212,32 @@ func mainErr() error {
 	if len(os.Args) == 2 && (os.Args[1] == "-license" || os.Args[1] == "--license") {
 		return printLicenses()
 	}
+	if len(os.Args) == 3 && os.Args[1] == "completion" {
+		return printCompletionScript(os.Args[2])
+	}
 
 	// Parse flags
 	contractName := pflag.StringP(
 		"contract",
 		"c",
 		"",
-		"Name of the contract to wrap. Optional if it matches the name of the .sol file.",
+		"Name of the contract to wrap. Optional if it matches the name of the .sol file. Use file:Name to pick between contracts with the same name.",
 	)
 	pflag.StringP(
 		"from",
 		"F",
 		defaultKeys[0],
-		"Hex-encoded private key to sign transactions with. Defaults to the 0th address in the 0x mnemonic. Use `hardware` to use Trezor/Ledger. ",
+		"Hex-encoded private key to sign transactions with. Defaults to the 0th address in the 0x mnemonic. Use `hardware` to use Trezor/Ledger. "+
+			"Use keystore:path to sign with an encrypted JSON keystore file, with its password from POKE_KEYSTORE_PASSWORD or a prompt. "+
+			"Use mnemonic:\"words...\", or just mnemonic to read them from POKE_MNEMONIC, to derive a key from a BIP 39 mnemonic along --derivation-path. "+
+			"Use awskms:<key ARN> or gcpkms:<key version resource name> to sign with a secp256k1 key in AWS KMS or Google Cloud KMS. "+
+			"Use walletconnect to sign with a mobile wallet, paired by scanning a QR code. "+
+			"With --offline or `tx build`, this may also be a plain address, to build an unsigned transaction.",
 	)
 	pflag.String(
 		"address",
 		"",
-		fmt.Sprintf("Address of a deployed copy of the contract."),
+		fmt.Sprintf("Address of a deployed copy of the contract, or its ENS name."),
 	)
 	pflag.StringP(
 		"node",
@@ -227,79 +245,530 @@ func mainErr() error {
 		"http://localhost:8545",
 		"URL of an Ethereum node",
 	)
+	pflag.String(
+		"network",
+		"",
+		fmt.Sprintf("Name of a network preset that sets the node URL and chain id. One of: %v", strings.Join(networkNames(), ", ")),
+	)
+	pflag.Int64(
+		"chain-id",
+		0,
+		"Chain id to sign transactions for. Defaults to the --network preset's, or else the node's. "+
+			"poke refuses to sign if the node's eth_chainId is different, so a transaction can't be sent to the wrong chain.",
+	)
 	pflag.IntP(
 		"gasprice",
 		"g",
 		0,
-		"Gas price to use, in gwei. Defaults to using go-ethereum default estimation algorithm.",
+		"Gas price to use, in gwei, for a legacy transaction. Defaults to using go-ethereum default estimation algorithm.",
+	)
+	pflag.Uint64(
+		"gas-limit",
+		0,
+		"Gas limit of transactions. Defaults to the node's estimate of the gas each transaction uses.",
+	)
+	pflag.Uint64(
+		"nonce",
+		0,
+		"Nonce of transactions, e.g. to replace a stuck one. Defaults to the account's next pending nonce.",
+	)
+	pflag.String(
+		"max-fee",
+		"",
+		"Maximum fee per gas of EIP-1559 transactions, in gwei. Defaults to twice the base fee plus the priority fee.",
+	)
+	pflag.String(
+		"priority-fee",
+		"",
+		"Priority fee (tip) per gas of EIP-1559 transactions, in gwei. Defaults to the median tip of recent blocks.",
+	)
+	pflag.Bool(
+		"legacy",
+		false,
+		"Send legacy transactions with a gas price, instead of EIP-1559 ones. Implied by --gasprice.",
+	)
+	pflag.Int(
+		"from-index",
+		0,
+		"Index of one of the default keys in the 0x mnemonic to send transactions from, instead of --from. Same as --from @N.",
 	)
 	pflag.String(
 		"derivation-path",
 		"m/44'/60'/0'/0/0",
-		"BIP 32 derivation path to use with hardware wallet. Only used if --from=hardware",
+		"BIP 32 derivation path to use with hardware wallet or mnemonic, or a shorthand like ledger-live:N or legacy:N for account N. Only used if --from is hardware or a mnemonic.",
+	)
+	pflag.Duration(
+		"wait-timeout",
+		5*time.Minute,
+		"How long to wait for a transaction to be mined, and confirmed, before giving up. Zero means wait indefinitely.",
+	)
+	pflag.Duration("timeout", 5*time.Minute, "")
+	pflag.CommandLine.MarkDeprecated("timeout", "use --wait-timeout instead")
+	pflag.Bool(
+		"confirm",
+		false,
+		"Show each transaction and ask for confirmation before sending it. This is the default on the --protected-chains.",
+	)
+	pflag.StringSlice(
+		"protected-chains",
+		[]string{"mainnet"},
+		"Chain ids, or network names, on which every transaction needs confirmation, unless --yes is given.",
+	)
+	pflag.String(
+		"max-value",
+		"",
+		"Most ETH the transactions of a run may send, in total. poke exits rather than send a transaction that would go over.",
+	)
+	pflag.String(
+		"max-cost",
+		"",
+		"Most ETH the gas of a run's transactions may cost, in total, at their max fees. poke exits rather than send a transaction that would go over.",
+	)
+	pflag.BoolP(
+		"yes",
+		"y",
+		false,
+		"Send transactions without asking for confirmation, even on the --protected-chains.",
+	)
+	pflag.Bool(
+		"dry-run",
+		false,
+		"Print the target, value, calldata, and estimated gas and cost of transactions, without sending them. Useful for proposing transactions to a multisig.",
+	)
+	pflag.Bool(
+		"no-wait",
+		false,
+		"Print the hash of each transaction once it's sent, without waiting for it to be mined.",
+	)
+	pflag.Int64(
+		"confirmations",
+		0,
+		"How many blocks to wait for on top of a transaction's own, once it's mined, before treating it as done.",
+	)
+	pflag.String(
+		"fee-multiplier",
+		"1.25",
+		"How much to raise the fees of a transaction by when replacing it, with replace, tx bump, or tx cancel. Nodes usually need at least 1.1.",
+	)
+	pflag.Bool(
+		"force",
+		false,
+		"Send transactions even if simulating them first shows they'd revert, and with send-raw and tx broadcast, even if they aren't replay-protected by EIP-155.",
+	)
+	pflag.Bool(
+		"offline",
+		false,
+		"Print transactions as RLP-encoded hex instead of sending them. Broadcast them later with the send-raw command.",
+	)
+	pflag.StringSlice(
+		"extra-abi",
+		nil,
+		"ABI file to decode the events of other contracts with, like those a transaction calls. Can be repeated. Common events, like ERC-20 transfers, are decoded without one.",
+	)
+	pflag.String(
+		"from-block",
+		"0",
+		"First block to search for events in, or for history to sample. history defaults to the contract's deployment block, from the deployments file.",
+	)
+	pflag.String(
+		"to-block",
+		"latest",
+		"Last block to search for events in, or \"latest\".",
+	)
+	pflag.StringSlice(
+		"topic",
+		nil,
+		"Only show events whose indexed argument has a value, as name=value. Can be repeated.",
+	)
+	pflag.Bool(
+		"no-cache",
+		false,
+		"Always run solc, rather than reusing the output of an earlier compilation of the same, unchanged sources.",
+	)
+	pflag.StringSlice(
+		"remap",
+		nil,
+		"Import remapping to compile with, as prefix=path, like @openzeppelin/=node_modules/@openzeppelin/. Can be repeated.",
+	)
+	pflag.StringSlice(
+		"allow-paths",
+		nil,
+		"Directory solc may import files from, beyond the source's own. Can be repeated.",
+	)
+	pflag.StringSlice(
+		"link",
+		nil,
+		"Library to link into the bytecode when deploying, as Name:0xaddress. Can be repeated.",
+	)
+	pflag.Bool(
+		"report-gas",
+		false,
+		"Print the gas used by each transaction when poke exits. Most useful with --script.",
+	)
+	pflag.String(
+		"gas-report-file",
+		"",
+		"CSV file to append the --report-gas table to.",
+	)
+	pflag.String(
+		"config",
+		"",
+		"Config file to read flag values from. Defaults to .poke.yaml in the working directory, if there is one.",
+	)
+	pflag.String(
+		"script",
+		"",
+		"File of poke commands to run one after another, sharing the node connection and parsed contract. Same as the run command, which describes the format.",
+	)
+	pflag.String(
+		"block",
+		"latest",
+		"Block to read the chain's state at in calls, balances, and code: a number, a hash, or latest, pending, earliest, safe, or finalized.",
+	)
+	pflag.StringSlice(
+		"override",
+		nil,
+		"Pretend an account's state is different in calls, like address:balance=1e18,code=0x...,storage[slot]=value. Can be repeated.",
+	)
+	pflag.Int64(
+		"step",
+		0,
+		"How many blocks apart history samples a view function. Defaults to a step that makes about 100 samples.",
+	)
+	pflag.Duration(
+		"interval",
+		15*time.Second,
+		"How often `watch` calls a view function.",
+	)
+	pflag.Bool(
+		"on-change",
+		false,
+		"Only print a view function's value when `watch` sees it change.",
+	)
+	pflag.Bool(
+		"multicall",
+		false,
+		"Take several sets of arguments to a view function, and make all the calls in one request through Multicall3.",
+	)
+	pflag.String(
+		"multicall-address",
+		"0xcA11bde05977b3631167028862bE2a173976CA11",
+		"Address of the Multicall3 contract, for --multicall and batch-call.",
+	)
+	pflag.Int(
+		"retries",
+		3,
+		"How many times to retry requests to the node that fail with a network error or rate limit. Transactions are never resent.",
+	)
+	pflag.String(
+		"access-list",
+		"",
+		"EIP-2930 access list to attach to transactions, as JSON like [{\"address\":\"0x...\",\"storageKeys\":[\"0x...\"]}].",
+	)
+	pflag.Bool(
+		"auto-access-list",
+		false,
+		"Attach the access list the node suggests with eth_createAccessList to each transaction.",
+	)
+	pflag.String(
+		"value",
+		"",
+		"Amount of ETH to send with transactions, such as calls to payable methods and deployments. In ether, unless set otherwise with --value-unit.",
+	)
+	pflag.String(
+		"value-unit",
+		"ether",
+		"Unit of --value and of send-wei's amount: wei, gwei, or ether (eth). send-wei's amount is in wei unless this is given explicitly, or it ends in a unit.",
+	)
+	pflag.String(
+		"decimals",
+		"",
+		"Decimals of the token, to take and show 256-bit integer arguments and results, and convert's token unit, in whole tokens. auto asks the contract's decimals().",
+	)
+	pflag.Bool(
+		"raw-amounts",
+		false,
+		"Take and show 256-bit integers as they are, even if --decimals is set, e.g. in the config file.",
+	)
+	pflag.StringP(
+		"output",
+		"o",
+		"text",
+		"Output format: \"text\" or \"json\". With json, commands print only their result to stdout, as one JSON object, and everything else to stderr.",
+	)
+	pflag.Bool(
+		"json",
+		false,
+		"Shorthand for --output json.",
+	)
+	pflag.Bool(
+		"verify",
+		false,
+		"After deploying, verify the contract's source on Etherscan. See the verify command.",
+	)
+	pflag.String(
+		"etherscan-api-key",
+		"",
+		"Etherscan API key, for verifying contracts.",
+	)
+	pflag.String(
+		"etherscan-url",
+		"https://api.etherscan.io/v2/api",
+		"URL of the Etherscan API, or of another explorer's Etherscan-compatible API.",
+	)
+	pflag.String(
+		"constructor-args",
+		"",
+		"Hex-encoded constructor arguments of the contract to verify. Defaults to those in the deployments file.",
+	)
+	pflag.String(
+		"solc-version",
+		"",
+		"Version of solc to compile .sol files with, like 0.8.24, downloading it if needed. \"auto\" picks the newest release the file's pragma allows. Defaults to the solc on the PATH.",
+	)
+	pflag.String(
+		"abi",
+		"",
+		"File holding just the contract's ABI, as a JSON array, to use instead of a .sol or compiler output file. Without bytecode, deploy isn't available.",
+	)
+	pflag.Bool(
+		"fetch-abi",
+		false,
+		"Download the ABI of the contract at --address from Etherscan, instead of reading a file. The first argument then just names the contract.",
+	)
+	pflag.Bool(
+		"via-proxy",
+		false,
+		"With --fetch-abi, if --address is a proxy, download its implementation's ABI, while still sending calls to the proxy.",
+	)
+	pflag.String(
+		"deployments",
+		"deployments.json",
+		"File recording the latest deployment of each contract on each network, to use when --address isn't given. Empty to not record deployments.",
+	)
+	pflag.String(
+		"export-file",
+		"",
+		"File to write export POKE_ADDRESS=... lines to after a deployment, for sourcing in a shell.",
+	)
+	pflag.Bool(
+		"4byte",
+		false,
+		"With decode, look up selectors that aren't in the ABI on 4byte.directory.",
+	)
+	pflag.String(
+		"safe",
+		"",
+		"Address of a Safe multisig to propose transactions to, signed by the from account, one of its owners, instead of sending them. See the safe command.",
+	)
+	pflag.String(
+		"safe-service-url",
+		"",
+		"URL of the Safe Transaction Service to propose Safe transactions to. Defaults to Safe's own service for the chain.",
+	)
+	pflag.String(
+		"keystore",
+		"",
+		"Directory for new-key and import-key to write encrypted keystore files to.",
+	)
+	pflag.String(
+		"walletconnect-project-id",
+		"",
+		"WalletConnect Cloud project ID, which the WalletConnect relay needs, for --from walletconnect.",
+	)
+	pflag.String(
+		"walletconnect-relay",
+		"wss://relay.walletconnect.com",
+		"WalletConnect relay to reach the wallet through, for --from walletconnect.",
+	)
+	pflag.String(
+		"ipfs-gateway",
+		"https://ipfs.io/ipfs/",
+		"IPFS gateway to fetch NFT metadata at ipfs:// URIs through.",
+	)
+	pflag.String(
+		"returns",
+		"",
+		"Comma-separated list of the types returned by a call-selector call, to decode its result.",
 	)
 	pflag.StringP(
 		"optimize-runs",
 		"r",
 		"1",
-		"Runs to optimize solc compilation for. ",
+		"Runs to optimize solc compilation for, or \"none\" to compile without the optimizer.",
 	)
 
+	// Negative numbers look like shorthand flags, so hide them from pflag and cobra.
+	// The completion scripts run `poke __complete <word...>` to complete the last word.
+	// Flags and their values are completed before parsing, which would fail on a flag missing its value.
+	var completing bool
+	var word string
+	if len(os.Args) > 2 && os.Args[1] == "__complete" {
+		completing = true
+		word = strings.TrimSpace(os.Args[len(os.Args)-1])
+		os.Args = append(os.Args[:1], os.Args[2:len(os.Args)-1]...)
+		if completions, ok := completeFlag(os.Args[1:], word); ok {
+			printCompletions(completions, word)
+			return nil
+		}
+	}
+	os.Args = escapeNegativeNumbers(os.Args)
 	pflag.Parse()
-	if len(pflag.Args()) == 0 {
-		fatal(`usage: poke <.sol file> [-c contract-name] [arg...]
+	// Bind flags now, rather than just before running the command, since compiling uses them too.
+	viper.SetEnvPrefix("poke")
+	viper.AutomaticEnv()
+	replacer := strings.NewReplacer("-", "_")
+	viper.SetEnvKeyReplacer(replacer)
+	viper.BindPFlags(pflag.CommandLine)
+	if err := readConfig(); err != nil {
+		return err
+	}
+	if completing {
+		if completions, ok := completeAlias(word); ok {
+			printCompletions(completions, word)
+			return nil
+		}
+	}
+	// With --abi, every argument is for the command; otherwise the first names the input file.
+	inputFile := viper.GetString("abi")
+	args := pflag.Args()
+	if inputFile == "" {
+		if len(args) == 0 && completing {
+			// Input files are left to the shell to complete.
+			var suites []string
+			for name := range builtinSuites {
+				suites = append(suites, name)
+			}
+			printCompletions(append(suites, "completion", "plan"), word)
+			return nil
+		}
+		if len(args) == 1 && args[0] == "completion" && completing {
+			var shells []string
+			for shell := range completionScripts {
+				shells = append(shells, shell)
+			}
+			printCompletions(shells, word)
+			return nil
+		}
+		if len(args) == 0 {
+			fail(usageError, `usage: poke <.sol file> [-c contract-name] [arg...]
+       poke --fetch-abi --address <address> <name> [arg...]
+       poke --abi <.abi.json file> [arg...]
+       poke <erc20|erc721|erc1155> <address> <command> [arg...]
+       poke plan apply <plan file>
+       poke completion <bash|zsh|fish|powershell>
 
 To see the licenses of libraries included in poke, run 'poke -license'`)
+		}
+		inputFile = args[0]
+		args = args[1:]
+	}
+	for i, arg := range args {
+		args[i] = unescapeNegativeNumber(arg)
+	}
+	if dash := pflag.CommandLine.ArgsLenAtDash(); dash != -1 {
+		// pflag drops the "--" that ends flag parsing, but cobra parses the args again,
+		// so put it back to let arguments like negative numbers through.
+		if dash > 0 && viper.GetString("abi") == "" {
+			dash--
+		}
+		args = append(append(append([]string{}, args[:dash]...), "--"), args[dash:]...)
+	}
+	args = protectNegativeNumbers(args, pflag.CommandLine)
+	if inputFile == "plan" && viper.GetString("abi") == "" {
+		// A plan names its own contracts, so there's no input file.
+		plan := planCmd()
+		args = append([]string{inputFile}, args...)
+		plan.SetArgs(args)
+		pflag.VisitAll(func(f *pflag.Flag) { plan.PersistentFlags().AddFlag(f) })
+		defer runExitFuncs()
+		if completing {
+			printCompletions(completeCommand(plan, args), word)
+			return nil
+		}
+		return withClass(usageError, plan.Execute())
 	}
-
-	inputFile := pflag.Arg(0)
-	args := pflag.Args()[1:]
 	defaultContractName := false
 
-	var bytes []byte
-
 	// Set contract name from filename, if needed
 	if *contractName == "" {
 		defaultContractName = true
-		*contractName = trimExtension(path.Base(inputFile))
+		*contractName = trimExtension(strings.TrimSuffix(path.Base(inputFile), ".abi.json"))
 	}
 
 	// Build or fetch EVM bytecode as needed
-	if strings.HasSuffix(inputFile, ".sol") {
+	var build *cacheObject
+	if viper.GetString("abi") != "" {
 		var err error
-		bytes, err = abigen(inputFile, *contractName)
+		build, err = readABIFile(inputFile, *contractName)
 		if err != nil {
-			return xerrors.Errorf("generating Go bindings to solidity ABI: %w", err)
+			return xerrors.Errorf("poke: %w", err)
 		}
-	} else if strings.HasSuffix(inputFile, ".json") {
+	} else if viper.GetBool("fetch-abi") {
+		// There's no file: inputFile just names the contract.
+		address := getContractAddress()
+		if implementation, kind, ok := proxyImplementation(address); ok {
+			if viper.GetBool("via-proxy") {
+				fmt.Fprintf(os.Stderr, "Using the ABI of %v, the implementation behind the proxy (%v).\n", implementation.Hex(), kind)
+				address = implementation
+			} else {
+				fmt.Fprintf(os.Stderr, "%v is a proxy for %v (%v). Pass --via-proxy to use the implementation's ABI.\n",
+					address.Hex(), implementation.Hex(), kind)
+			}
+		}
+		build = fetchABI(inputFile, address)
+	} else if suite, ok := builtinSuites[inputFile]; ok {
+		// There's no file: the contract's address comes first, then the command.
+		if len(args) == 0 && completing {
+			return nil
+		}
+		if len(args) == 0 {
+			failf(usageError, "usage: poke %v <address> <%v> [arg...]\n", inputFile, suite.commands)
+		}
+		viper.Set("address", args[0])
+		args = args[1:]
+		build = &cacheObject{ABI: suite.abi, Name: suite.contract}
+	} else {
 		var err error
-		bytes, err = openCombinedJson(inputFile, *contractName)
+		build, err = loadBuild(inputFile, *contractName, defaultContractName)
 		if err != nil {
-			return xerrors.Errorf("poke: %w", err)
+			return err
 		}
-	} else {
-		return xerrors.Errorf("\"%s\" expected to end with either \".sol\" or \".json\"", inputFile)
 	}
 
-	build, err := parseJsonBytecode(bytes, *contractName, inputFile, defaultContractName)
-
 	// Get and parse ABI
 	theABI, err := abi.JSON(strings.NewReader(build.ABI))
 	if err != nil {
 		return xerrors.Errorf("parsing ABI: %w", err)
 	}
+	enumInputs = build.EnumInputs
+	expectedRuntime = build.BinRuntime
 	devDoc := build.DevDoc
 	userDoc := build.UserDoc
 	name := build.Name
-	bytecode := build.Bytecode
+	registryContract = name
 	root := cobra.Command{
 		Use:   "poke",
 		Short: fmt.Sprintf("A command-line interface to interact with arbitrary smart contracts"),
 	}
 	var calls, transactions []*cobra.Command
-	for name, method := range theABI.Methods {
+	methods := theABI.Methods
+	if suite, ok := builtinSuites[inputFile]; ok {
+		// The suite's commands take the place of those for each function, e.g. to work in whole tokens.
+		calls, transactions = suite.cmds(theABI)
+		for _, cmd := range append(calls, transactions...) {
+			root.AddCommand(cmd)
+		}
+		methods = nil
+	}
+	overloads := make(map[string][]*cobra.Command)
+	for name, method := range methods {
 		name, method := name, method
-		parts := []string{name}
+		// go-ethereum names overloads like transfer, transfer0, transfer1; poke names them by signature.
+		parts := []string{method.RawName}
+		if isOverloaded(theABI, method.RawName) {
+			parts[0] = method.Sig
+		}
 		for _, input := range method.Inputs {
 			if input.Name == "" {
 				parts = append(parts, "<"+input.Type.String()+">")
@@ -309,8 +778,8 @@ To see the licenses of libraries included in poke, run 'poke -license'`)
 		}
 		var short, long string
 		{
-			dev := devDoc.Methods[method.Sig()].Details
-			user := userDoc.Methods[method.Sig()].Notice
+			dev := devDoc.Methods[method.Sig].Details
+			user := userDoc.Methods[method.Sig].Notice
 			short = dev
 			if short == "" {
 				short = strings.Split(user, "\n")[0]
@@ -323,45 +792,84 @@ To see the licenses of libraries included in poke, run 'poke -license'`)
 				}
 				long += dev
 			}
+			for i, members := range enumInputs[method.Sig] {
+				if members != nil {
+					if long != "" {
+						long += "\n\n"
+					}
+					long += enumUsage(method.Inputs[i].Name, members)
+				}
+			}
 		}
 		cmd := &cobra.Command{
 			Use:   strings.Join(parts, " "),
 			Short: short,
 			Long:  long,
-			Args:  cobra.ExactArgs(len(method.Inputs)),
-			// TODO: check if the deployed bytecode matches the compiled bytecode
-			//       if not, we might be pointing at a different contract, which
-			//       will by default print a non-helpful error message.
+			Args:  viewArgs(method),
 			Run: func(cmd *cobra.Command, args []string) {
-				inputs := make([]interface{}, len(args))
-				for i, arg := range args {
-					inputs[i] = solTypes[method.Inputs[i].Type.String()].parser(arg)
+				if method.IsConstant() && viper.GetBool("multicall") {
+					multicallView(theABI, method, args)
+					return
 				}
-				if method.Const {
-					outType := method.Outputs[0].Type.String()
-					out := solTypes[outType].goType()
+				inputs := parseArgs(method.Sig, method.Inputs, args)
+				if method.IsConstant() {
+					var outType solType
+					ok := false
+					if len(method.Outputs) == 1 {
+						outType, ok = lookupSolType(method.Outputs[0].Type.String())
+					}
+					if !ok {
+						// Types like structs don't have a solType, and neither do methods with
+						// several outputs or none, so decode those generically.
+						// displayValue labels struct fields with their names from the ABI.
+						var results []interface{}
+						err := getDeployment(theABI).Call(callOpts(), &results, name, inputs...)
+						check(explainRevert(theABI, err), "calling "+name)
+						printOutputs(method.Outputs, results)
+						return
+					}
+					out := outType.goType()
 
-					// TODO: handle tuple outputs / multiple outputs?
-					// TODO: handle no outputs
 					err := getDeployment(theABI).Call(
-						nil,
-						out,
+						callOpts(),
+						&[]interface{}{out},
 						name,
 						inputs...,
 					)
-					check(err, "calling "+name)
-					fmt.Println(solTypes[outType].toString(out))
+					check(explainRevert(theABI, err), "calling "+name)
+					printResult(outType.toString(out))
 				} else {
+					checkPayable(method, method.Sig)
+					// A different contract at --address would fail in confusing ways, so warn about it first.
+					checkBytecode(build.Name)
 					tx, err := getDeployment(theABI).Transact(
 						getTxnOpts(),
 						name,
 						inputs...,
 					)
-					log(name+"()", tx, theABI, err)
+					log(method.RawName+"()", tx, theABI, err)
 				}
 			},
 		}
-		if method.Const {
+		if method.IsConstant() {
+			calls = append(calls, cmd)
+		} else {
+			transactions = append(transactions, cmd)
+		}
+		root.AddCommand(cmd)
+		if parts[0] == method.Sig {
+			overloads[method.RawName] = append(overloads[method.RawName], cmd)
+		}
+	}
+	for rawName, cmds := range overloads {
+		cmd := overloadCmd(rawName, cmds)
+		constant := true
+		for _, method := range theABI.Methods {
+			if method.RawName == rawName && !method.IsConstant() {
+				constant = false
+			}
+		}
+		if constant {
 			calls = append(calls, cmd)
 		} else {
 			transactions = append(transactions, cmd)
@@ -373,8 +881,53 @@ To see the licenses of libraries included in poke, run 'poke -license'`)
 		sendWeiCmd,
 		addressCmd,
 		showGasCmd,
-		deployCmd(name, theABI, bytecode),
+		describeCmd(name, theABI, build.ABI, devDoc, userDoc),
+		abiCmd(name, build.ABI),
+		watchCmd(theABI),
+		eventsCmd(theABI),
+		batchCallCmd(theABI),
+		historyCmd(theABI),
+		encodeCmd(theABI),
+		decodeCmd(theABI),
+		keccakCmd,
+		convertCmd,
+		selectorCmd(theABI),
+		topicCmd(theABI),
+		decodeTxCmd(theABI),
+		traceCmd(theABI),
+		traceCallCmd(theABI),
+		callSelectorCmd,
+		sendSelectorCmd(theABI),
 		codeAtCmd,
+		storageAtCmd,
+		proxyCmd,
+		verifyBytecodeCmd(name),
+		storageCmd(build.StorageLayout),
+		sendRawCmd(theABI),
+		txCmd(theABI),
+		safeCmd(theABI),
+		newKeyCmd,
+		importKeyCmd,
+		exportKeyCmd,
+		signMessageCmd,
+		verifySigCmd,
+		ecrecoverCmd,
+		replaceCmd,
+		hwAccountsCmd,
+		hwVerifyCmd,
+		ensCmd,
+		ensReverseCmd,
+		runCmd(&root),
+	}
+	// Without bytecode, as with a fetched ABI, there's nothing to deploy or verify.
+	if build.Bin != "" {
+		utilities = append(utilities,
+			deployCmd(name, theABI, build.Bin, build.Contracts, build.Metadata),
+			verifyCmd(name, build.Metadata),
+		)
+	}
+	if _, ok := theABI.Methods["balanceOf"]; ok {
+		utilities = append(utilities, tokenBalanceCmd(theABI))
 	}
 	root.AddCommand(utilities...)
 	type cmdBlock struct {
@@ -405,15 +958,22 @@ To see the licenses of libraries included in poke, run 'poke -license'`)
 	})
 	root.SetUsageTemplate(usageTemplate)
 	root.SetArgs(args)
-	viper.SetEnvPrefix("poke")
-	viper.AutomaticEnv()
-	replacer := strings.NewReplacer("-", "_")
-	viper.SetEnvKeyReplacer(replacer)
 	pflag.VisitAll(func(f *pflag.Flag) { root.PersistentFlags().AddFlag(f) })
-	viper.BindPFlags(root.PersistentFlags())
 	defer runExitFuncs()
 
-	return root.Execute()
+	if completing {
+		printCompletions(completeCommand(&root, args), word)
+		return nil
+	}
+	if script := viper.GetString("script"); script != "" {
+		if len(args) > 0 {
+			failf(usageError, "I can't run both a script and the command %q\n", strings.Join(args, " "))
+		}
+		// Commands exit themselves when they fail, so the errors that get here are in the script itself.
+		return withClass(usageError, runScript(&root, script))
+	}
+	// Likewise, cobra's errors are of unknown commands, flags, and numbers of arguments.
+	return withClass(usageError, root.Execute())
 }
 
 // DevDoc is parsed @dev documentatation.
@@ -443,20 +1003,73 @@ func openCombinedJson(jsonFile, contractName string) ([]byte, error) {
 
 // abigen compiles the given Solidity file in workDir and returns the compiled bytecode.
 func abigen(solFile, contractName string) ([]byte, error) {
-	cmd := exec.Command(
-		"solc",
-		"--optimize",
-		"--allow-paths", "*,",
-		"--optimize-runs", getOptimizeRuns(), // performance tradeoff here
-		"--combined-json", "abi,bin,userdoc,devdoc",
-		solFile,
-	)
-	cmd.Stderr = os.Stderr
-	compiled, err := cmd.Output()
+	var allowPaths, remappings []string
+	// Inside a Foundry project, resolve imports the way forge would.
+	if root := findFoundryRoot(solFile); root != "" {
+		allowPaths = append(allowPaths, root)
+		remappings = foundryRemappings(root)
+	}
+	args := []string{"--allow-paths", solcAllowPaths(allowPaths...)}
+	if runs := getOptimizeRuns(); runs != "none" {
+		args = append(args, "--optimize", "--optimize-runs", runs) // performance tradeoff here
+	}
+	// --remap comes last, so it overrides the project's remappings.
+	args = append(args, remappings...)
+	args = append(args, viper.GetStringSlice("remap")...)
+	solc := solcCommand(solFile)
+	outputs := "abi,ast,bin,bin-runtime,userdoc,devdoc,metadata"
+	// Older versions of solc reject storage-layout, which only the storage command needs.
+	if version, ok := solcBinaryVersion(solc); ok && compareVersions(version, [3]int{0, 5, 13}) >= 0 {
+		outputs += ",storage-layout"
+	}
+	args = append(args, "--combined-json", outputs, solFile)
+	return cachedCompile(solc, args, func() ([]byte, error) {
+		cmd := exec.Command(solc, args...)
+		cmd.Stderr = os.Stderr
+		compiled, err := cmd.Output()
+		if err != nil {
+			return nil, xerrors.Errorf("solc: %w", err)
+		}
+		return compiled, nil
+	})
+}
+
+// loadBuild compiles the contract contractName in inputFile, a .sol file or a standard-json input file,
+// or reads it from inputFile, if that's the compiler's output. defaultContractName is set if contractName
+// was taken from the file's name, rather than given, and so might not be the contract the file is for.
+func loadBuild(inputFile, contractName string, defaultContractName bool) (*cacheObject, error) {
+	var bytes []byte
+	if strings.HasSuffix(inputFile, ".sol") {
+		var err error
+		restore := failingAs(compileError)
+		bytes, err = abigen(inputFile, contractName)
+		restore()
+		if err != nil {
+			return nil, withClass(compileError, xerrors.Errorf("generating Go bindings to solidity ABI: %w", err))
+		}
+	} else if strings.HasSuffix(inputFile, ".json") {
+		var err error
+		bytes, err = openCombinedJson(inputFile, contractName)
+		if err != nil {
+			return nil, xerrors.Errorf("poke: %w", err)
+		}
+		if isCompilerInput(bytes) {
+			restore := failingAs(compileError)
+			bytes, err = compileStandardJSON(inputFile, bytes)
+			restore()
+			if err != nil {
+				return nil, withClass(compileError, xerrors.Errorf("compiling standard-json input: %w", err))
+			}
+		}
+	} else {
+		return nil, xerrors.Errorf("\"%s\" expected to end with either \".sol\" or \".json\"", inputFile)
+	}
+
+	build, err := parseJsonBytecode(bytes, contractName, inputFile, defaultContractName)
 	if err != nil {
-		return nil, xerrors.Errorf("solc: %w", err)
+		return nil, withClass(compileError, xerrors.Errorf("reading compiler output: %w", err))
 	}
-	return compiled, nil
+	return build, nil
 }
 
 // trimExtension returns the filename with its filename extension trimmed away.
@@ -464,36 +1077,90 @@ func trimExtension(filename string) string {
 	return strings.TrimSuffix(filename, path.Ext(filename))
 }
 
+// parseDocs parses solc's devdoc and userdoc output.
+// Either may be empty, as not every format poke reads includes them.
+func parseDocs(devDocJSON, userDocJSON []byte) (DevDoc, UserDoc, error) {
+	var devDoc DevDoc
+	if len(devDocJSON) > 0 {
+		err := json.Unmarshal(devDocJSON, &devDoc)
+		if err != nil {
+			return DevDoc{}, UserDoc{}, xerrors.Errorf("unmarshaling devdoc: %w", err)
+		}
+	}
+
+	userDoc := UserDoc{Methods: make(map[string]notice)}
+	if len(userDocJSON) > 0 {
+		// Older versions of solc output a different type for the user docs for the constructor
+		// than they do for any other method.
+		// Most of the following is there to deal with that fact.
+		var tmp struct {
+			Methods map[string]interface{}
+		}
+		err := json.Unmarshal(userDocJSON, &tmp)
+		if err != nil {
+			return DevDoc{}, UserDoc{}, xerrors.Errorf("unmarshaling userdoc: %w", err)
+		}
+		for name, methodInfo := range tmp.Methods {
+			switch methodInfo := methodInfo.(type) {
+			case string:
+				userDoc.Methods[name] = notice{methodInfo}
+			case map[string]interface{}:
+				text, _ := methodInfo["notice"].(string)
+				userDoc.Methods[name] = notice{text}
+			}
+		}
+	}
+	return devDoc, userDoc, nil
+}
+
 // parseJsonBytecode takes compiled bytes, as the bytestream output by
-// `solc --combined-json abi,bin,userdoc,devdoc` and formats it as a cacheObject.
-// contractName: the name of the contract to grab the cached object from
+// `solc --combined-json abi,ast,bin,bin-runtime,userdoc,devdoc,metadata,storage-layout` and formats it as a cacheObject.
+// (solc before 0.5.13 can't output the storage layout, so poke doesn't ask those versions for it.)
+// The ast is optional, and only used to look up enum member names.
+// The metadata is optional too, and only used to verify the contract's source.
+// So are the storage layout, which the storage command uses, and bin-runtime,
+// which verify-bytecode compares the code at --address to.
+// contractName: the name of the contract to grab the cached object from,
+// optionally qualified by its file, as in "contracts/Token.sol:Token"
 func parseJsonBytecode(compiled []byte, contractName string, inputFile string, defaultContractName bool) (*cacheObject, error) {
-	type CompilerOutput struct {
-		ABI     string
-		Bin     string
-		UserDoc string
-		DevDoc  string
+	if isBuildInfo(compiled) {
+		var err error
+		compiled, err = buildInfoOutput(compiled)
+		if err != nil {
+			return nil, err
+		}
 	}
-	var parsed struct {
-		Contracts map[string]CompilerOutput
+	if isArtifact(compiled) {
+		return parseArtifact(compiled, contractName, inputFile, defaultContractName)
 	}
-	err := json.NewDecoder(bytes.NewBuffer(compiled)).Decode(&parsed)
-	if err != nil {
-		fmt.Printf("error %v", err)
 
-		return nil, xerrors.Errorf("failed to decode solc output: %w", err)
+	var parsed combinedJSON
+	if isStandardJSON(compiled) {
+		var err error
+		parsed, err = parseStandardJSON(compiled)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		err := json.NewDecoder(bytes.NewBuffer(compiled)).Decode(&parsed)
+		if err != nil {
+			fmt.Printf("error %v", err)
+
+			return nil, xerrors.Errorf("failed to decode solc output: %w", err)
+		}
 	}
 	inputFile = path.Base(inputFile)
 
 	// choose which contract we care about
-	var compilerOutput CompilerOutput
+	var compilerOutput contractOutput
 	{
-		var compilerOutputs []CompilerOutput
+		var compilerOutputs []contractOutput
+		var candidates []string
 
 		for fileColonContractName := range parsed.Contracts {
-			nameParts := strings.Split(fileColonContractName, ":")
-			if trimExtension(nameParts[1]) == contractName {
+			if matchesContract(fileColonContractName, contractName) {
 				compilerOutputs = append(compilerOutputs, parsed.Contracts[fileColonContractName])
+				candidates = append(candidates, fileColonContractName)
 			}
 		}
 		if len(compilerOutputs) == 0 {
@@ -507,57 +1174,78 @@ func parseJsonBytecode(compiled []byte, contractName string, inputFile string, d
 			fatalf(errStr)
 		}
 		if len(compilerOutputs) > 1 {
+			sort.Strings(candidates)
 			fatalf(
-				"I got unexpected output from solc that I do not know how to handle.\n"+
-					"The solc output contained %v results for the %v contract in %v, and I do "+
-					"not know which one to choose.\n",
+				"The compiler output in %v contains %v contracts named %v, and I do not know which one to choose:\n"+
+					"  %v\n"+
+					"Pick one by passing its file too, like --contract %v\n",
+				inputFile,
 				len(compilerOutputs),
 				contractName,
-				inputFile,
+				strings.Join(candidates, "\n  "),
+				candidates[0],
 			)
 		}
 		compilerOutput = compilerOutputs[0]
 	}
 
-	var devDoc DevDoc
-	err = json.Unmarshal([]byte(compilerOutput.DevDoc), &devDoc)
+	devDocJSON, userDocJSON := []byte(compilerOutput.DevDoc), []byte(compilerOutput.UserDoc)
+	if len(devDocJSON) == 0 && len(userDocJSON) == 0 && compilerOutput.Metadata != "" {
+		// Hardhat doesn't ask solc for the docs, so build-info files only have them inside the metadata.
+		var metadata artifactMetadata
+		if json.Unmarshal([]byte(compilerOutput.Metadata), &metadata) == nil {
+			devDocJSON, userDocJSON = metadata.Output.DevDoc, metadata.Output.UserDoc
+		}
+	}
+	devDoc, userDoc, err := parseDocs(devDocJSON, userDocJSON)
 	if err != nil {
-		return nil, xerrors.Errorf("unmarshaling devdoc: %w", err)
+		return nil, err
 	}
 
-	var userDoc UserDoc
-	{
-		userDoc.Methods = make(map[string]notice)
-		// solc outputs a different type for the user docs for the constructor than it does for any other method.
-		// Most of the following is there to deal with that fact.
-		var tmp struct {
-			Methods map[string]interface{}
-		}
-		err = json.Unmarshal([]byte(compilerOutput.UserDoc), &tmp)
+	enums := make(map[string][]string)
+	for _, source := range parsed.Sources {
+		err := parseEnumDefinitions(source.AST, enums)
 		if err != nil {
-			return nil, xerrors.Errorf("unmarshaling userdoc: %w", err)
-		}
-		for name, methodInfo := range tmp.Methods {
-			if name == "constructor" {
-				userDoc.Methods[name] = notice{methodInfo.(string)}
-			} else {
-				userDoc.Methods[name] = notice{
-					methodInfo.(map[string]interface{})["notice"].(string),
-				}
-			}
+			return nil, xerrors.Errorf("reading enums from AST: %w", err)
 		}
 	}
-
-	bytecode, err := hex.DecodeString(compilerOutput.Bin)
+	enumInputs, err := parseEnumInputs(compilerOutput.ABI, enums)
 	if err != nil {
-		return nil, xerrors.Errorf("decoding bytecode: %w", err)
+		return nil, xerrors.Errorf("reading enum inputs from ABI: %w", err)
+	}
+
+	var contracts []string
+	for fileColonContractName := range parsed.Contracts {
+		contracts = append(contracts, fileColonContractName)
 	}
 
 	return &cacheObject{
-		ABI:      compilerOutput.ABI,
-		DevDoc:   devDoc,
-		UserDoc:  userDoc,
-		Name:     contractName,
-		Bytecode: bytecode,
+		ABI:       compilerOutput.ABI,
+		DevDoc:    devDoc,
+		UserDoc:   userDoc,
+		Name:      contractName[strings.LastIndex(contractName, ":")+1:],
+		Bin:       compilerOutput.Bin,
+		Contracts: contracts,
+
+		EnumInputs:    enumInputs,
+		Metadata:      compilerOutput.Metadata,
+		StorageLayout: compilerOutput.StorageLayout,
+		BinRuntime:    compilerOutput.BinRuntime,
 	}, err
 }
+
+// matchesContract reports whether fileColonContractName, a contract's key in solc's output,
+// names the contract contractName. contractName may be qualified by all or the end of the
+// contract's file path, like "Token.sol:Token", to tell apart contracts with the same name.
+func matchesContract(fileColonContractName, contractName string) bool {
+	colon := strings.LastIndex(fileColonContractName, ":")
+	file, name := fileColonContractName[:colon], fileColonContractName[colon+1:]
+	if colon := strings.LastIndex(contractName, ":"); colon != -1 {
+		wantFile := contractName[:colon]
+		if file != wantFile && !strings.HasSuffix(file, "/"+wantFile) {
+			return false
+		}
+		contractName = contractName[colon+1:]
+	}
+	return trimExtension(name) == contractName
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// mnemonicPrefix marks a --from value as a BIP 39 mnemonic, as in mnemonic:"word word ...".
+// Plain "mnemonic" reads the words from the POKE_MNEMONIC environment variable instead.
+const mnemonicPrefix = "mnemonic"
+
+// isMnemonic reports whether from is a mnemonic, as accepted by deriveMnemonicKey.
+func isMnemonic(from string) bool {
+	return from == mnemonicPrefix || strings.HasPrefix(from, mnemonicPrefix+":")
+}
+
+// deriveMnemonicKey derives the key at --derivation-path from the BIP 39 mnemonic in from,
+// which is either mnemonic:"word word ..." or plain mnemonic to use POKE_MNEMONIC.
+func deriveMnemonicKey(from string) *ecdsa.PrivateKey {
+	words := strings.TrimPrefix(strings.TrimPrefix(from, mnemonicPrefix), ":")
+	if from == mnemonicPrefix {
+		words = os.Getenv("POKE_MNEMONIC")
+		if words == "" {
+			fatal("`from` is set to \"mnemonic\", but the POKE_MNEMONIC environment variable is empty")
+		}
+	}
+	seed, err := bip39.NewSeedWithErrorChecking(strings.Join(strings.Fields(words), " "), "")
+	check(err, "parsing mnemonic")
+
+	// BIP 32: the master key and chain code come from the seed, then each child from its parent.
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := new(big.Int).SetBytes(sum[:32]), sum[32:]
+	for _, index := range getDerivationPath("a mnemonic") {
+		var data []byte
+		if index >= 0x80000000 {
+			// Hardened children are derived from the private key,
+			data = append([]byte{0}, math.PaddedBigBytes(key, 32)...)
+		} else {
+			// and normal ones from the compressed public key.
+			parent, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+			check(err, "deriving key from mnemonic")
+			data = crypto.CompressPubkey(&parent.PublicKey)
+		}
+		var indexBytes [4]byte
+		binary.BigEndian.PutUint32(indexBytes[:], index)
+		data = append(data, indexBytes[:]...)
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		key.Add(key, new(big.Int).SetBytes(sum[:32]))
+		key.Mod(key, crypto.S256().Params().N)
+		chainCode = sum[32:]
+	}
+	privateKey, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+	check(err, "deriving key from mnemonic")
+	return privateKey
+}
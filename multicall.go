@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+// multicall3ABI declares Multicall3's aggregate3, which makes a batch of calls and reports how each went.
+const multicall3ABI = `[
+	{"type":"function","name":"aggregate3","stateMutability":"payable",
+	 "inputs":[{"name":"calls","type":"tuple[]","components":[
+		{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}]}],
+	 "outputs":[{"name":"returnData","type":"tuple[]","components":[
+		{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}]}]}
+]`
+
+// multicallCall and multicallResult mirror the structs aggregate3 takes and returns.
+type multicallCall struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+type multicallResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// viewCall is a call to a view function, to make in a batch with multicall.
+type viewCall struct {
+	target common.Address
+	abi    abi.ABI
+	method abi.Method
+	args   []interface{}
+}
+
+// multicall makes calls with a single eth_call, through the Multicall3 contract at --multicall-address,
+// and returns each one's decoded results, or why it failed. Like other calls, it reads the state at
+// --block, with the --override state overrides.
+func multicall(calls []viewCall) ([][]interface{}, []error) {
+	parsed, err := abi.JSON(strings.NewReader(multicall3ABI))
+	check(err, "parsing Multicall3 ABI")
+	packed := make([]multicallCall, len(calls))
+	for i, call := range calls {
+		data, err := call.abi.Pack(call.method.Name, call.args...)
+		check(err, "encoding call to "+call.method.Sig)
+		packed[i] = multicallCall{Target: call.target, AllowFailure: true, CallData: data}
+	}
+	data, err := parsed.Pack("aggregate3", packed)
+	check(err, "encoding multicall")
+
+	ctx := context.Background()
+	multicallAddress := parseAddress(viper.GetString("multicall-address"))
+	code, err := caller{getNode()}.CodeAt(ctx, multicallAddress, callBlock())
+	check(err, "looking up the Multicall3 contract")
+	if len(code) == 0 && getOverrides()[multicallAddress] == nil {
+		fatalf("There's no Multicall3 contract at %v on this chain. Give its address with --multicall-address.\n", multicallAddress.Hex())
+	}
+	output, err := caller{getNode()}.CallContract(ctx, ethereum.CallMsg{To: &multicallAddress, Data: data}, callBlock())
+	check(err, "calling Multicall3")
+	unpacked, err := parsed.Unpack("aggregate3", output)
+	check(err, "decoding multicall results")
+	results := *abi.ConvertType(unpacked[0], new([]multicallResult)).(*[]multicallResult)
+
+	values := make([][]interface{}, len(calls))
+	errs := make([]error, len(calls))
+	for i, call := range calls {
+		if !results[i].Success {
+			if len(results[i].ReturnData) == 0 {
+				errs[i] = xerrors.New("execution reverted")
+			} else {
+				errs[i] = xerrors.New("execution reverted: " + decodeRevert(call.abi, results[i].ReturnData))
+			}
+			continue
+		}
+		values[i], errs[i] = call.method.Outputs.Unpack(results[i].ReturnData)
+	}
+	return values, errs
+}
+
+// printMulticall prints the results of calls, as multicall returned them, in the same form poke prints
+// each call's results in on its own. Failed calls print their error instead, so each call still has its place.
+func printMulticall(calls []viewCall, values [][]interface{}, errs []error, labels []string) {
+	if jsonOutput() {
+		type result struct {
+			Call   string            `json:"call"`
+			Result map[string]string `json:"result,omitempty"`
+			Error  string            `json:"error,omitempty"`
+		}
+		results := make([]result, len(calls))
+		for i, call := range calls {
+			results[i].Call = labels[i]
+			if errs[i] != nil {
+				results[i].Error = errs[i].Error()
+				continue
+			}
+			results[i].Result = make(map[string]string)
+			for j, output := range call.method.Outputs {
+				name := output.Name
+				if name == "" {
+					name = fmt.Sprint(j)
+				}
+				results[i].Result[name] = displayValue(output.Type, values[i][j])
+			}
+		}
+		printJSON(results)
+		return
+	}
+	for i, call := range calls {
+		if len(call.method.Outputs) != 1 {
+			fmt.Printf("%v:\n", labels[i])
+		}
+		if errs[i] != nil {
+			fmt.Printf("Error: %v\n", errs[i])
+			continue
+		}
+		printOutputs(call.method.Outputs, values[i])
+	}
+}
+
+// viewArgs validates the arguments to method's command: exactly one per input, or, for a view function
+// with --multicall, any number of sets of them.
+func viewArgs(method abi.Method) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		inputs := len(method.Inputs)
+		if !method.IsConstant() || !viper.GetBool("multicall") || inputs == 0 {
+			return cobra.ExactArgs(inputs)(cmd, args)
+		}
+		if len(args) == 0 || len(args)%inputs != 0 {
+			return xerrors.Errorf("with --multicall, %v takes sets of %v arguments, but got %v", method.Sig, inputs, len(args))
+		}
+		return nil
+	}
+}
+
+// multicallView calls method once for each set of arguments in args, all in one multicall.
+func multicallView(theABI abi.ABI, method abi.Method, args []string) {
+	inputs := len(method.Inputs)
+	if inputs == 0 {
+		fatalf("%v takes no arguments, so --multicall has nothing to repeat it with\n", method.Sig)
+	}
+	var calls []viewCall
+	var labels []string
+	for i := 0; i < len(args); i += inputs {
+		set := args[i : i+inputs]
+		calls = append(calls, viewCall{getContractAddress(), theABI, method, parseArgs(method.Sig, method.Inputs, set)})
+		labels = append(labels, method.RawName+" "+strings.Join(set, " "))
+	}
+	values, errs := multicall(calls)
+	printMulticall(calls, values, errs, labels)
+}
+
+// lookupMethod finds the function named name, or with the signature name, in theABI.
+func lookupMethod(theABI abi.ABI, name string) (abi.Method, error) {
+	if method, ok := theABI.Methods[name]; ok {
+		return method, nil
+	}
+	for _, method := range theABI.Methods {
+		if method.Sig == name {
+			return method, nil
+		}
+	}
+	return abi.Method{}, xerrors.Errorf("the contract has no function %q", name)
+}
+
+// lookupViewMethod finds the view function named name, or with the signature name, in theABI.
+func lookupViewMethod(theABI abi.ABI, name string) (abi.Method, error) {
+	method, err := lookupMethod(theABI, name)
+	if err != nil {
+		return abi.Method{}, err
+	}
+	if !method.IsConstant() {
+		return abi.Method{}, xerrors.Errorf("%v isn't a view function, so it can't be batched", method.Sig)
+	}
+	return method, nil
+}
+
+// batchCallCmd reads view calls from a file, one per line, and makes them all with one multicall.
+func batchCallCmd(theABI abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "batch-call <file>",
+		Short: "Make the view calls in a file, one per line, in a single request through Multicall3",
+		Long: "Reads calls like `balanceOf @1`, one per line, and makes them all in one eth_call through Multicall3.\n" +
+			"As in scripts, lines starting with # are comments, and `use <address>` sends later calls to another contract with the same ABI.",
+		Example: "  poke Token.sol batch-call holders.txt",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			f, err := os.Open(args[0])
+			check(err, "opening batch file")
+			defer f.Close()
+
+			var calls []viewCall
+			var labels []string
+			var target *common.Address
+			scanner := bufio.NewScanner(f)
+			for lineNumber := 1; scanner.Scan(); lineNumber++ {
+				line := strings.TrimSpace(scanner.Text())
+				if line == "" || strings.HasPrefix(line, "#") {
+					continue
+				}
+				words, err := splitScriptLine(line)
+				check(err, fmt.Sprintf("%v:%v", args[0], lineNumber))
+				if words[0] == "use" {
+					if len(words) != 2 {
+						fatalf("%v:%v: use takes exactly one address\n", args[0], lineNumber)
+					}
+					address := parseAddress(words[1])
+					target = &address
+					continue
+				}
+				method, err := lookupViewMethod(theABI, words[0])
+				check(err, fmt.Sprintf("%v:%v", args[0], lineNumber))
+				if len(words)-1 != len(method.Inputs) {
+					fatalf("%v:%v: %v takes %v arguments, but got %v\n", args[0], lineNumber, method.Sig, len(method.Inputs), len(words)-1)
+				}
+				if target == nil {
+					address := getContractAddress()
+					target = &address
+				}
+				calls = append(calls, viewCall{*target, theABI, method, parseArgs(method.Sig, method.Inputs, words[1:])})
+				labels = append(labels, line)
+			}
+			check(scanner.Err(), "reading batch file")
+			if len(calls) == 0 {
+				return
+			}
+			values, errs := multicall(calls)
+			printMulticall(calls, values, errs, labels)
+		},
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+type networkPreset struct {
+	Node    string
+	ChainID int64
+}
+
+// networks are the built-in presets for the --network flag.
+// A preset's node URL and chain id can be overridden with the
+// `<network>-node` and `<network>-chain-id` settings, e.g. the
+// POKE_MAINNET_NODE environment variable.
+var networks = map[string]networkPreset{
+	"mainnet":          {"https://ethereum-rpc.publicnode.com", 1},
+	"sepolia":          {"https://ethereum-sepolia-rpc.publicnode.com", 11155111},
+	"holesky":          {"https://ethereum-holesky-rpc.publicnode.com", 17000},
+	"optimism":         {"https://mainnet.optimism.io", 10},
+	"arbitrum":         {"https://arb1.arbitrum.io/rpc", 42161},
+	"arbitrum-sepolia": {"https://sepolia-rollup.arbitrum.io/rpc", 421614},
+	"base":             {"https://mainnet.base.org", 8453},
+	"base-sepolia":     {"https://sepolia.base.org", 84532},
+	"polygon":          {"https://polygon-rpc.com", 137},
+	"gnosis":           {"https://rpc.gnosischain.com", 100},
+}
+
+// networkNames returns the names of the built-in network presets, sorted.
+func networkNames() []string {
+	var names []string
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getNetwork returns the preset selected by the `network` flag, if any.
+// Besides the built-in presets, networks can be defined in the config file; see applyNetworkProfile.
+func getNetwork() (name string, preset networkPreset, ok bool) {
+	name = viper.GetString("network")
+	if name == "" {
+		return "", networkPreset{}, false
+	}
+	preset, ok = networks[name]
+	profile := networkProfile(name)
+	if !ok && profile == nil {
+		failf(usageError, "unknown network %q. Known networks are: %v, and those under networks in the config file\n",
+			name, strings.Join(networkNames(), ", "))
+	}
+	if node := viper.GetString(name + "-node"); node != "" {
+		preset.Node = node
+	}
+	if chainID := viper.GetInt64(name + "-chain-id"); chainID != 0 {
+		preset.ChainID = chainID
+	}
+	if chainID, ok := profile["chain-id"]; ok {
+		preset.ChainID = cast.ToInt64(chainID)
+	}
+	return name, preset, true
+}
+
+// networkProfile returns the settings of the network `name` under networks in the config file,
+// or nil if it has none.
+func networkProfile(name string) map[string]interface{} {
+	if !viper.IsSet("networks." + name) {
+		return nil
+	}
+	return viper.GetStringMap("networks." + name)
+}
+
+// profileKeys are the flags whose values came from the selected network's profile in the config file.
+var profileKeys = make(map[string]bool)
+
+// applyNetworkProfile applies the settings of the network selected by --network from the config file,
+// where networks map each network's name to flag values, as well as its chain-id:
+//
+//	networks:
+//	  mainnet:
+//	    node: https://mainnet.example.com
+//	    from: keystore:/keys/deployer.json
+//	    max-fee: 40
+//	  devnet:
+//	    node: http://10.0.0.5:8545
+//	    chain-id: 31337
+//
+// They take precedence over the rest of the config file, but not over flags or the environment.
+// applyNetworkProfile also checks that the selected network exists.
+func applyNetworkProfile() error {
+	name := viper.GetString("network")
+	if name == "" {
+		return nil
+	}
+	getNetwork() // Exits if the network is unknown.
+	for key, value := range networkProfile(name) {
+		if key == "chain-id" {
+			continue // Used by getNetwork.
+		}
+		f := pflag.Lookup(key)
+		if f == nil {
+			return xerrors.Errorf("unknown setting %q for network %v in the config file", key, name)
+		}
+		if f.Changed || isSetInEnv(key) {
+			continue
+		}
+		viper.Set(key, value)
+		profileKeys[key] = true
+	}
+	return nil
+}
+
+// getNodeURL returns the URL of the Ethereum node to connect to.
+// An explicitly set `node` flag wins over the `network` preset.
+func getNodeURL() string {
+	if !isExplicitlySet("node") {
+		if _, preset, ok := getNetwork(); ok && preset.Node != "" {
+			return preset.Node
+		}
+	}
+	return viper.GetString("node")
+}
+
+// verifiedChainID is the chain id, once getChainID has checked it against the node.
+var verifiedChainID *big.Int
+
+// getChainID returns the chain id to sign transactions for.
+// It comes from --chain-id if that's set, or else the `network` preset if there is one, and from the node otherwise.
+// Either of the first two must match the node's, or poke exits, rather than sign transactions for a chain the node isn't on.
+func getChainID() *big.Int {
+	if verifiedChainID != nil {
+		return verifiedChainID
+	}
+	var expected int64
+	var source string
+	if isExplicitlySet("chain-id") {
+		expected, source = viper.GetInt64("chain-id"), "--chain-id"
+	} else if name, preset, ok := getNetwork(); ok && preset.ChainID != 0 {
+		expected, source = preset.ChainID, "--network "+name
+	}
+	chainID := getNodeChainID()
+	if expected != 0 && chainID.Cmp(big.NewInt(expected)) != 0 {
+		failf(refusedError, "%v is chain %v, but the node at %v is on chain %v. Refusing to sign for the wrong chain.\n",
+			source, expected, getNodeURL(), chainID)
+	}
+	verifiedChainID = chainID
+	return chainID
+}
+
+// checkReplayProtection exits unless tx, signed elsewhere, is for the node's chain and protected by EIP-155,
+// without which it could be replayed on any chain. --force sends unprotected transactions anyway,
+// since some, like keyless deployments, are meant to be.
+func checkReplayProtection(tx *types.Transaction) {
+	if !tx.Protected() {
+		if !viper.GetBool("force") {
+			fail(refusedError, "The transaction isn't signed for a particular chain, as EIP-155 has it be, so it could be replayed on any chain. --force sends it anyway.")
+		}
+		return
+	}
+	if chainID := getChainID(); tx.ChainId().Cmp(chainID) != 0 {
+		failf(refusedError, "The transaction is for chain %v, but the node is on chain %v\n", tx.ChainId(), chainID)
+	}
+}
+
+// isExplicitlySet reports whether the flag `name` was set on the command line,
+// in the environment, or in the config file, rather than falling back to its default value.
+func isExplicitlySet(name string) bool {
+	if f := pflag.Lookup(name); f != nil && f.Changed {
+		return true
+	}
+	return isSetInEnv(name) || viper.InConfig(name) || profileKeys[name]
+}
+
+// isSetInEnv reports whether the flag `name` was set by a POKE_* environment variable.
+func isSetInEnv(name string) bool {
+	_, ok := os.LookupEnv("POKE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")))
+	return ok
+}
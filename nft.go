@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+// erc721ABI is the standard ERC-721 interface, with its optional metadata functions.
+// Of the two safeTransferFrom overloads, it has just the one without data, so that the function keeps its name.
+const erc721ABI = `[
+	{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"tokenURI","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"ownerOf","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"safeTransferFrom","stateMutability":"nonpayable","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"tokenId","type":"uint256"}],"outputs":[]},
+	{"type":"event","name":"Transfer","anonymous":false,"inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"tokenId","type":"uint256","indexed":true}]},
+	{"type":"event","name":"Approval","anonymous":false,"inputs":[{"name":"owner","type":"address","indexed":true},{"name":"approved","type":"address","indexed":true},{"name":"tokenId","type":"uint256","indexed":true}]},
+	{"type":"event","name":"ApprovalForAll","anonymous":false,"inputs":[{"name":"owner","type":"address","indexed":true},{"name":"operator","type":"address","indexed":true},{"name":"approved","type":"bool","indexed":false}]}
+]`
+
+// erc1155ABI is the standard ERC-1155 interface, with its optional metadata URI function.
+const erc1155ABI = `[
+	{"type":"function","name":"uri","stateMutability":"view","inputs":[{"name":"id","type":"uint256"}],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"},{"name":"id","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"safeTransferFrom","stateMutability":"nonpayable","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"id","type":"uint256"},{"name":"amount","type":"uint256"},{"name":"data","type":"bytes"}],"outputs":[]},
+	{"type":"event","name":"TransferSingle","anonymous":false,"inputs":[{"name":"operator","type":"address","indexed":true},{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"id","type":"uint256","indexed":false},{"name":"value","type":"uint256","indexed":false}]},
+	{"type":"event","name":"TransferBatch","anonymous":false,"inputs":[{"name":"operator","type":"address","indexed":true},{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"ids","type":"uint256[]","indexed":false},{"name":"values","type":"uint256[]","indexed":false}]},
+	{"type":"event","name":"ApprovalForAll","anonymous":false,"inputs":[{"name":"account","type":"address","indexed":true},{"name":"operator","type":"address","indexed":true},{"name":"approved","type":"bool","indexed":false}]},
+	{"type":"event","name":"URI","anonymous":false,"inputs":[{"name":"value","type":"string","indexed":false},{"name":"id","type":"uint256","indexed":true}]}
+]`
+
+// callView calls the view function method of the contract at --address, and returns its single result.
+func callView(theABI abi.ABI, method string, args ...interface{}) interface{} {
+	var results []interface{}
+	err := getDeployment(theABI).Call(callOpts(), &results, method, args...)
+	check(explainRevert(theABI, err), "calling "+method)
+	return results[0]
+}
+
+// parseTokenID parses s as the id of an NFT. Unlike amounts, ids aren't scaled by --decimals, and must be whole.
+func parseTokenID(s string) *big.Int {
+	defer failingAs(usageError)()
+	id := parseInteger(s, false, 256)
+	if !parseDecimal(s).Equal(decimal.NewFromBigInt(id, 0)) {
+		fatalf("%q isn't a whole number, so it can't be a token id\n", s)
+	}
+	return id
+}
+
+// fetchMetadataURI returns the JSON metadata at uri, as an NFT's tokenURI or uri gives it.
+// Besides http(s) URLs, it reads data: URIs, and ipfs:// and ar:// ones through gateways.
+func fetchMetadataURI(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		return decodeDataURI(uri)
+	}
+	switch {
+	case strings.HasPrefix(uri, "ipfs://"):
+		uri = strings.TrimSuffix(viper.GetString("ipfs-gateway"), "/") + "/" + strings.TrimPrefix(strings.TrimPrefix(uri, "ipfs://"), "ipfs/")
+	case strings.HasPrefix(uri, "ar://"):
+		uri = "https://arweave.net/" + strings.TrimPrefix(uri, "ar://")
+	}
+	resp, err := http.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, xerrors.Errorf("HTTP %v from %v", resp.Status, uri)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// decodeDataURI returns the data in an RFC 2397 data: URI, which contracts that keep their metadata on chain return.
+func decodeDataURI(uri string) ([]byte, error) {
+	comma := strings.Index(uri, ",")
+	if comma < 0 {
+		return nil, xerrors.Errorf("data URI has no comma")
+	}
+	header, data := uri[len("data:"):comma], uri[comma+1:]
+	if strings.HasSuffix(header, ";base64") {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	// Without base64, the data is percent-encoded, though contracts often leave JSON as it is.
+	unescaped, err := url.PathUnescape(data)
+	if err != nil {
+		return []byte(data), nil
+	}
+	return []byte(unescaped), nil
+}
+
+// printMetadata fetches and prints the JSON metadata of an NFT at uri, indented, in the order of its fields.
+func printMetadata(uri string) {
+	metadata, err := fetchMetadataURI(uri)
+	check(err, "fetching metadata from "+uri)
+	var out bytes.Buffer
+	if err := json.Indent(&out, bytes.TrimSpace(metadata), "", "  "); err != nil {
+		fatalf("The metadata at %v isn't JSON: %v\n", uri, err)
+	}
+	fmt.Println(out.String())
+}
+
+// erc721Cmds returns the commands for the bundled ERC-721 ABI, theABI: the calls and the transactions.
+func erc721Cmds(theABI abi.ABI) (calls []*cobra.Command, transactions []*cobra.Command) {
+	calls = []*cobra.Command{
+		{
+			Use:     "ownerOf <tokenId>",
+			Short:   "Show the owner of an NFT",
+			Example: "  poke erc721 0xBC4CA0EdA7647A8aB7C2061c2E118A18a936f13D ownerOf 1",
+			Args:    cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				owner := callView(theABI, "ownerOf", parseTokenID(args[0]))
+				printResult(owner.(common.Address).Hex())
+			},
+		},
+		{
+			Use:   "balanceOf <owner>",
+			Short: "Show how many of the collection's NFTs an address owns",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				printResult(fmt.Sprint(callView(theABI, "balanceOf", parseAddress(args[0]))))
+			},
+		},
+		{
+			Use:   "tokenURI <tokenId>",
+			Short: "Show the URI of an NFT's metadata",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				printResult(callView(theABI, "tokenURI", parseTokenID(args[0])).(string))
+			},
+		},
+		{
+			Use:   "metadata <tokenId>",
+			Short: "Fetch and print the JSON metadata at an NFT's tokenURI",
+			Long: "Fetches the JSON metadata at an NFT's tokenURI, and prints it indented. The URI may be an http(s) URL, a data: URI, " +
+				"or an ipfs:// URI, which is fetched through --ipfs-gateway, or ar:// one, through arweave.net.",
+			Example: "  poke erc721 0xBC4CA0EdA7647A8aB7C2061c2E118A18a936f13D metadata 1",
+			Args:    cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				printMetadata(callView(theABI, "tokenURI", parseTokenID(args[0])).(string))
+			},
+		},
+	}
+	transactions = []*cobra.Command{
+		{
+			Use:     "safeTransferFrom <from> <to> <tokenId>",
+			Short:   "Transfer an NFT, checking that a contract receiving it can handle it",
+			Example: "  poke erc721 0xBC4CA0EdA7647A8aB7C2061c2E118A18a936f13D safeTransferFrom @0 @1 1",
+			Args:    cobra.ExactArgs(3),
+			Run: func(cmd *cobra.Command, args []string) {
+				from, to, id := parseAddress(args[0]), parseAddress(args[1]), parseTokenID(args[2])
+				tx, err := getDeployment(theABI).Transact(getTxnOpts(), "safeTransferFrom", from, to, id)
+				log(fmt.Sprintf("transfer of token %v", id), tx, theABI, err)
+			},
+		},
+	}
+	return calls, transactions
+}
+
+// erc1155URI returns the URI of the metadata of token id of the ERC-1155 contract,
+// with any {id} in it replaced by the id, in the 64-digit hex the standard specifies.
+func erc1155URI(theABI abi.ABI, id *big.Int) string {
+	uri := callView(theABI, "uri", id).(string)
+	return strings.Replace(uri, "{id}", fmt.Sprintf("%064x", id), -1)
+}
+
+// erc1155Cmds returns the commands for the bundled ERC-1155 ABI, theABI: the calls and the transactions.
+// Amounts of ERC-1155 tokens are whole numbers, so --decimals doesn't apply to them.
+func erc1155Cmds(theABI abi.ABI) (calls []*cobra.Command, transactions []*cobra.Command) {
+	calls = []*cobra.Command{
+		{
+			Use:     "balanceOf <account> <id>",
+			Short:   "Show how many of a token an address owns",
+			Example: "  poke erc1155 0x76BE3b62873462d2142405439777e971754E8E77 balanceOf @1 1",
+			Args:    cobra.ExactArgs(2),
+			Run: func(cmd *cobra.Command, args []string) {
+				printResult(fmt.Sprint(callView(theABI, "balanceOf", parseAddress(args[0]), parseTokenID(args[1]))))
+			},
+		},
+		{
+			Use:   "uri <id>",
+			Short: "Show the URI of a token's metadata, with {id} filled in",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				printResult(erc1155URI(theABI, parseTokenID(args[0])))
+			},
+		},
+		{
+			Use:   "metadata <id>",
+			Short: "Fetch and print the JSON metadata at a token's uri",
+			Long: "Fetches the JSON metadata at a token's uri, with {id} filled in, and prints it indented. " +
+				"URIs are fetched as erc721's metadata fetches them.",
+			Args: cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				printMetadata(erc1155URI(theABI, parseTokenID(args[0])))
+			},
+		},
+	}
+	transactions = []*cobra.Command{
+		{
+			Use:     "safeTransferFrom <from> <to> <id> <amount> [data]",
+			Short:   "Transfer an amount of a token, with data for a contract receiving it",
+			Example: "  poke erc1155 0x76BE3b62873462d2142405439777e971754E8E77 safeTransferFrom @0 @1 1 10",
+			Args:    cobra.RangeArgs(4, 5),
+			Run: func(cmd *cobra.Command, args []string) {
+				from, to, id := parseAddress(args[0]), parseAddress(args[1]), parseTokenID(args[2])
+				amount := parseTokenID(args[3])
+				data := []byte{}
+				if len(args) == 5 {
+					data = parseBytes(args[4])
+				}
+				tx, err := getDeployment(theABI).Transact(getTxnOpts(), "safeTransferFrom", from, to, id, amount, data)
+				log(fmt.Sprintf("transfer of %v of token %v", amount, id), tx, theABI, err)
+			},
+		},
+	}
+	return calls, transactions
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/spf13/cobra"
+)
+
+// isOverloaded reports whether theABI has more than one method named rawName.
+func isOverloaded(theABI abi.ABI, rawName string) bool {
+	count := 0
+	for _, method := range theABI.Methods {
+		if method.RawName == rawName {
+			count++
+		}
+	}
+	return count > 1
+}
+
+// overloadCmd returns a command named rawName that runs whichever of cmds, the commands
+// of an overloaded method's signatures, takes as many arguments as it was given.
+// If several overloads take that many, the signature has to be named explicitly.
+func overloadCmd(rawName string, cmds []*cobra.Command) *cobra.Command {
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name() < cmds[j].Name()
+	})
+	sigs := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		sigs[i] = cmd.Name()
+	}
+	return &cobra.Command{
+		Use:   rawName + " [arg...]",
+		Short: "Overloaded: runs " + strings.Join(sigs, " or ") + ", by number of arguments",
+		Run: func(cmd *cobra.Command, args []string) {
+			var matches []*cobra.Command
+			for _, overload := range cmds {
+				if overload.Args(overload, args) == nil {
+					matches = append(matches, overload)
+				}
+			}
+			switch len(matches) {
+			case 0:
+				fatalf("No overload of %v takes %v arguments. Its overloads are:\n  %v\n",
+					rawName, len(args), strings.Join(sigs, "\n  "))
+			case 1:
+				matches[0].Run(matches[0], args)
+			default:
+				matchingSigs := make([]string, len(matches))
+				for i, match := range matches {
+					matchingSigs[i] = match.Name()
+				}
+				fatalf("Several overloads of %v take %v arguments, so name the one you mean, quoted:\n  %v\n",
+					rawName, len(args), strings.Join(matchingSigs, "\n  "))
+			}
+		},
+	}
+}
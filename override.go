@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/viper"
+)
+
+// stateOverride is how eth_call should pretend one account is, for the duration of the call.
+// Fields left out keep their real values, which is why it doesn't use gethclient's OverrideAccount:
+// that always overrides the nonce, and the code too.
+type stateOverride struct {
+	Balance   *hexutil.Big                `json:"balance,omitempty"`
+	Nonce     *hexutil.Uint64             `json:"nonce,omitempty"`
+	Code      *hexutil.Bytes              `json:"code,omitempty"`
+	StateDiff map[common.Hash]common.Hash `json:"stateDiff,omitempty"`
+}
+
+// overrides caches the parsed --override flags.
+var overrides map[common.Address]*stateOverride
+
+// getOverrides returns the state overrides from the `override` flags, each like
+// address:balance=...,nonce=...,code=0x...,storage[slot]=value.
+// The flag's values are split at commas, so a value without an address continues the override before it.
+func getOverrides() map[common.Address]*stateOverride {
+	if overrides != nil {
+		return overrides
+	}
+	overrides = make(map[common.Address]*stateOverride)
+	var account *stateOverride
+	for _, part := range viper.GetStringSlice("override") {
+		eq := strings.Index(part, "=")
+		if colon := strings.Index(part, ":"); colon != -1 && (eq == -1 || colon < eq) {
+			address := parseAddress(part[:colon])
+			if overrides[address] == nil {
+				overrides[address] = &stateOverride{}
+			}
+			account = overrides[address]
+			part = part[colon+1:]
+			eq = strings.Index(part, "=")
+		}
+		if account == nil || eq == -1 {
+			fatalf("--override %q should look like address:balance=...,code=...,storage[slot]=...\n", part)
+		}
+		parseOverrideField(account, part[:eq], part[eq+1:])
+	}
+	return overrides
+}
+
+// parseOverrideField sets the field of account named key to value.
+func parseOverrideField(account *stateOverride, key, value string) {
+	switch {
+	case key == "balance":
+		account.Balance = (*hexutil.Big)(parseInteger(value, false, 256))
+	case key == "nonce":
+		nonce := hexutil.Uint64(parseInteger(value, false, 64).Uint64())
+		account.Nonce = &nonce
+	case key == "code":
+		code, err := hexutil.Decode(value)
+		check(err, "parsing --override code")
+		account.Code = (*hexutil.Bytes)(&code)
+	case strings.HasPrefix(key, "storage[") && strings.HasSuffix(key, "]"):
+		if account.StateDiff == nil {
+			account.StateDiff = make(map[common.Hash]common.Hash)
+		}
+		account.StateDiff[parseStorageWord(key[len("storage["):len(key)-1])] = parseStorageWord(value)
+	default:
+		fatalf("--override can't set %q. It can set balance, nonce, code, and storage[slot].\n", key)
+	}
+}
+
+// parseStorageWord parses a storage slot or value: a 0x-prefixed hex word, or a number.
+func parseStorageWord(s string) common.Hash {
+	if strings.HasPrefix(s, "0x") {
+		if len(s)%2 == 1 {
+			s = "0x0" + s[2:]
+		}
+		word, err := hexutil.Decode(s)
+		check(err, "parsing storage word "+s)
+		if len(word) > common.HashLength {
+			fatalf("%v is longer than a storage word\n", s)
+		}
+		return common.BytesToHash(word)
+	}
+	return common.BigToHash(parseInteger(s, false, 256))
+}
+
+// caller is the backend view functions are called through.
+// It behaves like the node, except that it applies the --override state overrides to calls.
+type caller struct {
+	*ethclient.Client
+}
+
+// CallContract calls the node's eth_call, with the state overrides if there are any.
+func (c caller) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if len(getOverrides()) == 0 {
+		return c.Client.CallContract(ctx, msg, blockNumber)
+	}
+	var result hexutil.Bytes
+	err := getRPC().CallContext(ctx, &result, "eth_call", callArg(msg), blockTag(blockNumber), getOverrides())
+	return result, err
+}
+
+// callArg is msg as the call object of JSON-RPC methods like eth_call.
+func callArg(msg ethereum.CallMsg) map[string]interface{} {
+	arg := map[string]interface{}{"from": msg.From, "to": msg.To}
+	if len(msg.Data) > 0 {
+		arg["data"] = hexutil.Bytes(msg.Data)
+	}
+	if msg.Value != nil {
+		arg["value"] = (*hexutil.Big)(msg.Value)
+	}
+	if msg.Gas != 0 {
+		arg["gas"] = hexutil.Uint64(msg.Gas)
+	}
+	return arg
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v2"
+)
+
+// planStep is one contract of a deployment plan: what to build it from,
+// the arguments to deploy it with, and the transactions that set it up once it's deployed.
+type planStep struct {
+	// Name is the contract's name in the deployments file, and in references to it, like $token.
+	// It defaults to the contract's name.
+	Name   string `json:"name" yaml:"name"`
+	Source string `json:"source" yaml:"source"`
+	// Contract picks the contract in Source, as -c does. It defaults to the file's name.
+	Contract string      `json:"contract" yaml:"contract"`
+	Args     []planValue `json:"args" yaml:"args"`
+	// Calls are transactions to send to the contract, like "transfer $vault 1e18",
+	// or to an earlier step's contract, like "$token.transfer $vault 1e18".
+	Calls []string `json:"calls" yaml:"calls"`
+}
+
+// planFile is the contents of a plan file.
+type planFile struct {
+	Contracts []planStep `json:"contracts" yaml:"contracts"`
+}
+
+// planValue is an argument in a plan: a string, or a list or object of planValues, for an array or struct.
+// Numbers are kept as they're written, since decoding them would round those too big for a float64,
+// like most token amounts, to different numbers.
+type planValue struct {
+	value interface{}
+}
+
+func (v *planValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	// A scalar decodes to a string as it's written, whether it's a number or not.
+	var s string
+	if err := unmarshal(&s); err == nil {
+		var decoded interface{}
+		unmarshal(&decoded)
+		switch decoded.(type) {
+		case int, int64, uint64, float64:
+			s = planNumber(s)
+		}
+		v.value = s
+		return nil
+	}
+	var list []planValue
+	if err := unmarshal(&list); err == nil {
+		v.value = list
+		return nil
+	}
+	var object map[string]planValue
+	if err := unmarshal(&object); err != nil {
+		return err
+	}
+	v.value = object
+	return nil
+}
+
+func (v *planValue) UnmarshalJSON(data []byte) error {
+	switch data[0] {
+	case '"':
+		var s string
+		err := json.Unmarshal(data, &s)
+		v.value = s
+		return err
+	case '[':
+		var list []planValue
+		err := json.Unmarshal(data, &list)
+		v.value = list
+		return err
+	case '{':
+		var object map[string]planValue
+		err := json.Unmarshal(data, &object)
+		v.value = object
+		return err
+	}
+	// Numbers, booleans, and null.
+	v.value = planNumber(string(data))
+	return nil
+}
+
+func (v planValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.value)
+}
+
+// planIntegerRegexp matches an integer as YAML and JSON write it.
+var planIntegerRegexp = regexp.MustCompile(`^-?[0-9]+$`)
+
+// planNumber writes a number from a plan as it would be given on the command line,
+// where integers of more than three digits need underscores between each group of three.
+func planNumber(text string) string {
+	if !planIntegerRegexp.MatchString(text) {
+		return text
+	}
+	sign, digits := "", text
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	var grouped strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte('_')
+		}
+		grouped.WriteRune(digit)
+	}
+	return sign + grouped.String()
+}
+
+// planContract is a contract of a plan, once it's built, for its calls and later steps to refer to.
+// address is set once the contract is deployed.
+type planContract struct {
+	step    planStep
+	build   *cacheObject
+	abi     abi.ABI
+	address common.Address
+}
+
+// planNameRegexp matches the names of a plan's contracts, which are referred to as script variables are.
+var planNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// planCmd is the root of `poke plan`, which takes the place of the usual commands for a contract.
+func planCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use: "poke",
+	}
+	plan := &cobra.Command{
+		Use:   "plan",
+		Short: "Deploy and set up several contracts, as described by a plan file",
+	}
+	root.AddCommand(plan)
+	plan.AddCommand(&cobra.Command{
+		Use:   "apply <plan file>",
+		Short: "Deploy the plan's contracts in order, and send their initialization calls, skipping what's already done",
+		Long: "Deploys each contract of a YAML or JSON plan that the --deployments file doesn't have a deployment of, " +
+			"then sends the initialization calls it hasn't yet sent, recording its progress as it goes. " +
+			"Running it again after a failure picks up where it stopped.",
+		Example: "  poke plan apply deploy.yaml --network sepolia",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			applyPlan(args[0])
+		},
+	})
+	return root
+}
+
+// readPlan reads the steps of the plan file, resolving their sources relative to it.
+func readPlan(file string) ([]planStep, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, xerrors.Errorf("reading plan: %w", err)
+	}
+	var plan planFile
+	switch filepath.Ext(file) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(contents))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&plan)
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(contents, &plan)
+	default:
+		return nil, xerrors.Errorf("%v should be a .yaml or .json file", file)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("reading plan %v: %w", file, err)
+	}
+	steps := plan.Contracts
+	if len(steps) == 0 {
+		return nil, xerrors.Errorf("%v has no contracts to deploy", file)
+	}
+	names := make(map[string]bool)
+	for i := range steps {
+		step := &steps[i]
+		if step.Source == "" {
+			return nil, xerrors.Errorf("%v: contract %v has no source", file, i+1)
+		}
+		if step.Name == "" {
+			step.Name = step.Contract
+			if step.Name == "" {
+				step.Name = trimExtension(path.Base(step.Source))
+			}
+		}
+		if !filepath.IsAbs(step.Source) {
+			step.Source = filepath.Join(filepath.Dir(file), step.Source)
+		}
+		if !planNameRegexp.MatchString(step.Name) {
+			return nil, xerrors.Errorf("%v: %q isn't a valid name: use letters, digits, and underscores", file, step.Name)
+		}
+		if names[step.Name] {
+			return nil, xerrors.Errorf("%v: there are two contracts named %v", file, step.Name)
+		}
+		names[step.Name] = true
+	}
+	return steps, nil
+}
+
+// planArg turns an argument from a plan into one as given on the command line,
+// replacing references to earlier contracts with their addresses.
+// Lists and objects are written as JSON, which is how arrays and structs are given.
+func planArg(value planValue, contracts map[string]*planContract) (string, error) {
+	arg, ok := value.value.(string)
+	if !ok {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", xerrors.Errorf("encoding argument: %w", err)
+		}
+		arg = string(encoded)
+	}
+	var undefined string
+	arg = scriptVarRegexp.ReplaceAllStringFunc(arg, func(v string) string {
+		contract, ok := contracts[v[1:]]
+		if !ok {
+			undefined = v
+			return v
+		}
+		return contract.address.Hex()
+	})
+	if undefined != "" {
+		return "", xerrors.Errorf("%v isn't a contract deployed earlier in the plan", undefined)
+	}
+	return arg, nil
+}
+
+// loadPlan reads the plan file and builds its contracts, checking that their constructor arguments and calls
+// make sense for them, so that a mistake is caught before anything is deployed.
+func loadPlan(file string) ([]*planContract, map[string]*planContract, error) {
+	steps, err := readPlan(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	var plan []*planContract
+	contracts := make(map[string]*planContract)
+	for _, step := range steps {
+		contractName := step.Contract
+		if contractName == "" {
+			contractName = trimExtension(path.Base(step.Source))
+		}
+		build, err := loadBuild(step.Source, contractName, step.Contract == "")
+		if err != nil {
+			return nil, nil, xerrors.Errorf("%v: %w", step.Name, err)
+		}
+		theABI, err := abi.JSON(strings.NewReader(build.ABI))
+		if err != nil {
+			return nil, nil, xerrors.Errorf("parsing ABI of %v: %w", step.Name, err)
+		}
+		if build.Bin == "" {
+			return nil, nil, xerrors.Errorf("%v has no bytecode to deploy", step.Name)
+		}
+		if len(step.Args) != len(theABI.Constructor.Inputs) {
+			return nil, nil, xerrors.Errorf("%v's constructor takes %v arguments, but the plan gives %v",
+				step.Name, len(theABI.Constructor.Inputs), len(step.Args))
+		}
+		// Parsing the arguments checks them, with the contracts yet to be deployed at the zero address.
+		args := make([]string, len(step.Args))
+		for i, value := range step.Args {
+			if args[i], err = planArg(value, contracts); err != nil {
+				return nil, nil, xerrors.Errorf("%v: %w", step.Name, err)
+			}
+		}
+		enumInputs = build.EnumInputs
+		parseArgs("constructor", theABI.Constructor.Inputs, args)
+		contract := &planContract{step: step, build: build, abi: theABI}
+		plan = append(plan, contract)
+		contracts[step.Name] = contract
+		for _, call := range step.Calls {
+			target, method, args, err := parsePlanCall(step.Name, call, contracts)
+			if err != nil {
+				return nil, nil, err
+			}
+			enumInputs = target.build.EnumInputs
+			parseArgs(method.Sig, method.Inputs, args)
+		}
+	}
+	return plan, contracts, nil
+}
+
+// parsePlanCall parses call, one of the initialization calls of the plan's contract name. It returns the contract
+// to send it to, the function to call, and its arguments, with references to contracts replaced by their addresses.
+func parsePlanCall(name, call string, contracts map[string]*planContract) (*planContract, abi.Method, []string, error) {
+	words, err := splitScriptLine(call)
+	if err == nil && len(words) == 0 {
+		err = xerrors.New("the call is empty")
+	}
+	if err != nil {
+		return nil, abi.Method{}, nil, xerrors.Errorf("%v: %v: %w", name, call, err)
+	}
+
+	// A call to another of the plan's contracts starts with its name, like $token.approve.
+	target, methodName := contracts[name], words[0]
+	if parts := strings.SplitN(words[0], ".", 2); len(parts) == 2 && strings.HasPrefix(parts[0], "$") {
+		other, ok := contracts[parts[0][1:]]
+		if !ok {
+			return nil, abi.Method{}, nil, xerrors.Errorf("%v: %v isn't a contract deployed earlier in the plan", name, parts[0])
+		}
+		target, methodName = other, parts[1]
+	}
+	method, err := lookupMethod(target.abi, methodName)
+	if err != nil {
+		return nil, abi.Method{}, nil, xerrors.Errorf("%v: %w", name, err)
+	}
+	if method.IsConstant() {
+		return nil, abi.Method{}, nil, xerrors.Errorf("%v: %v is a view function, so calling it wouldn't set anything up", name, method.Sig)
+	}
+	args := words[1:]
+	if len(args) != len(method.Inputs) {
+		return nil, abi.Method{}, nil, xerrors.Errorf("%v: %v takes %v arguments, but the plan gives %v",
+			name, method.Sig, len(method.Inputs), len(args))
+	}
+	for i, arg := range args {
+		args[i], err = planArg(planValue{arg}, contracts)
+		if err != nil {
+			return nil, abi.Method{}, nil, xerrors.Errorf("%v: %w", name, err)
+		}
+	}
+	return target, method, args, nil
+}
+
+// applyPlan deploys the contracts of the plan file that aren't in the deployments file yet,
+// and sends each one's initialization calls that haven't been sent.
+// With --output json, it prints the contracts' addresses and the transactions it sent as one JSON object.
+func applyPlan(file string) {
+	if viper.GetString("deployments") == "" {
+		failf(usageError, "A plan keeps track of what it's done in the deployments file, so --deployments can't be empty\n")
+	}
+	if viper.GetBool("no-wait") {
+		failf(usageError, "Later steps of a plan need the earlier ones mined, so it can't be applied with --no-wait\n")
+	}
+	if viper.GetString("value") != "" {
+		failf(usageError, "--value would be sent with every transaction of the plan, so it can't be given\n")
+	}
+	restore := failingAs(usageError)
+	plan, contracts, err := loadPlan(file)
+	restore()
+	if err != nil {
+		fatal(withClass(usageError, err))
+	}
+
+	addresses := make(map[string]string)
+	var sent []interface{}
+	for _, contract := range plan {
+		step := contract.step
+		registryContract = step.Name
+		record, ok := lookupDeploymentRecord()
+		if ok {
+			code, err := getNode().CodeAt(context.Background(), hexToAddress(record.Address), nil)
+			check(err, "checking the deployment of "+step.Name)
+			ok = len(code) > 0
+		}
+		if ok {
+			fmt.Fprintf(humanOutput(), "%v is already deployed at %v\n", step.Name, record.Address)
+		} else {
+			fmt.Fprintf(humanOutput(), "Deploying %v\n", step.Name)
+			args := make([]string, len(step.Args))
+			for i, value := range step.Args {
+				args[i], _ = planArg(value, contracts)
+			}
+			enumInputs = contract.build.EnumInputs
+			inputs := parseArgs("constructor", contract.abi.Constructor.Inputs, args)
+			address, constructorArgs, tx, receipt := deployContract(contract.abi, contract.build.Bin, contract.build.Contracts, inputs)
+			if jsonOutput() {
+				sent = append(sent, sentTxJSON(contract.abi, tx, receipt))
+			} else {
+				printReceipt(contract.abi, receipt)
+			}
+			fmt.Fprintf(humanOutput(), "Deployed %v at %v\n", step.Name, address.Hex())
+			record = deploymentRecord{
+				Address:         address.Hex(),
+				TransactionHash: tx.Hash().Hex(),
+				ConstructorArgs: constructorArgs,
+				ChainID:         getChainID().Int64(),
+			}
+		}
+		contract.address = hexToAddress(record.Address)
+		addresses[step.Name] = record.Address
+
+		if record.InitCalls > len(step.Calls) {
+			fatalf("%v has made %v initialization calls, but the plan only has %v\n", step.Name, record.InitCalls, len(step.Calls))
+		}
+		for record.InitCalls < len(step.Calls) {
+			call := step.Calls[record.InitCalls]
+			fmt.Fprintf(humanOutput(), "%v: %v\n", step.Name, call)
+			target, method, args, _ := parsePlanCall(step.Name, call, contracts)
+			if tx := sendPlanCall(target, method, args); tx != nil {
+				sent = append(sent, tx)
+			}
+			record.InitCalls++
+			recordDeployment(record)
+		}
+	}
+
+	if jsonOutput() {
+		if sent == nil {
+			sent = []interface{}{}
+		}
+		printJSON(map[string]interface{}{
+			"contracts":    addresses,
+			"transactions": sent,
+		})
+	}
+}
+
+// sendPlanCall calls method on target with args, and waits for the transaction to be mined.
+// With --output json, it returns the transaction as JSON for applyPlan to print, rather than printing it.
+func sendPlanCall(target *planContract, method abi.Method, args []string) map[string]interface{} {
+	enumInputs = target.build.EnumInputs
+	inputs := parseArgs(method.Sig, method.Inputs, args)
+	contract := bind.NewBoundContract(
+		target.address,
+		target.abi,
+		caller{getNode()},
+		transactor{Client: getNode(), abi: &target.abi},
+		getNode(),
+	)
+	tx, err := contract.Transact(getTxnOpts(), method.Name, inputs...)
+	receipt := waitSent(method.RawName+"()", tx, target.abi, err)
+	if jsonOutput() {
+		return sentTxJSON(target.abi, tx, receipt)
+	}
+	printReceipt(target.abi, receipt)
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// eip1967ImplementationSlot is where EIP-1967 proxies, like OpenZeppelin's transparent and UUPS proxies, keep their implementation.
+	eip1967ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+	// eip1967BeaconSlot is where EIP-1967 beacon proxies keep their beacon, which knows the implementation.
+	eip1967BeaconSlot = common.HexToHash("0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50")
+	// eip1822Slot is where EIP-1822 (UUPS) proxies that predate EIP-1967 keep their implementation: keccak256("PROXIABLE").
+	eip1822Slot = common.HexToHash("0xc5f16f0fcc639fa48a6947836d9850f504798523bf8c9a3a87d5876cf622bcf7")
+	// zeppelinOSSlot is where OpenZeppelin's proxies kept their implementation before EIP-1967.
+	zeppelinOSSlot = common.HexToHash("0x7050c9e0f4ca769c69bd3a8ef740bc37934f8e2c036a5a723fd8ee048ed3f8c3")
+
+	// implementationSelector is the selector of a beacon's implementation().
+	implementationSelector = []byte{0x5c, 0x60, 0xda, 0x1b}
+)
+
+// proxyImplementation finds the implementation behind the proxy at address, by looking in the storage slots
+// that the common proxy standards keep it in. It returns the implementation and the kind of proxy,
+// or ok false if address doesn't look like a proxy.
+func proxyImplementation(address common.Address) (implementation common.Address, kind string, ok bool) {
+	ctx := context.Background()
+	readSlot := func(slot common.Hash) common.Address {
+		word, err := getNode().StorageAt(ctx, address, slot, callBlock())
+		check(err, "reading proxy storage")
+		return common.BytesToAddress(word)
+	}
+	if implementation := readSlot(eip1967ImplementationSlot); implementation != (common.Address{}) {
+		return implementation, "EIP-1967", true
+	}
+	if beacon := readSlot(eip1967BeaconSlot); beacon != (common.Address{}) {
+		result, err := getNode().CallContract(ctx, ethereum.CallMsg{To: &beacon, Data: implementationSelector}, callBlock())
+		check(err, "asking beacon "+beacon.Hex()+" for the implementation")
+		if len(result) < 32 {
+			fatalf("The beacon %v of the proxy at %v has no implementation()\n", beacon.Hex(), address.Hex())
+		}
+		return common.BytesToAddress(result[:32]), "EIP-1967 beacon, through the beacon at " + beacon.Hex(), true
+	}
+	if implementation := readSlot(eip1822Slot); implementation != (common.Address{}) {
+		return implementation, "EIP-1822", true
+	}
+	if implementation := readSlot(zeppelinOSSlot); implementation != (common.Address{}) {
+		return implementation, "ZeppelinOS", true
+	}
+	return common.Address{}, "", false
+}
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy",
+	Short: "Print the implementation of the proxy at --address",
+	Long: "Looks for the implementation of the contract at --address in the storage slots of EIP-1967 proxies, " +
+		"including beacon proxies, EIP-1822 proxies, and older ZeppelinOS ones.",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		address := getContractAddress()
+		implementation, kind, ok := proxyImplementation(address)
+		if !ok {
+			fatalf("%v isn't a proxy poke recognizes\n", address.Hex())
+		}
+		if jsonOutput() {
+			printJSON(map[string]string{"implementation": implementation.Hex(), "type": kind})
+			return
+		}
+		fmt.Printf("Implementation: %v\nProxy type: %v\n", implementation.Hex(), kind)
+	},
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// qrBlocks describes the error correction blocks of a QR code version at error correction level L:
+// how many error correction codewords each block has, and how many blocks of each of the two sizes of data there are.
+type qrBlocks struct {
+	ecPerBlock           int
+	blocks1, dataLength1 int
+	blocks2, dataLength2 int
+}
+
+// qrVersions are the blocks of QR code versions 1 through 20, at level L, from ISO/IEC 18004's table 9.
+// They hold up to 858 bytes, which is plenty for a URI.
+var qrVersions = []qrBlocks{
+	{7, 1, 19, 0, 0}, {10, 1, 34, 0, 0}, {15, 1, 55, 0, 0}, {20, 1, 80, 0, 0}, {26, 1, 108, 0, 0},
+	{18, 2, 68, 0, 0}, {20, 2, 78, 0, 0}, {24, 2, 97, 0, 0}, {30, 2, 116, 0, 0}, {18, 2, 68, 2, 69},
+	{20, 4, 81, 0, 0}, {24, 2, 92, 2, 93}, {26, 4, 107, 0, 0}, {30, 3, 115, 1, 116}, {22, 5, 87, 1, 88},
+	{24, 5, 98, 1, 99}, {28, 1, 107, 5, 108}, {30, 5, 120, 1, 121}, {28, 3, 113, 4, 114}, {28, 3, 107, 5, 108},
+}
+
+func (b qrBlocks) dataCapacity() int {
+	return b.blocks1*b.dataLength1 + b.blocks2*b.dataLength2
+}
+
+// qrCode is a QR code's grid of modules, true for dark ones.
+type qrCode struct {
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+// encodeQR encodes data as a QR code, in byte mode, at error correction level L, in the smallest version that fits it.
+// It follows Project Nayuki's QR code generator, which is in the public domain.
+func encodeQR(data []byte) (*qrCode, error) {
+	version := 0
+	for v, blocks := range qrVersions {
+		countBits := 8
+		if v+1 >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*blocks.dataCapacity() {
+			version = v + 1
+			break
+		}
+	}
+	if version == 0 {
+		return nil, xerrors.Errorf("%v bytes is too long for a QR code", len(data))
+	}
+	blocks := qrVersions[version-1]
+
+	// The data: byte mode, the length, the bytes, a terminator, and padding.
+	var bits []bool
+	appendBits := func(value, length int) {
+		for i := length - 1; i >= 0; i-- {
+			bits = append(bits, (value>>uint(i))&1 == 1)
+		}
+	}
+	appendBits(0x4, 4)
+	if version < 10 {
+		appendBits(len(data), 8)
+	} else {
+		appendBits(len(data), 16)
+	}
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+	capacity := 8 * blocks.dataCapacity()
+	for i := 0; i < 4 && len(bits) < capacity; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		appendBits(pad, 8)
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << uint(7-i%8)
+		}
+	}
+
+	// Split the data into blocks, add error correction to each, and interleave them.
+	var dataBlocks, ecBlocks [][]byte
+	generator := reedSolomonGenerator(blocks.ecPerBlock)
+	for i := 0; i < blocks.blocks1+blocks.blocks2; i++ {
+		length := blocks.dataLength1
+		if i >= blocks.blocks1 {
+			length = blocks.dataLength2
+		}
+		block := codewords[:length]
+		codewords = codewords[length:]
+		dataBlocks = append(dataBlocks, block)
+		ecBlocks = append(ecBlocks, reedSolomonRemainder(block, generator))
+	}
+	var interleaved []byte
+	for i := 0; i < blocks.dataLength1 || i < blocks.dataLength2; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				interleaved = append(interleaved, block[i])
+			}
+		}
+	}
+	for i := 0; i < blocks.ecPerBlock; i++ {
+		for _, block := range ecBlocks {
+			interleaved = append(interleaved, block[i])
+		}
+	}
+
+	qr := &qrCode{size: 4*version + 17}
+	qr.modules = make([][]bool, qr.size)
+	qr.isFunction = make([][]bool, qr.size)
+	for y := range qr.modules {
+		qr.modules[y] = make([]bool, qr.size)
+		qr.isFunction[y] = make([]bool, qr.size)
+	}
+	qr.drawFunctionPatterns(version)
+	qr.drawCodewords(interleaved)
+
+	// Use the mask that leaves the fewest patterns that confuse scanners.
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormatBits(mask)
+		if penalty := qr.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		qr.applyMask(mask)
+	}
+	qr.applyMask(bestMask)
+	qr.drawFormatBits(bestMask)
+	return qr, nil
+}
+
+func (qr *qrCode) setFunction(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.isFunction[y][x] = true
+}
+
+// drawFunctionPatterns draws the finder, timing, and alignment patterns, and the version information,
+// and reserves the modules of the format information.
+func (qr *qrCode) drawFunctionPatterns(version int) {
+	for i := 0; i < qr.size; i++ {
+		qr.setFunction(6, i, i%2 == 0)
+		qr.setFunction(i, 6, i%2 == 0)
+	}
+	for _, corner := range [][2]int{{3, 3}, {qr.size - 4, 3}, {3, qr.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := corner[0]+dx, corner[1]+dy
+				if x < 0 || x >= qr.size || y < 0 || y >= qr.size {
+					continue
+				}
+				distance := abs(dx)
+				if abs(dy) > distance {
+					distance = abs(dy)
+				}
+				qr.setFunction(x, y, distance != 2 && distance != 4)
+			}
+		}
+	}
+
+	if version > 1 {
+		count := version/7 + 2
+		step := (version*4 + count*2 + 1) / (count*2 - 2) * 2
+		positions := make([]int, count)
+		positions[0] = 6
+		for i, position := count-1, qr.size-7; i >= 1; i, position = i-1, position-step {
+			positions[i] = position
+		}
+		for i, x := range positions {
+			for j, y := range positions {
+				// Skip the three corners with finder patterns.
+				if (i == 0 && j == 0) || (i == 0 && j == count-1) || (i == count-1 && j == 0) {
+					continue
+				}
+				for dy := -2; dy <= 2; dy++ {
+					for dx := -2; dx <= 2; dx++ {
+						distance := abs(dx)
+						if abs(dy) > distance {
+							distance = abs(dy)
+						}
+						qr.setFunction(x+dx, y+dy, distance != 1)
+					}
+				}
+			}
+		}
+	}
+
+	// Reserve the format information's modules, which are drawn once the mask is picked.
+	qr.drawFormatBits(0)
+
+	if version >= 7 {
+		remainder := version
+		for i := 0; i < 12; i++ {
+			remainder = (remainder << 1) ^ ((remainder >> 11) * 0x1F25)
+		}
+		bits := version<<12 | remainder
+		for i := 0; i < 18; i++ {
+			dark := (bits>>uint(i))&1 == 1
+			a, b := qr.size-11+i%3, i/3
+			qr.setFunction(a, b, dark)
+			qr.setFunction(b, a, dark)
+		}
+	}
+}
+
+// drawFormatBits draws the format information, for level L and mask, in both of its places.
+func (qr *qrCode) drawFormatBits(mask int) {
+	data := 1<<3 | mask // Level L is 01.
+	remainder := data
+	for i := 0; i < 10; i++ {
+		remainder = (remainder << 1) ^ ((remainder >> 9) * 0x537)
+	}
+	bits := (data<<10 | remainder) ^ 0x5412
+	bit := func(i int) bool { return (bits>>uint(i))&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		qr.setFunction(8, i, bit(i))
+	}
+	qr.setFunction(8, 7, bit(6))
+	qr.setFunction(8, 8, bit(7))
+	qr.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.setFunction(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		qr.setFunction(qr.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunction(8, qr.size-15+i, bit(i))
+	}
+	qr.setFunction(8, qr.size-8, true)
+}
+
+// drawCodewords fills the modules that aren't part of a function pattern with codewords, in the zigzag order of the standard:
+// up and down pairs of columns, from the right.
+func (qr *qrCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		// The vertical timing pattern takes a whole column.
+		if right == 6 {
+			right = 5
+		}
+		for vertical := 0; vertical < qr.size; vertical++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vertical
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vertical
+				}
+				if !qr.isFunction[y][x] && i < len(codewords)*8 {
+					qr.modules[y][x] = (codewords[i/8]>>uint(7-i%8))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the modules that aren't part of a function pattern where mask says to. Applying it twice undoes it.
+func (qr *qrCode) applyMask(mask int) {
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !qr.isFunction[y][x] {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to scan, by the standard's rules: long runs of one color,
+// 2x2 boxes of one color, patterns that look like finders, and an unbalanced number of dark modules.
+func (qr *qrCode) penalty() int {
+	penalty := 0
+	finderLike := func(line []bool, i int) bool {
+		// 1:1:3:1:1 dark:light:dark:light:dark, with four light modules on one side.
+		pattern := []bool{true, false, true, true, true, false, true}
+		for j, dark := range pattern {
+			if i+j >= len(line) || line[i+j] != dark {
+				return false
+			}
+		}
+		lightRun := func(from, to int) bool {
+			for k := from; k < to; k++ {
+				if k >= 0 && k < len(line) && line[k] {
+					return false
+				}
+			}
+			return true
+		}
+		return lightRun(i-4, i) || lightRun(i+7, i+11)
+	}
+	scoreLine := func(line []bool) {
+		run := 1
+		for i := 1; i <= len(line); i++ {
+			if i < len(line) && line[i] == line[i-1] {
+				run++
+				continue
+			}
+			if run >= 5 {
+				penalty += run - 2
+			}
+			run = 1
+		}
+		for i := range line {
+			if finderLike(line, i) {
+				penalty += 40
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < qr.size; y++ {
+		scoreLine(qr.modules[y])
+		column := make([]bool, qr.size)
+		for x := 0; x < qr.size; x++ {
+			column[x] = qr.modules[x][y]
+			if qr.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				color := qr.modules[y][x]
+				if qr.modules[y-1][x] == color && qr.modules[y][x-1] == color && qr.modules[y-1][x-1] == color {
+					penalty += 3
+				}
+			}
+		}
+		scoreLine(column)
+	}
+	total := qr.size * qr.size
+	deviation := abs(dark*20 - total*10)
+	penalty += (deviation + total - 1) / total * 10
+	return penalty
+}
+
+// terminal renders the code for a terminal, two rows of modules per line, with a quiet zone around it.
+// It sets both colors, so that dark modules are dark whatever the terminal's theme.
+func (qr *qrCode) terminal() string {
+	const quiet = 2
+	dark := func(x, y int) bool {
+		x, y = x-quiet, y-quiet
+		return x >= 0 && x < qr.size && y >= 0 && y < qr.size && qr.modules[y][x]
+	}
+	var out strings.Builder
+	for y := 0; y < qr.size+2*quiet; y += 2 {
+		for x := 0; x < qr.size+2*quiet; x++ {
+			// The upper half block's foreground is the upper module, and its background the lower one.
+			foreground, background := "97", "107"
+			if dark(x, y) {
+				foreground = "30"
+			}
+			if dark(x, y+1) {
+				background = "40"
+			}
+			out.WriteString("\x1b[" + foreground + ";" + background + "m▀")
+		}
+		out.WriteString("\x1b[0m\n")
+	}
+	return out.String()
+}
+
+// reedSolomonGenerator returns the generator polynomial of degree for Reed-Solomon error correction in GF(2^8),
+// its coefficients highest first, without the leading 1.
+func reedSolomonGenerator(degree int) []byte {
+	generator := make([]byte, degree)
+	generator[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range generator {
+			generator[j] = gfMultiply(generator[j], root)
+			if j+1 < len(generator) {
+				generator[j] ^= generator[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return generator
+}
+
+// reedSolomonRemainder returns the error correction codewords of data.
+func reedSolomonRemainder(data, generator []byte) []byte {
+	remainder := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[len(remainder)-1] = 0
+		for i := range remainder {
+			remainder[i] ^= gfMultiply(generator[i], factor)
+		}
+	}
+	return remainder
+}
+
+// gfMultiply multiplies x and y in GF(2^8), modulo the QR code's polynomial, x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
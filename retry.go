@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// retryBackoff is the wait before the first retry of a failed RPC request. It doubles with each retry.
+const retryBackoff = 500 * time.Millisecond
+
+// idempotentMethods are the JSON-RPC methods that are safe to send again when a request fails.
+// Anything that broadcasts a transaction is deliberately missing, since a request that seemed to
+// fail might have reached the node anyway, and retrying it could send the transaction twice.
+var idempotentMethods = map[string]bool{
+	"eth_blockNumber":           true,
+	"eth_call":                  true,
+	"eth_chainId":               true,
+	"eth_estimateGas":           true,
+	"eth_gasPrice":              true,
+	"eth_getBalance":            true,
+	"eth_getBlockByHash":        true,
+	"eth_getBlockByNumber":      true,
+	"eth_getCode":               true,
+	"eth_getLogs":               true,
+	"eth_getTransactionByHash":  true,
+	"eth_getTransactionCount":   true,
+	"eth_getTransactionReceipt": true,
+	"net_version":               true,
+}
+
+// retryTransport retries idempotent JSON-RPC requests over HTTP that fail with a network error
+// or a status code that suggests the node is temporarily unavailable or rate-limiting us.
+type retryTransport struct {
+	http.RoundTripper
+	retries int
+}
+
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil {
+		return t.RoundTripper.RoundTrip(req)
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	retries := t.retries
+	if !isIdempotentRequest(body) {
+		retries = 0
+	}
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		attemptReq := req.Clone(req.Context())
+		attemptReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		resp, err := t.RoundTripper.RoundTrip(attemptReq)
+		if attempt >= retries || !isTransientFailure(resp, err) {
+			return resp, err
+		}
+		if err == nil {
+			resp.Body.Close()
+			err = fmt.Errorf("%v", resp.Status)
+		}
+		fmt.Fprintf(os.Stderr, "Request to the node failed (%v). Retrying in %v...\n", err, backoff)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isIdempotentRequest reports whether body is a JSON-RPC request, or batch of requests,
+// that only calls idempotent methods.
+func isIdempotentRequest(body []byte) bool {
+	type request struct {
+		Method string
+	}
+	var batch []request
+	if json.Unmarshal(body, &batch) != nil {
+		var single request
+		if json.Unmarshal(body, &single) != nil {
+			return false
+		}
+		batch = []request{single}
+	}
+	for _, r := range batch {
+		if !idempotentMethods[r.Method] {
+			return false
+		}
+	}
+	return len(batch) > 0
+}
+
+// isTransientFailure reports whether a request that got resp and err might succeed if sent again.
+func isTransientFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// getRetries returns how many times to retry failed idempotent requests to the node.
+func getRetries() int {
+	retries := viper.GetInt("retries")
+	if retries < 0 {
+		fatalf("--retries must not be negative, but it's %v\n", retries)
+	}
+	return retries
+}
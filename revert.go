@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/xerrors"
+)
+
+var (
+	// errorStringSelector is the selector of Error(string), which require and revert with a reason use.
+	errorStringSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+	// panicSelector is the selector of Panic(uint256), which failed asserts and arithmetic errors use.
+	panicSelector = []byte{0x4e, 0x48, 0x7b, 0x71}
+)
+
+// panicReasons describes the codes of Panic(uint256), as listed in the Solidity docs.
+var panicReasons = map[uint64]string{
+	0x00: "generic compiler panic",
+	0x01: "assertion failed",
+	0x11: "arithmetic overflow or underflow",
+	0x12: "division or modulo by zero",
+	0x21: "conversion to an invalid enum value",
+	0x22: "incorrectly encoded storage byte array",
+	0x31: "pop from an empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call to an uninitialized function",
+}
+
+// explainRevert returns err with its revert data, if it has any, decoded using theABI.
+// Errors without revert data are returned as they are.
+func explainRevert(theABI abi.ABI, err error) error {
+	dataErr, ok := err.(rpc.DataError)
+	if !ok {
+		return err
+	}
+	hexData, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return err
+	}
+	data, decodeErr := hexutil.Decode(hexData)
+	if decodeErr != nil || len(data) == 0 {
+		return err
+	}
+	return withClass(revertError, xerrors.New("execution reverted: "+decodeRevert(theABI, data)))
+}
+
+// decodeRevert describes revert data: as one of theABI's custom errors if it matches one,
+// or one of the --extra-abi files' errors, as from a contract theABI's calls,
+// then as a require or assert failure, and otherwise as raw hex.
+func decodeRevert(theABI abi.ABI, data []byte) string {
+	if otherEventABIs == nil {
+		otherEventABIs = loadOtherEventABIs()
+	}
+	var customErrors []abi.Error
+	for _, errorABI := range append([]abi.ABI{theABI}, otherEventABIs...) {
+		for _, customError := range errorABI.Errors {
+			customErrors = append(customErrors, customError)
+		}
+	}
+	if len(data) >= 4 {
+		for _, customError := range customErrors {
+			if !bytes.Equal(customError.ID[:4], data[:4]) {
+				continue
+			}
+			values, err := customError.Inputs.Unpack(data[4:])
+			if err != nil {
+				break
+			}
+			fields := make([]string, len(values))
+			for i, input := range customError.Inputs {
+				fields[i] = displayValue(input.Type, values[i])
+				if input.Name != "" {
+					fields[i] = input.Name + ": " + fields[i]
+				}
+			}
+			return fmt.Sprintf("%v(%v)", customError.Name, strings.Join(fields, ", "))
+		}
+		if bytes.Equal(data[:4], errorStringSelector) {
+			if reason, err := abi.UnpackRevert(data); err == nil {
+				return fmt.Sprintf("%q", reason)
+			}
+		}
+		if bytes.Equal(data[:4], panicSelector) && len(data) == 36 {
+			code := new(big.Int).SetBytes(data[4:])
+			if reason, ok := panicReasons[code.Uint64()]; ok && code.IsUint64() {
+				return fmt.Sprintf("Panic(%#x): %v", code, reason)
+			}
+			return fmt.Sprintf("Panic(%#x)", code)
+		}
+	}
+	return hexutil.Encode(data)
+}
+
+// replayRevert explains why tx, which was mined in receipt's block but reverted, failed.
+// The reason isn't stored on chain, so it replays the transaction as a call on the state the block started from.
+// The transactions before it in the same block aren't applied first, so if one of them changed what it depended on,
+// the replay might revert differently, or not at all.
+func replayRevert(theABI abi.ABI, tx *types.Transaction, receipt *types.Receipt) error {
+	reverted := xerrors.New("transaction reverted")
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return reverted
+	}
+	_, err = getNode().CallContract(context.Background(), ethereum.CallMsg{
+		From:     from,
+		To:       tx.To(),
+		Gas:      tx.Gas(),
+		GasPrice: tx.GasPrice(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	}, new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1)))
+	if err == nil {
+		return reverted
+	}
+	return xerrors.Errorf("transaction reverted: %w", explainRevert(theABI, err))
+}
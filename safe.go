@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+// safeABI is the part of the Safe (formerly Gnosis Safe) multisig's interface poke uses.
+const safeABI = `[
+	{"type":"function","name":"nonce","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"getThreshold","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"isOwner","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"domainSeparator","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"bytes32"}]},
+	{"type":"function","name":"getTransactionHash","stateMutability":"view","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"},{"name":"operation","type":"uint8"},{"name":"safeTxGas","type":"uint256"},{"name":"baseGas","type":"uint256"},{"name":"gasPrice","type":"uint256"},{"name":"gasToken","type":"address"},{"name":"refundReceiver","type":"address"},{"name":"_nonce","type":"uint256"}],"outputs":[{"name":"","type":"bytes32"}]},
+	{"type":"function","name":"execTransaction","stateMutability":"payable","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"},{"name":"operation","type":"uint8"},{"name":"safeTxGas","type":"uint256"},{"name":"baseGas","type":"uint256"},{"name":"gasPrice","type":"uint256"},{"name":"gasToken","type":"address"},{"name":"refundReceiver","type":"address"},{"name":"signatures","type":"bytes"}],"outputs":[{"name":"","type":"bool"}]}
+]`
+
+// safeTxTypeHash is the EIP-712 type hash of the Safe's SafeTx struct.
+var safeTxTypeHash = crypto.Keccak256Hash([]byte("SafeTx(address to,uint256 value,bytes data,uint8 operation,uint256 safeTxGas,uint256 baseGas,uint256 gasPrice,address gasToken,address refundReceiver,uint256 nonce)"))
+
+// safeServices are the URLs of the Safe Transaction Service for each chain id, for when --safe-service-url isn't given.
+var safeServices = map[int64]string{
+	1:        "https://safe-transaction-mainnet.safe.global",
+	11155111: "https://safe-transaction-sepolia.safe.global",
+	17000:    "https://safe-transaction-holesky.safe.global",
+	10:       "https://safe-transaction-optimism.safe.global",
+	42161:    "https://safe-transaction-arbitrum.safe.global",
+	8453:     "https://safe-transaction-base.safe.global",
+	84532:    "https://safe-transaction-base-sepolia.safe.global",
+	137:      "https://safe-transaction-polygon.safe.global",
+	100:      "https://safe-transaction-gnosis-chain.safe.global",
+}
+
+// safeNumber is a number in the Safe Transaction Service's JSON, which has both numbers and strings of digits.
+type safeNumber big.Int
+
+func (n *safeNumber) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		s = "0"
+	}
+	if _, ok := (*big.Int)(n).SetString(s, 10); !ok {
+		return xerrors.Errorf("%v isn't a number", string(data))
+	}
+	return nil
+}
+
+func (n *safeNumber) int() *big.Int {
+	return (*big.Int)(n)
+}
+
+// safeTransaction is a transaction of a Safe, as the Safe Transaction Service records it.
+type safeTransaction struct {
+	Safe           common.Address `json:"safe"`
+	To             common.Address `json:"to"`
+	Value          safeNumber     `json:"value"`
+	Data           hexutil.Bytes  `json:"data"`
+	Operation      uint8          `json:"operation"`
+	SafeTxGas      safeNumber     `json:"safeTxGas"`
+	BaseGas        safeNumber     `json:"baseGas"`
+	GasPrice       safeNumber     `json:"gasPrice"`
+	GasToken       common.Address `json:"gasToken"`
+	RefundReceiver common.Address `json:"refundReceiver"`
+	Nonce          safeNumber     `json:"nonce"`
+	IsExecuted     bool           `json:"isExecuted"`
+	Confirmations  []struct {
+		Owner     common.Address `json:"owner"`
+		Signature hexutil.Bytes  `json:"signature"`
+	} `json:"confirmations"`
+}
+
+// hash returns the EIP-712 hash of the SafeTx struct of tx, which, with the Safe's domain separator, owners sign.
+func (tx *safeTransaction) hash() common.Hash {
+	uint256, _ := abi.NewType("uint256", "", nil)
+	uint8, _ := abi.NewType("uint8", "", nil)
+	address, _ := abi.NewType("address", "", nil)
+	bytes32, _ := abi.NewType("bytes32", "", nil)
+	encoded, err := abi.Arguments{
+		{Type: bytes32}, {Type: address}, {Type: uint256}, {Type: bytes32}, {Type: uint8},
+		{Type: uint256}, {Type: uint256}, {Type: uint256}, {Type: address}, {Type: address}, {Type: uint256},
+	}.Pack(
+		safeTxTypeHash, tx.To, tx.Value.int(), crypto.Keccak256Hash(tx.Data), tx.Operation,
+		tx.SafeTxGas.int(), tx.BaseGas.int(), tx.GasPrice.int(), tx.GasToken, tx.RefundReceiver, tx.Nonce.int(),
+	)
+	check(err, "encoding Safe transaction")
+	return crypto.Keccak256Hash(encoded)
+}
+
+// getSafeAddress returns the Safe that --safe proposes transactions to, or the zero address without --safe.
+func getSafeAddress() (common.Address, bool) {
+	if viper.GetString("safe") == "" {
+		return common.Address{}, false
+	}
+	return parseAddress(viper.GetString("safe")), true
+}
+
+// getSafe returns the Safe at address, to call and send transactions to.
+func getSafe(address common.Address) *bind.BoundContract {
+	theABI, err := abi.JSON(strings.NewReader(safeABI))
+	check(err, "parsing Safe ABI")
+	return bind.NewBoundContract(address, theABI, caller{getNode()}, transactor{Client: getNode(), abi: &theABI}, getNode())
+}
+
+// callSafe calls the view function method of safe, and returns its single result.
+func callSafe(safe *bind.BoundContract, method string, args ...interface{}) interface{} {
+	var results []interface{}
+	check(safe.Call(callOpts(), &results, method, args...), "calling the Safe's "+method+"; is it a Safe?")
+	return results[0]
+}
+
+// getSafeServiceURL returns the URL of the Safe Transaction Service for the current chain.
+func getSafeServiceURL() string {
+	if url := viper.GetString("safe-service-url"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	url, ok := safeServices[getChainID().Int64()]
+	if !ok {
+		fatalf("There's no known Safe Transaction Service for chain %v; give its URL with --safe-service-url\n", getChainID())
+	}
+	return url
+}
+
+// safeServiceRequest sends a request to the Safe Transaction Service, with body as JSON if it isn't nil,
+// and decodes the JSON response into result, if it isn't nil.
+func safeServiceRequest(method, path string, body, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, getSafeServiceURL()+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		var message bytes.Buffer
+		message.ReadFrom(resp.Body)
+		return xerrors.Errorf("HTTP %v: %v", resp.Status, strings.TrimSpace(message.String()))
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// nextSafeNonce returns the nonce for a new transaction of the Safe at address: the Safe's nonce,
+// or, if transactions are already queued in the Safe Transaction Service, the one after them.
+func nextSafeNonce(address common.Address) *big.Int {
+	nonce := callSafe(getSafe(address), "nonce").(*big.Int)
+	var queued struct {
+		Results []struct {
+			Nonce safeNumber `json:"nonce"`
+		} `json:"results"`
+	}
+	path := fmt.Sprintf("/api/v1/safes/%v/multisig-transactions/?executed=false&nonce__gte=%v&ordering=-nonce&limit=1", address.Hex(), nonce)
+	check(safeServiceRequest("GET", path, nil, &queued), "looking up queued Safe transactions")
+	if len(queued.Results) > 0 && queued.Results[0].Nonce.int().Cmp(nonce) >= 0 {
+		nonce = new(big.Int).Add(queued.Results[0].Nonce.int(), big.NewInt(1))
+	}
+	return nonce
+}
+
+// signSafeTransaction signs tx with the `from` account, which must be an owner of the Safe, and returns its hash, the safeTxHash,
+// and the signature. The hash is checked against the Safe's own getTransactionHash.
+func signSafeTransaction(tx *safeTransaction) (common.Hash, []byte) {
+	safe := getSafe(tx.Safe)
+	if !callSafe(safe, "isOwner", getAddress()).(bool) {
+		fatalf("%v isn't an owner of the Safe %v, so it can't sign its transactions\n", getAddress().Hex(), tx.Safe.Hex())
+	}
+	domainSeparator := common.Hash(callSafe(safe, "domainSeparator").([32]byte))
+	safeTxHash := crypto.Keccak256Hash([]byte{0x19, 0x01}, domainSeparator.Bytes(), tx.hash().Bytes())
+	expected := common.Hash(callSafe(safe, "getTransactionHash", tx.To, tx.Value.int(), []byte(tx.Data), tx.Operation,
+		tx.SafeTxGas.int(), tx.BaseGas.int(), tx.GasPrice.int(), tx.GasToken, tx.RefundReceiver, tx.Nonce.int()).([32]byte))
+	if safeTxHash != expected {
+		fatalf("The Safe hashes the transaction as %v, not %v; Safes older than 1.3.0 aren't supported\n", expected.Hex(), safeTxHash.Hex())
+	}
+	return safeTxHash, signTypedData(domainSeparator, tx.hash())
+}
+
+// proposeSafeTransaction proposes tx, as a transaction of the Safe --safe gives, to the Safe Transaction Service,
+// signed by the `from` account, instead of sending it. Other owners then confirm it, and anyone can execute it with `safe exec`.
+func proposeSafeTransaction(tx *types.Transaction) {
+	if tx.To() == nil {
+		fatal("A Safe can't deploy contracts with a plain transaction, so deployments can't be proposed to one")
+	}
+	address, _ := getSafeAddress()
+	safeTx := &safeTransaction{Safe: address, To: *tx.To(), Data: tx.Data()}
+	safeTx.Value = safeNumber(*tx.Value())
+	safeTx.Nonce = safeNumber(*nextSafeNonce(address))
+	safeTxHash, sig := signSafeTransaction(safeTx)
+
+	data := interface{}(nil)
+	if len(safeTx.Data) > 0 {
+		data = safeTx.Data
+	}
+	proposal := map[string]interface{}{
+		"to":                      safeTx.To.Hex(),
+		"value":                   safeTx.Value.int().String(),
+		"data":                    data,
+		"operation":               safeTx.Operation,
+		"safeTxGas":               "0",
+		"baseGas":                 "0",
+		"gasPrice":                "0",
+		"gasToken":                common.Address{}.Hex(),
+		"refundReceiver":          common.Address{}.Hex(),
+		"nonce":                   safeTx.Nonce.int().String(),
+		"contractTransactionHash": safeTxHash.Hex(),
+		"sender":                  getAddress().Hex(),
+		"signature":               hexutil.Encode(sig),
+		"origin":                  "poke",
+	}
+	path := fmt.Sprintf("/api/v1/safes/%v/multisig-transactions/", address.Hex())
+	check(safeServiceRequest("POST", path, proposal, nil), "proposing the transaction to the Safe Transaction Service")
+	if jsonOutput() {
+		printJSON(map[string]interface{}{"safe": address.Hex(), "nonce": safeTx.Nonce.int().String(), "safeTxHash": safeTxHash.Hex()})
+		return
+	}
+	fmt.Printf("Proposed to Safe %v, at nonce %v.\n", address.Hex(), safeTx.Nonce.int())
+	fmt.Printf("Safe transaction hash: %v\n", safeTxHash.Hex())
+}
+
+// getSafeTransaction looks up the Safe transaction with the hash safeTxHash in the Safe Transaction Service.
+func getSafeTransaction(safeTxHash string) *safeTransaction {
+	var tx safeTransaction
+	check(safeServiceRequest("GET", "/api/v1/multisig-transactions/"+common.HexToHash(safeTxHash).Hex()+"/", nil, &tx),
+		"looking up Safe transaction "+safeTxHash)
+	return &tx
+}
+
+// safeCmd groups the commands for transactions proposed to a Safe with --safe. theABI describes the transactions' events.
+func safeCmd(theABI abi.ABI) *cobra.Command {
+	safe := &cobra.Command{
+		Use:   "safe",
+		Short: "Confirm and execute transactions proposed to a Safe multisig with --safe",
+		Example: "  poke Token.sol transfer @1 1e18 --safe 0x1234... -F hardware\n" +
+			"  poke Token.sol safe confirm 0xabcd... -F @1\n" +
+			"  poke Token.sol safe exec 0xabcd...",
+	}
+	safe.AddCommand(
+		&cobra.Command{
+			Use:   "confirm <safeTxHash>",
+			Short: "Sign a proposed Safe transaction with the `from` account, an owner of the Safe",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				tx := getSafeTransaction(args[0])
+				safeTxHash, sig := signSafeTransaction(tx)
+				path := "/api/v1/multisig-transactions/" + safeTxHash.Hex() + "/confirmations/"
+				check(safeServiceRequest("POST", path, map[string]string{"signature": hexutil.Encode(sig)}, nil), "confirming the transaction")
+				fmt.Printf("Confirmed Safe transaction %v.\n", safeTxHash.Hex())
+			},
+		},
+		&cobra.Command{
+			Use:   "exec <safeTxHash>",
+			Short: "Execute a Safe transaction that has been confirmed by enough owners, from the `from` account",
+			Long: "Sends the Safe's execTransaction, with the confirmations the Safe Transaction Service has collected. " +
+				"If the `from` account is an owner that hasn't confirmed the transaction, sending it counts as its confirmation.",
+			Args: cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				// execTransaction itself is sent, not proposed.
+				viper.Set("safe", "")
+				tx := getSafeTransaction(args[0])
+				if tx.IsExecuted {
+					fatalf("Safe transaction %v has already been executed\n", args[0])
+				}
+				safe := getSafe(tx.Safe)
+				threshold := callSafe(safe, "getThreshold").(*big.Int)
+
+				confirmations := tx.Confirmations
+				sort.Slice(confirmations, func(i, j int) bool {
+					return bytes.Compare(confirmations[i].Owner.Bytes(), confirmations[j].Owner.Bytes()) < 0
+				})
+				type signature struct {
+					owner common.Address
+					sig   []byte
+				}
+				var signatures []signature
+				executor, confirmed := getAddress(), false
+				for _, confirmation := range confirmations {
+					signatures = append(signatures, signature{confirmation.Owner, confirmation.Signature})
+					confirmed = confirmed || confirmation.Owner == executor
+				}
+				// An owner sending execTransaction approves it with a signature with v = 1, holding just its address.
+				if !confirmed && int64(len(signatures)) < threshold.Int64() && callSafe(safe, "isOwner", executor).(bool) {
+					sig := append(common.LeftPadBytes(executor.Bytes(), 32), append(make([]byte, 32), 1)...)
+					signatures = append(signatures, signature{executor, sig})
+					sort.Slice(signatures, func(i, j int) bool {
+						return bytes.Compare(signatures[i].owner.Bytes(), signatures[j].owner.Bytes()) < 0
+					})
+				}
+				if int64(len(signatures)) < threshold.Int64() {
+					fatalf("Safe transaction %v has %v of the %v confirmations it needs\n", args[0], len(signatures), threshold)
+				}
+				var packed []byte
+				for _, s := range signatures {
+					packed = append(packed, s.sig...)
+				}
+
+				sent, err := safe.Transact(getTxnOpts(), "execTransaction", tx.To, tx.Value.int(), []byte(tx.Data), tx.Operation,
+					tx.SafeTxGas.int(), tx.BaseGas.int(), tx.GasPrice.int(), tx.GasToken, tx.RefundReceiver, packed)
+				log("execTransaction", sent, theABI, err)
+			},
+		},
+	)
+	return safe
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+// scriptVarRegexp matches a script variable, like $token.
+var scriptVarRegexp = regexp.MustCompile(`\$[A-Za-z_][A-Za-z0-9_]*`)
+
+// scriptResult is the result of the command a script ran last, for `$var = command` to save:
+// what a call or utility printed with printResult, a transaction's hash, or a new contract's address.
+var scriptResult string
+
+// scriptFromStdin is set while a script is read from stdin, which is then no use for asking the user anything.
+var scriptFromStdin bool
+
+// runCmd runs a script of commands against root, like --script.
+func runCmd(root *cobra.Command) *cobra.Command {
+	return &cobra.Command{
+		Use:   "run <script|->",
+		Short: "Run the poke commands in a script file, or stdin, one per line",
+		Long: "Runs each line of a script as a poke command, with the same contract and node connection. " +
+			"$name = <command> saves the command's result in a variable for later lines: a call's result, a transaction's hash, or a new contract's address. " +
+			"use <address> sends later commands to another contract. - reads the script from stdin.",
+		Example: "  poke Token.sol run deploy.poke\n" +
+			"  echo 'balanceOf @1' | poke Token.sol run -",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runScript(root, args[0]); err != nil {
+				fatal(withClass(usageError, err))
+			}
+		},
+	}
+}
+
+// runScript runs each line of the script file, or stdin if it's -, as a poke command against root,
+// sharing the parsed contract and the node connection between them.
+//
+// Besides commands, a script can contain:
+//
+//	# comments
+//	$token = deploy arg...   deploy, and save the new contract's address as $token
+//	$supply = totalSupply    save the result of a command, or a transaction's hash, as $supply
+//	use $token               send later commands to the contract at $token (or any address)
+//
+// A deployment also makes the new contract the target of later commands.
+// Flags given on a line only apply to that line.
+// The script stops at the first command that fails.
+func runScript(root *cobra.Command, file string) error {
+	in := os.Stdin
+	if file == "-" {
+		file = "stdin"
+		scriptFromStdin = true
+		defer func() {
+			scriptFromStdin = false
+		}()
+	} else {
+		f, err := os.Open(file)
+		if err != nil {
+			return xerrors.Errorf("opening script: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(in)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "> %v\n", line)
+
+		words, err := splitScriptLine(line)
+		if err != nil {
+			return xerrors.Errorf("%v:%v: %w", file, lineNumber, err)
+		}
+		var assignTo string
+		if len(words) > 2 && scriptVarRegexp.MatchString(words[0]) && words[1] == "=" {
+			assignTo = words[0]
+			words = words[2:]
+		}
+		for i, word := range words {
+			var undefined string
+			words[i] = scriptVarRegexp.ReplaceAllStringFunc(word, func(v string) string {
+				value, ok := vars[v]
+				if !ok {
+					undefined = v
+				}
+				return value
+			})
+			if undefined != "" {
+				return xerrors.Errorf("%v:%v: %v is not defined", file, lineNumber, undefined)
+			}
+		}
+
+		switch {
+		case words[0] == "use":
+			if len(words) != 2 {
+				return xerrors.Errorf("%v:%v: use takes exactly one address", file, lineNumber)
+			}
+			if assignTo != "" {
+				return xerrors.Errorf("%v:%v: use has no result to save in %v", file, lineNumber, assignTo)
+			}
+			viper.Set("address", parseAddress(words[1]).Hex())
+		default:
+			scriptResult = ""
+			restoreFlags := saveFlags(root.PersistentFlags())
+			root.SetArgs(protectNegativeNumbers(words, root.PersistentFlags()))
+			if err := root.Execute(); err != nil {
+				return xerrors.Errorf("%v:%v: %w", file, lineNumber, err)
+			}
+			if err := restoreFlags(); err != nil {
+				return xerrors.Errorf("%v:%v: %w", file, lineNumber, err)
+			}
+			if assignTo != "" {
+				if scriptResult == "" {
+					return xerrors.Errorf("%v:%v: %v has no result to save in %v", file, lineNumber, words[0], assignTo)
+				}
+				vars[assignTo] = scriptResult
+			}
+		}
+		// The next command might target a different contract.
+		deployment = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return xerrors.Errorf("reading script: %w", err)
+	}
+	return nil
+}
+
+// saveFlags records the values of flags, and returns a function that restores them.
+// Slice flags can't be restored, since setting them again appends to them,
+// so it fails if any of those changed.
+func saveFlags(flags *pflag.FlagSet) func() error {
+	type saved struct {
+		value   string
+		changed bool
+	}
+	values := make(map[*pflag.Flag]saved)
+	flags.VisitAll(func(f *pflag.Flag) {
+		values[f] = saved{f.Value.String(), f.Changed}
+	})
+	return func() error {
+		var err error
+		flags.VisitAll(func(f *pflag.Flag) {
+			old := values[f]
+			if err != nil || f.Value.String() == old.value {
+				return
+			}
+			if strings.HasSuffix(f.Value.Type(), "Slice") {
+				err = xerrors.Errorf("--%v can't be set within a script; give it on the command line instead", f.Name)
+				return
+			}
+			err = f.Value.Set(old.value)
+			f.Changed = old.changed
+		})
+		return err
+	}
+}
+
+// splitScriptLine splits a line of a script into words at whitespace, like a shell would.
+// Single and double quotes group words, so that JSON arguments can contain spaces.
+func splitScriptLine(line string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\\':
+			escaped, inWord = true, true
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, xerrors.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
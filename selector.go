@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// parseTypeList parses a comma-separated list of ABI types, like "address,uint256".
+// Surrounding parentheses are optional, and an empty list means no types.
+func parseTypeList(s string) abi.Arguments {
+	s = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "("), ")")
+	if s == "" {
+		return nil
+	}
+	var args abi.Arguments
+	for _, typeName := range strings.Split(s, ",") {
+		t, err := abi.NewType(strings.TrimSpace(typeName), "", nil)
+		check(err, fmt.Sprintf("parsing type %q", typeName))
+		args = append(args, abi.Argument{Type: t})
+	}
+	return args
+}
+
+// selectorCalldata builds calldata from a hex-encoded 4-byte selector, a list of
+// argument types as accepted by parseTypeList, and the arguments themselves.
+func selectorCalldata(selector string, types string, args []string) []byte {
+	sel, err := hexutil.Decode(selector)
+	check(err, fmt.Sprintf("decoding selector %q", selector))
+	if len(sel) != 4 {
+		fatalf("a selector is 4 bytes long, but %q is %v bytes\n", selector, len(sel))
+	}
+	inputs := parseTypeList(types)
+	if len(inputs) != len(args) {
+		fatalf("got %v argument types, but %v arguments\n", len(inputs), len(args))
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = parseArg(inputs[i].Type, arg)
+	}
+	packed, err := inputs.Pack(values...)
+	check(err, "encoding arguments")
+	return append(sel, packed...)
+}
+
+var callSelectorCmd = &cobra.Command{
+	Use:   "call-selector <0xselector> <arg-types> [arg...]",
+	Short: "Call a method by its raw 4-byte selector, without state changes",
+	Long: "Calls a method by its selector, even if it isn't in the contract's ABI.\n" +
+		"arg-types is a comma-separated list of the method's argument types, which may be empty.\n" +
+		"The result is printed as hex, unless its types are given with --returns.",
+	Example: "  poke call-selector 0x70a08231 address @1 --returns uint256\n" +
+		"  poke call-selector 0x18160ddd ''",
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		address := getContractAddress()
+		data := selectorCalldata(args[0], args[1], args[2:])
+		result, err := caller{getNode()}.CallContract(
+			context.Background(),
+			ethereum.CallMsg{From: getAddress(), To: &address, Data: data},
+			callBlock(),
+		)
+		check(err, "calling "+args[0])
+
+		outputs := parseTypeList(viper.GetString("returns"))
+		if len(outputs) == 0 {
+			printResult(hexutil.Encode(result))
+			return
+		}
+		values, err := outputs.UnpackValues(result)
+		check(err, "decoding result")
+		if jsonOutput() {
+			printOutputs(outputs, values)
+			return
+		}
+		for i, output := range outputs {
+			fmt.Println(displayValue(output.Type, values[i]))
+		}
+	},
+}
+
+// sendSelectorCmd sends a transaction calling a method by its raw 4-byte selector.
+// It uses abi to decode the events the transaction emits.
+func sendSelectorCmd(abi abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "send-selector <0xselector> <arg-types> [arg...]",
+		Short: "Send a transaction calling a method by its raw 4-byte selector",
+		Long: "Sends a transaction calling a method by its selector, even if it isn't in the contract's ABI.\n" +
+			"arg-types is a comma-separated list of the method's argument types, which may be empty.",
+		Example: "  poke send-selector 0xa9059cbb address,uint256 @1 1e18",
+		Args:    cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			address := getContractAddress()
+			data := selectorCalldata(args[0], args[1], args[2:])
+			ctx := context.Background()
+			from := getAddress()
+			nonce := getNonce(from)
+			gas := getGasLimit(ethereum.CallMsg{From: from, To: &address, Data: data})
+			tx, err := getTxnOpts().Signer(
+				from,
+				newTransaction(nonce, &address, nil, gas, data),
+			)
+			check(err, "signing transaction")
+			err = transactor{Client: getNode(), abi: &abi}.SendTransaction(ctx, tx)
+			log(args[0], tx, abi, err)
+		},
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+)
+
+// parseMessage returns the bytes of a message to hash or sign: 0x-prefixed messages are hex-decoded,
+// and anything else is taken as UTF-8 text.
+func parseMessage(s string) []byte {
+	if !strings.HasPrefix(s, "0x") {
+		return []byte(s)
+	}
+	data, err := hexutil.Decode(s)
+	check(err, "decoding hex message")
+	return data
+}
+
+// recoverSigner returns the address that signed hash with sig. sig is 65 bytes, [R || S || V], with V being 0, 1, 27, or 28,
+// or 64 bytes in EIP-2098's compact form, with V folded into the top bit of S.
+func recoverSigner(hash []byte, sig []byte) (common.Address, error) {
+	switch len(sig) {
+	case 65:
+		sig = append([]byte{}, sig...)
+	case 64:
+		// The compact form keeps y parity where a low S always has a zero.
+		sig = append(append([]byte{}, sig...), sig[32]>>7)
+		sig[32] &= 0x7f
+	default:
+		return common.Address{}, xerrors.Errorf("a signature is 65 bytes, or 64 in compact form, not %v", len(sig))
+	}
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+var verifySigCmd = &cobra.Command{
+	Use:   "verify-sig <message> <signature> [expected-address]",
+	Short: "Show who signed a message, EIP-191 personal_sign style, or check that an address did",
+	Long: "Recovers the address that signed a message, as sign-message signs it, and prints it. The message is taken as sign-message takes it. " +
+		"Given an expected address, it exits with an error unless that address signed the message.",
+	Example: "  poke verify-sig 'hello world' 0x1234...\n  poke verify-sig 'hello world' 0x1234... 0x5409ED021D9299bf6814279A6A1411A7e866A631",
+	Args:    cobra.RangeArgs(2, 3),
+	Run: func(cmd *cobra.Command, args []string) {
+		sig, err := hexutil.Decode(args[1])
+		check(err, "decoding signature")
+		signer, err := recoverSigner(accounts.TextHash(parseMessage(args[0])), sig)
+		check(err, "recovering signer")
+		if len(args) == 3 {
+			if expected := parseAddress(args[2]); signer != expected {
+				fatalf("Invalid: the message was signed by %v, not %v\n", signer.Hex(), expected.Hex())
+			}
+		}
+		switch {
+		case jsonOutput():
+			printJSON(map[string]string{"signer": signer.Hex()})
+		case len(args) == 2:
+			fmt.Printf("Signed by %v\n", signer.Hex())
+		default:
+			fmt.Printf("Valid: signed by %v\n", signer.Hex())
+		}
+	},
+}
+
+var ecrecoverCmd = &cobra.Command{
+	Use:   "ecrecover <hash> <signature>",
+	Short: "Print the address that signed a 32-byte hash, as Solidity's ecrecover does",
+	Long: "Recovers the address that signed a hash, without the prefix sign-message adds, as for EIP-712 signatures " +
+		"or a hash signed directly. Signatures may be 65 bytes or 64 in EIP-2098's compact form.",
+	Example: "  poke ecrecover 0x1c8aff950685c2ed4bc3174f3472287b56d9517b9c948127319a09a7a36deac8 0x1234...",
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		hash, err := hexutil.Decode(args[0])
+		check(err, "decoding hash")
+		if len(hash) != 32 {
+			fatalf("A hash is 32 bytes, not %v\n", len(hash))
+		}
+		sig, err := hexutil.Decode(args[1])
+		check(err, "decoding signature")
+		signer, err := recoverSigner(hash, sig)
+		check(err, "recovering signer")
+		printResult(signer.Hex())
+	},
+}
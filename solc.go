@@ -0,0 +1,262 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+// solcBinURL is where solc release binaries are downloaded from.
+const solcBinURL = "https://binaries.soliditylang.org"
+
+// solcList is the part of solc-bin's list.json that poke uses.
+type solcList struct {
+	Builds []struct {
+		Path    string
+		Version string
+		SHA256  string
+	}
+	Releases map[string]string // version to file name
+}
+
+// pragmaRegexp matches the version pragma of a Solidity source file, like "pragma solidity ^0.8.0;".
+var pragmaRegexp = regexp.MustCompile(`pragma\s+solidity\s+([^;]+);`)
+
+// solcVersionRegexp matches the version in the output of solc --version, like "Version: 0.8.24+commit.e11b9ed9.Linux.g++".
+var solcVersionRegexp = regexp.MustCompile(`Version: (\d+\.\d+\.\d+)`)
+
+// operatorSpaceRegexp matches the space solc allows between an operator and its version, as in ">= 0.8.0".
+var operatorSpaceRegexp = regexp.MustCompile(`([<>=^~]+)\s+`)
+
+// solcCommand returns the solc binary to compile solFile with: the one on the PATH, unless --solc-version
+// names a release, or is "auto" to pick the newest release that satisfies solFile's version pragma.
+// Releases are downloaded on first use, and cached in ~/.poke/solc/<version>.
+func solcCommand(solFile string) string {
+	version := viper.GetString("solc-version")
+	if version == "" {
+		return "solc"
+	}
+	platform := solcPlatform()
+	var list *solcList
+	if version == "auto" {
+		list = fetchSolcList(platform)
+		source, err := ioutil.ReadFile(solFile)
+		check(err, "reading "+solFile)
+		match := pragmaRegexp.FindSubmatch(source)
+		if match == nil {
+			fatalf("%v has no version pragma for --solc-version auto to follow\n", solFile)
+		}
+		version = newestSolcRelease(*list, string(match[1]))
+		if version == "" {
+			fatalf("No solc release satisfies %v's pragma %q\n", solFile, match[1])
+		}
+	}
+	version = strings.TrimPrefix(version, "v")
+
+	home, err := os.UserHomeDir()
+	check(err, "finding home directory")
+	binary := filepath.Join(home, ".poke", "solc", version, "solc")
+	if _, err := os.Stat(binary); err == nil {
+		return binary
+	}
+	if list == nil {
+		list = fetchSolcList(platform)
+	}
+	file, ok := list.Releases[version]
+	if !ok {
+		fatalf("There's no solc release %q for %v\n", version, platform)
+	}
+	fmt.Fprintf(os.Stderr, "Downloading solc %v...\n", version)
+	check(downloadSolc(*list, platform, file, binary), "downloading solc "+version)
+	return binary
+}
+
+// solcAllowPaths returns the value of solc's --allow-paths flag, allowing the --allow-paths directories and extra.
+func solcAllowPaths(extra ...string) string {
+	return "*," + strings.Join(append(viper.GetStringSlice("allow-paths"), extra...), ",")
+}
+
+// solcPlatform returns the name solc-bin gives the platform poke is running on.
+func solcPlatform() string {
+	switch runtime.GOOS {
+	case "linux":
+		if runtime.GOARCH == "amd64" {
+			return "linux-amd64"
+		}
+	case "darwin":
+		// The macOS builds run on Apple silicon too.
+		return "macosx-amd64"
+	case "windows":
+		return "windows-amd64"
+	}
+	fatalf("solc-bin has no builds for %v/%v. Install solc yourself, and drop --solc-version.\n", runtime.GOOS, runtime.GOARCH)
+	return ""
+}
+
+// fetchSolcList downloads the list of solc builds for platform.
+func fetchSolcList(platform string) *solcList {
+	resp, err := http.Get(solcBinURL + "/" + platform + "/list.json")
+	check(err, "fetching the list of solc releases")
+	defer resp.Body.Close()
+	var list solcList
+	check(json.NewDecoder(resp.Body).Decode(&list), "decoding the list of solc releases")
+	return &list
+}
+
+// downloadSolc downloads the solc build in file to binary, checking it against the list's hash.
+func downloadSolc(list solcList, platform, file, binary string) error {
+	var want string
+	for _, build := range list.Builds {
+		if build.Path == file {
+			want = strings.TrimPrefix(build.SHA256, "0x")
+		}
+	}
+	resp, err := http.Get(solcBinURL + "/" + platform + "/" + file)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return xerrors.Errorf("HTTP %v", resp.Status)
+	}
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(contents)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return xerrors.Errorf("the download's SHA-256 hash is %v, but should be %v", got, want)
+	}
+	if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so an interrupted download isn't mistaken for a cached binary.
+	if err := ioutil.WriteFile(binary+".tmp", contents, 0755); err != nil {
+		return err
+	}
+	return os.Rename(binary+".tmp", binary)
+}
+
+// newestSolcRelease returns the newest release in list that satisfies the version pragma constraint,
+// or "" if none do.
+func newestSolcRelease(list solcList, constraint string) string {
+	var newest string
+	var newestVersion [3]int
+	for release := range list.Releases {
+		version, ok := parseSolcVersion(release)
+		if !ok || !satisfiesPragma(version, constraint) {
+			continue
+		}
+		if newest == "" || compareVersions(version, newestVersion) > 0 {
+			newest, newestVersion = release, version
+		}
+	}
+	return newest
+}
+
+// solcBinaryVersion returns the version of the solc binary, as it reports with --version.
+func solcBinaryVersion(solc string) ([3]int, bool) {
+	out, err := exec.Command(solc, "--version").Output()
+	if err != nil {
+		return [3]int{}, false
+	}
+	match := solcVersionRegexp.FindSubmatch(out)
+	if match == nil {
+		return [3]int{}, false
+	}
+	return parseSolcVersion(string(match[1]))
+}
+
+// parseSolcVersion parses a version like "0.8.24" or "0.8".
+func parseSolcVersion(s string) ([3]int, bool) {
+	var version [3]int
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(parts) > 3 {
+		return version, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return version, false
+		}
+		version[i] = n
+	}
+	return version, true
+}
+
+// compareVersions returns -1, 0, or 1 as a is older than, the same as, or newer than b.
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		} else if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// satisfiesPragma reports whether version satisfies a Solidity version pragma, like "^0.8.0"
+// or ">=0.7.0 <0.9.0 || 0.6.12". It supports the operators solc itself does.
+func satisfiesPragma(version [3]int, constraint string) bool {
+	constraint = operatorSpaceRegexp.ReplaceAllString(constraint, "$1")
+	for _, alternative := range strings.Split(constraint, "||") {
+		satisfied := true
+		for _, term := range strings.Fields(alternative) {
+			if !satisfiesTerm(version, term) {
+				satisfied = false
+			}
+		}
+		if satisfied {
+			return true
+		}
+	}
+	return false
+}
+
+// satisfiesTerm reports whether version satisfies a single term of a version pragma, like ">=0.7.0".
+func satisfiesTerm(version [3]int, term string) bool {
+	op := strings.TrimRight(term, "0123456789.v")
+	bound, ok := parseSolcVersion(term[len(op):])
+	if !ok {
+		fatalf("Can't parse %q in the version pragma\n", term)
+	}
+	cmp := compareVersions(version, bound)
+	switch op {
+	case "", "=":
+		return cmp == 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case "^":
+		// ^0.8.1 allows 0.8.x from 0.8.1 on; ^1.2.3 allows 1.x.x from 1.2.3 on.
+		upper := [3]int{bound[0] + 1, 0, 0}
+		if bound[0] == 0 {
+			upper = [3]int{0, bound[1] + 1, 0}
+		}
+		return cmp >= 0 && compareVersions(version, upper) < 0
+	case "~":
+		upper := [3]int{bound[0], bound[1] + 1, 0}
+		return cmp >= 0 && compareVersions(version, upper) < 0
+	}
+	fatalf("Unknown operator %q in the version pragma\n", op)
+	return false
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+// combinedJSON is solc's --combined-json output, which poke also builds from standard-json output.
+type combinedJSON struct {
+	Contracts map[string]contractOutput // keyed by "file:ContractName"
+	Sources   map[string]struct {
+		AST json.RawMessage
+	}
+}
+
+// contractOutput is the compiler output for a single contract, with each field JSON-encoded as a string.
+type contractOutput struct {
+	ABI           string
+	Bin           string
+	BinRuntime    string `json:"bin-runtime"`
+	UserDoc       string
+	DevDoc        string
+	Metadata      string
+	StorageLayout string `json:"storage-layout"`
+}
+
+// standardJSON is the part of solc's --standard-json output that poke uses.
+// Unlike combined-json, contracts are nested by file and then name, and their fields are plain JSON.
+type standardJSON struct {
+	Contracts map[string]map[string]struct {
+		ABI           json.RawMessage
+		DevDoc        json.RawMessage
+		UserDoc       json.RawMessage
+		Metadata      string
+		StorageLayout json.RawMessage
+		EVM           struct {
+			Bytecode struct {
+				Object string
+			}
+			DeployedBytecode struct {
+				Object string
+			}
+		}
+	}
+	Sources map[string]struct {
+		AST json.RawMessage
+	}
+	Errors []struct {
+		Severity         string
+		FormattedMessage string
+	}
+}
+
+// isStandardJSON reports whether compiled is solc's standard-json output, rather than combined-json.
+func isStandardJSON(compiled []byte) bool {
+	var probe struct {
+		Contracts map[string]map[string]json.RawMessage
+		Errors    json.RawMessage
+	}
+	if json.Unmarshal(compiled, &probe) != nil {
+		return false
+	}
+	if len(probe.Contracts) == 0 {
+		// Failed compilations only have errors.
+		return len(probe.Errors) > 0
+	}
+	for _, contracts := range probe.Contracts {
+		for _, contract := range contracts {
+			// In combined-json, these are the fields of a single contract, like "abi" and "bin".
+			var fields map[string]json.RawMessage
+			if json.Unmarshal(contract, &fields) != nil {
+				return false
+			}
+			_, hasABI := fields["abi"]
+			_, hasEVM := fields["evm"]
+			return hasABI || hasEVM
+		}
+	}
+	return false
+}
+
+// parseStandardJSON converts solc's standard-json output into the layout of its combined-json output.
+func parseStandardJSON(compiled []byte) (combinedJSON, error) {
+	var output standardJSON
+	if err := json.Unmarshal(compiled, &output); err != nil {
+		return combinedJSON{}, xerrors.Errorf("failed to decode solc standard-json output: %w", err)
+	}
+
+	var errors []string
+	for _, e := range output.Errors {
+		if e.Severity == "error" {
+			errors = append(errors, e.FormattedMessage)
+		}
+	}
+	if len(errors) > 0 {
+		return combinedJSON{}, xerrors.Errorf("solc reported errors:\n%v", strings.Join(errors, "\n"))
+	}
+
+	parsed := combinedJSON{
+		Contracts: make(map[string]contractOutput),
+		Sources:   output.Sources,
+	}
+	for file, contracts := range output.Contracts {
+		for name, contract := range contracts {
+			parsed.Contracts[file+":"+name] = contractOutput{
+				ABI:           string(contract.ABI),
+				Bin:           contract.EVM.Bytecode.Object,
+				BinRuntime:    contract.EVM.DeployedBytecode.Object,
+				UserDoc:       string(contract.UserDoc),
+				DevDoc:        string(contract.DevDoc),
+				Metadata:      contract.Metadata,
+				StorageLayout: string(contract.StorageLayout),
+			}
+		}
+	}
+	return parsed, nil
+}
+
+// isCompilerInput reports whether contents is a solc standard-json input file, to compile, rather than compiler output.
+func isCompilerInput(contents []byte) bool {
+	var probe struct {
+		Language string
+		Sources  map[string]json.RawMessage
+	}
+	if json.Unmarshal(contents, &probe) != nil {
+		return false
+	}
+	return probe.Language != "" && len(probe.Sources) > 0
+}
+
+// compileStandardJSON compiles the standard-json input in inputFile, whose contents are input, and returns
+// solc's standard-json output. It asks for all the output poke uses, and adds the --remap remappings.
+// Source paths are relative to inputFile's directory.
+func compileStandardJSON(inputFile string, input []byte) ([]byte, error) {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(input, &parsed); err != nil {
+		return nil, xerrors.Errorf("failed to decode %v: %w", inputFile, err)
+	}
+	settings, _ := parsed["settings"].(map[string]interface{})
+	if settings == nil {
+		settings = make(map[string]interface{})
+		parsed["settings"] = settings
+	}
+	settings["outputSelection"] = map[string]interface{}{
+		"*": map[string][]string{
+			"*": {"abi", "evm.bytecode.object", "evm.deployedBytecode.object", "userdoc", "devdoc", "metadata", "storageLayout"},
+			"":  {"ast"},
+		},
+	}
+	if remaps := viper.GetStringSlice("remap"); len(remaps) > 0 {
+		remappings, _ := settings["remappings"].([]interface{})
+		for _, remap := range remaps {
+			remappings = append(remappings, remap)
+		}
+		settings["remappings"] = remappings
+	}
+	input, err := json.Marshal(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(solcCommand(inputFile), "--standard-json", "--allow-paths", solcAllowPaths())
+	cmd.Dir = filepath.Dir(inputFile)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	compiled, err := cmd.Output()
+	if err != nil {
+		return nil, xerrors.Errorf("solc: %w", err)
+	}
+	return compiled, nil
+}
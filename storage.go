@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/cobra"
+)
+
+// storageLayout is solc's storage layout output: where each state variable lives, and how its type is stored.
+type storageLayout struct {
+	Storage []storageVariable
+	Types   map[string]storageType
+}
+
+// storageVariable is a state variable, or a struct member, in a storage layout.
+// Its slot is relative to the struct's, for a member.
+type storageVariable struct {
+	Label  string
+	Offset int
+	Slot   string
+	Type   string
+}
+
+// storageType describes how values of a type are stored.
+// Encoding is "inplace" for values and fixed-size arrays and structs, or "mapping", "dynamic_array", or "bytes".
+type storageType struct {
+	Encoding      string
+	Label         string
+	NumberOfBytes string
+	Key           string            // of a mapping
+	Value         string            // of a mapping
+	Base          string            // of an array
+	Members       []storageVariable // of a struct
+}
+
+// staticArrayRegexp matches the length of a fixed-size array type's label, like "uint256[3]".
+var staticArrayRegexp = regexp.MustCompile(`\[(\d+)\]$`)
+
+// storageLocation is where a value is in storage: the slot, and the offset of its lowest byte in it, from the right.
+type storageLocation struct {
+	slot   *big.Int
+	offset int
+	typeID string
+}
+
+var storageAtCmd = &cobra.Command{
+	Use:     "storage-at <slot>",
+	Short:   "Print the raw 32-byte word at a storage slot of the contract",
+	Example: "  poke Token.sol storage-at 0\n  poke Token.sol storage-at 0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		printResult(readStorage(parseStorageWord(args[0]).Big()).Hex())
+	},
+}
+
+// storageCmd reads a state variable of the contract, finding it with the storage layout in layoutJSON.
+func storageCmd(layoutJSON string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "storage <variable> [key-or-member...]",
+		Short: "Read a state variable straight from storage, even if it has no getter",
+		Long: "Finds a state variable in the contract's storage with the storage layout from the compiler, and prints its value.\n" +
+			"Each argument after the variable picks a part of it: a key of a mapping, an index of an array, or a member of a struct.\n" +
+			"Without a key or index, an array prints its length. The storage layout comes from compiling the contract with solc " +
+			"0.5.13 or later; Foundry and Hardhat only include it if configured to.",
+		Example: "  poke Token.sol storage totalSupply\n" +
+			"  poke Token.sol storage allowances @1 @2\n" +
+			"  poke Vault.sol storage positions @1 amount",
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if layoutJSON == "" {
+				fatal("The compiler output has no storage layout. Compile the .sol file with poke, using solc 0.5.13 or later, or ask your build tool for the storageLayout output.")
+			}
+			var layout storageLayout
+			check(json.Unmarshal([]byte(layoutJSON), &layout), "decoding storage layout")
+
+			var location *storageLocation
+			for _, variable := range layout.Storage {
+				if variable.Label == args[0] {
+					location = &storageLocation{parseStorageSlot(variable.Slot), variable.Offset, variable.Type}
+				}
+			}
+			if location == nil {
+				fatalf("The contract has no state variable named %q\n", args[0])
+			}
+			for _, arg := range args[1:] {
+				location = layout.index(location, arg)
+			}
+			printStorageValue(layout, location)
+		},
+	}
+}
+
+// index returns the location of the part of the value at location that arg picks.
+func (layout storageLayout) index(location *storageLocation, arg string) *storageLocation {
+	t := layout.Types[location.typeID]
+	switch {
+	case t.Encoding == "mapping":
+		key := encodeMappingKey(layout.Types[t.Key], arg)
+		slot := crypto.Keccak256(key, common.BigToHash(location.slot).Bytes())
+		return &storageLocation{new(big.Int).SetBytes(slot), 0, t.Value}
+	case t.Encoding == "dynamic_array":
+		length := readStorage(location.slot).Big()
+		base := new(big.Int).SetBytes(crypto.Keccak256(common.BigToHash(location.slot).Bytes()))
+		return layout.element(base, t.Base, length, arg)
+	case t.Base != "":
+		match := staticArrayRegexp.FindStringSubmatch(t.Label)
+		if match == nil {
+			fatalf("Can't tell the length of %v\n", t.Label)
+		}
+		length, _ := new(big.Int).SetString(match[1], 10)
+		return layout.element(location.slot, t.Base, length, arg)
+	case len(t.Members) > 0:
+		name := strings.TrimPrefix(arg, ".")
+		for _, member := range t.Members {
+			if member.Label == name {
+				slot := new(big.Int).Add(location.slot, parseStorageSlot(member.Slot))
+				return &storageLocation{slot, member.Offset, member.Type}
+			}
+		}
+		fatalf("%v has no member named %q\n", t.Label, name)
+	}
+	fatalf("A %v can't be indexed by %q\n", t.Label, arg)
+	return nil
+}
+
+// element returns the location of the element arg, an index, of the array of length elements of type
+// elementType that starts at slot base. Elements of 16 bytes or less are packed several to a slot.
+func (layout storageLayout) element(base *big.Int, elementType string, length *big.Int, arg string) *storageLocation {
+	i := parseInteger(arg, false, 256)
+	if i.Cmp(length) >= 0 {
+		fatalf("Index %v is out of bounds; the array's length is %v\n", i, length)
+	}
+	size, err := strconv.Atoi(layout.Types[elementType].NumberOfBytes)
+	check(err, "reading the size of "+elementType)
+	if size > 16 {
+		slots := (size + 31) / 32
+		slot := new(big.Int).Add(base, new(big.Int).Mul(i, big.NewInt(int64(slots))))
+		return &storageLocation{slot, 0, elementType}
+	}
+	perSlot := int64(32 / size)
+	slot := new(big.Int).Add(base, new(big.Int).Div(i, big.NewInt(perSlot)))
+	offset := int(new(big.Int).Mod(i, big.NewInt(perSlot)).Int64()) * size
+	return &storageLocation{slot, offset, elementType}
+}
+
+// printStorageValue reads and prints the value at location. Structs print each of their members,
+// and dynamic arrays their length; mappings and fixed-size arrays need a key or index.
+func printStorageValue(layout storageLayout, location *storageLocation) {
+	t := layout.Types[location.typeID]
+	switch {
+	case t.Encoding == "mapping":
+		fatalf("%v is a mapping, so it needs a key\n", t.Label)
+	case t.Encoding == "dynamic_array":
+		fmt.Printf("length: %v\n", readStorage(location.slot).Big())
+	case t.Encoding == "bytes":
+		fmt.Println(readStorageBytes(location.slot, t.Label))
+	case t.Base != "":
+		fatalf("%v is an array, so it needs an index\n", t.Label)
+	case len(t.Members) > 0:
+		for _, member := range t.Members {
+			memberType := layout.Types[member.Type]
+			if (memberType.Encoding != "inplace" && memberType.Encoding != "bytes") || memberType.Base != "" || len(memberType.Members) > 0 {
+				fmt.Printf("%v: (%v)\n", member.Label, memberType.Label)
+				continue
+			}
+			fmt.Printf("%v: ", member.Label)
+			slot := new(big.Int).Add(location.slot, parseStorageSlot(member.Slot))
+			printStorageValue(layout, &storageLocation{slot, member.Offset, member.Type})
+		}
+	default:
+		fmt.Println(decodeStorageValue(t, readStorage(location.slot), location.offset))
+	}
+}
+
+// decodeStorageValue decodes the value of type t that's offset bytes from the right of word.
+func decodeStorageValue(t storageType, word common.Hash, offset int) string {
+	size, err := strconv.Atoi(t.NumberOfBytes)
+	check(err, "reading the size of "+t.Label)
+	value := word[32-offset-size : 32-offset]
+	abiType := storageABIType(t.Label)
+
+	// Lay the value out as the ABI would, to decode it the same way as a call's result.
+	var encoded common.Hash
+	switch {
+	case abiType.T == abi.FixedBytesTy:
+		copy(encoded[:], value)
+	case abiType.T == abi.IntTy && value[0]&0x80 != 0:
+		for i := range encoded {
+			encoded[i] = 0xff
+		}
+		fallthrough
+	default:
+		copy(encoded[32-size:], value)
+	}
+	values, err := abi.Arguments{{Type: abiType}}.Unpack(encoded[:])
+	check(err, "decoding "+t.Label)
+	return displayValue(abiType, values[0])
+}
+
+// storageABIType returns the ABI type of values with the storage layout label, like "uint256" or "contract IERC20".
+func storageABIType(label string) abi.Type {
+	switch {
+	case strings.HasPrefix(label, "contract "), label == "address payable":
+		label = "address"
+	case strings.HasPrefix(label, "enum "):
+		label = "uint8"
+	}
+	t, err := abi.NewType(label, "", nil)
+	if err != nil {
+		fatalf("Can't decode values of type %v from storage\n", label)
+	}
+	return t
+}
+
+// encodeMappingKey encodes arg as a key of a mapping with keys of type t, as Solidity hashes it:
+// value types padded to a word, and strings and bytes as they are.
+func encodeMappingKey(t storageType, arg string) []byte {
+	switch t.Label {
+	case "string":
+		return []byte(arg)
+	case "bytes":
+		key, err := hexutil.Decode(arg)
+		check(err, "parsing bytes key")
+		return key
+	}
+	abiType := storageABIType(t.Label)
+	key, err := abi.Arguments{{Type: abiType}}.Pack(parseArg(abiType, arg))
+	check(err, "encoding key "+arg)
+	return key
+}
+
+// readStorageBytes reads the string or bytes value at slot. Values under 32 bytes share the slot with their
+// length; longer ones keep only their length there, and their contents from the slot's hash on.
+func readStorageBytes(slot *big.Int, label string) string {
+	word := readStorage(slot)
+	var contents []byte
+	if word[31]&1 == 0 {
+		contents = word[:word[31]/2]
+	} else {
+		length := new(big.Int).Rsh(word.Big(), 1).Int64()
+		start := new(big.Int).SetBytes(crypto.Keccak256(common.BigToHash(slot).Bytes()))
+		for i := int64(0); int64(len(contents)) < length; i++ {
+			chunk := readStorage(new(big.Int).Add(start, big.NewInt(i)))
+			contents = append(contents, chunk[:]...)
+		}
+		contents = contents[:length]
+	}
+	if label == "string" {
+		return string(contents)
+	}
+	return hexutil.Encode(contents)
+}
+
+// readStorage reads the word at slot of the contract's storage, at --block.
+func readStorage(slot *big.Int) common.Hash {
+	word, err := getNode().StorageAt(context.Background(), getContractAddress(), common.BigToHash(slot), callBlock())
+	check(err, "reading storage")
+	return common.BytesToHash(word)
+}
+
+// parseStorageSlot parses a slot number from a storage layout, where it's a decimal string.
+func parseStorageSlot(s string) *big.Int {
+	slot, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		fatalf("Bad slot %q in the storage layout\n", s)
+	}
+	return slot
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	// timestampRegexp matches a timestamp relative to the latest block, like now, now+1h, or now-7d.
+	timestampRegexp = regexp.MustCompile(`^now(?:([+-])(.*))?$`)
+	// durationPartRegexp matches each part of a duration, like the 1d and 12h of 1d12h.
+	durationPartRegexp = regexp.MustCompile(`([0-9]+)([smhdw])`)
+)
+
+// durationUnits are the units a relative timestamp's duration can use.
+var durationUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// isTimestamp reports whether s is a timestamp relative to the latest block, for parseTimestamp.
+func isTimestamp(s string) bool {
+	return timestampRegexp.MatchString(s)
+}
+
+// parseTimestamp resolves s, like now or now+1d12h, to a unix time in seconds,
+// relative to the timestamp of the latest block.
+func parseTimestamp(s string) *big.Int {
+	match := timestampRegexp.FindStringSubmatch(s)
+	if match == nil {
+		fatalf("%q isn't a timestamp like now, now+1h, or now-7d\n", s)
+	}
+	var offset time.Duration
+	if match[1] != "" {
+		offset = parseDuration(match[2])
+		if match[1] == "-" {
+			offset = -offset
+		}
+	}
+	header, err := getNode().HeaderByNumber(context.Background(), nil)
+	check(err, "retrieving the latest block to resolve "+s)
+	return big.NewInt(int64(header.Time) + int64(offset/time.Second))
+}
+
+// parseDuration parses a duration like 90s, 1h30m, or 2w, which unlike time.ParseDuration
+// can be in days (d) and weeks (w).
+func parseDuration(s string) time.Duration {
+	parts := durationPartRegexp.FindAllStringSubmatch(s, -1)
+	matched := ""
+	var duration time.Duration
+	for _, part := range parts {
+		matched += part[0]
+		n, err := strconv.ParseInt(part[1], 10, 64)
+		check(err, fmt.Sprintf("parsing duration %q", s))
+		duration += time.Duration(n) * durationUnits[part[2]]
+	}
+	if matched != s || s == "" {
+		fatalf("%q isn't a duration like 30m, 1h, or 7d\n", s)
+	}
+	return duration
+}
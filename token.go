@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// erc20MetadataABI declares the optional ERC20 metadata methods, which token-balance
+// calls even if the contract's own ABI leaves them out.
+const erc20MetadataABI = `[
+	{"constant":true,"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"type":"function"},
+	{"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"type":"function"}
+]`
+
+var (
+	// resolvedDecimals caches the decimals from the `decimals` flag, which may take a call to the token to find.
+	resolvedDecimals    int32
+	hasResolvedDecimals bool
+)
+
+// amountDecimals returns the decimals to scale 256-bit integer arguments and results by, from the `decimals` flag.
+// It's 0, for no scaling, if the flag isn't set, or --raw-amounts overrides it.
+func amountDecimals() int32 {
+	if viper.GetBool("raw-amounts") {
+		return 0
+	}
+	return tokenDecimals()
+}
+
+// tokenDecimals returns the token's decimals from the `decimals` flag: a number, or auto to ask the contract's decimals().
+// It's 0 if the flag isn't set.
+func tokenDecimals() int32 {
+	if hasResolvedDecimals {
+		return resolvedDecimals
+	}
+	switch value := viper.GetString("decimals"); value {
+	case "":
+	case "auto":
+		metadataABI, err := abi.JSON(strings.NewReader(erc20MetadataABI))
+		check(err, "parsing ERC20 metadata ABI")
+		token := bind.NewBoundContract(getContractAddress(), metadataABI, caller{getNode()}, getNode(), getNode())
+		var decimals uint8
+		check(token.Call(callOpts(), &[]interface{}{&decimals}, "decimals"), "calling decimals() for --decimals auto")
+		resolvedDecimals = int32(decimals)
+	default:
+		decimals, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			fatalf("--decimals %q isn't a number of decimals or auto\n", value)
+		}
+		resolvedDecimals = int32(decimals)
+	}
+	hasResolvedDecimals = true
+	return resolvedDecimals
+}
+
+// parseAmount parses s as a 256-bit integer argument, scaled by amountDecimals, unless it starts with raw:
+// to give the number as it is, like a token id or a timestamp among amounts.
+func parseAmount(s string, signed bool) *big.Int {
+	if raw := strings.TrimPrefix(s, "raw:"); raw != s {
+		return parseInteger(raw, signed, 256)
+	}
+	return parseScaledInteger(s, signed, 256, amountDecimals())
+}
+
+// displayAmount displays i, a 256-bit integer, in whole tokens if --decimals is set, and like displayBigInt otherwise.
+func displayAmount(i *big.Int) string {
+	if decimals := amountDecimals(); decimals != 0 {
+		return decimal.NewFromBigInt(i, -decimals).String()
+	}
+	return displayBigInt(i)
+}
+
+// tokenBalanceCmd shows an ERC20 balance, scaled by the token's decimals.
+func tokenBalanceCmd(theABI abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:     "token-balance <holder>",
+		Short:   "Show an ERC20 token balance, scaled by the token's decimals",
+		Example: "  poke Token.sol token-balance @1",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			holder := parseAddress(args[0])
+			balance := new(*big.Int)
+			check(getDeployment(theABI).Call(callOpts(), &[]interface{}{balance}, "balanceOf", holder), "calling balanceOf")
+
+			metadataABI, err := abi.JSON(strings.NewReader(erc20MetadataABI))
+			check(err, "parsing ERC20 metadata ABI")
+			token := bind.NewBoundContract(getContractAddress(), metadataABI, caller{getNode()}, getNode(), getNode())
+
+			// Tokens don't have to implement decimals or symbol, so fall back to showing the raw balance.
+			var decimals uint8
+			if err := token.Call(callOpts(), &[]interface{}{&decimals}, "decimals"); err != nil {
+				decimals = 0
+			}
+			var symbol string
+			if err := token.Call(callOpts(), &[]interface{}{&symbol}, "symbol"); err != nil {
+				symbol = ""
+			}
+
+			amount := decimal.NewFromBigInt(*balance, -int32(decimals)).String()
+			if jsonOutput() {
+				printJSON(map[string]string{"balance": amount, "symbol": symbol})
+				return
+			}
+			fmt.Println(strings.TrimSpace(amount + " " + symbol))
+		},
+	}
+}
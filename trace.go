@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/shopspring/decimal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// callFrame is a call in a transaction's call tree, as geth's callTracer reports it.
+type callFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     hexutil.Uint64  `json:"gas"`
+	GasUsed hexutil.Uint64  `json:"gasUsed"`
+	Input   hexutil.Bytes   `json:"input"`
+	Output  hexutil.Bytes   `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Calls   []callFrame     `json:"calls,omitempty"`
+}
+
+// parityTrace is one call of a transaction, as the trace_* methods of Erigon, Nethermind,
+// and other nodes that followed OpenEthereum report it. They list every call in a flat list,
+// in which traceAddress locates each call in the tree.
+type parityTrace struct {
+	Type   string
+	Action struct {
+		CallType string
+		From     common.Address
+		To       *common.Address
+		Value    *hexutil.Big
+		Gas      hexutil.Uint64
+		Input    hexutil.Bytes
+		Init     hexutil.Bytes
+	}
+	Result *struct {
+		GasUsed hexutil.Uint64
+		Output  hexutil.Bytes
+		Address *common.Address
+	}
+	Error        string
+	TraceAddress []int
+}
+
+// traceCmd prints the calls a mined transaction made.
+func traceCmd(theABI abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "trace <txhash>",
+		Short: "Print the tree of calls a transaction made, with their arguments, results, gas, and reverts",
+		Long: "Replays a transaction with the node's debug_traceTransaction, or trace_transaction, and prints each call it made, " +
+			"nested under the call that made it. Calls are decoded with the contract's ABI and the --extra-abi files.\n" +
+			"Not all nodes support tracing; public RPC providers often don't.",
+		Example: "  poke Vault.sol trace 0x1234...",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			hash := common.HexToHash(args[0])
+			var root callFrame
+			// geth's callTracer gives the tree of calls, rather than every step of the EVM.
+			debugErr := getRPC().Call(&root, "debug_traceTransaction", hash, map[string]interface{}{"tracer": "callTracer"})
+			if debugErr != nil {
+				var traces []parityTrace
+				if err := getRPC().Call(&traces, "trace_transaction", hash); err != nil {
+					fatalf("The node can't trace transactions: debug_traceTransaction failed (%v), and so did trace_transaction (%v)\n", debugErr, err)
+				}
+				root = parityCallTree(traces)
+			}
+			printCallTree(theABI, root)
+		},
+	}
+}
+
+// traceCallCmd prints the calls a call to one of theABI's functions would make, without sending a transaction.
+func traceCallCmd(theABI abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:   "trace-call <method> [arg...]",
+		Short: "Print the tree of calls a call would make, without sending a transaction",
+		Long: "Simulates calling a function of the contract from the `from` account, at --block and with any --override state overrides, " +
+			"with the node's debug_traceCall, or trace_call, and prints each call it would make, as trace does.",
+		Example: "  poke Vault.sol trace-call deposit 1e18 --value 1",
+		Args:    cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			method, err := lookupMethod(theABI, args[0])
+			check(err, "trace-call")
+			if len(args)-1 != len(method.Inputs) {
+				fatalf("%v takes %v arguments, but got %v\n", method.Sig, len(method.Inputs), len(args)-1)
+			}
+			data, err := theABI.Pack(method.Name, parseArgs(method.Sig, method.Inputs, args[1:])...)
+			check(err, "encoding arguments")
+			address := getContractAddress()
+			msg := ethereum.CallMsg{From: getAddress(), To: &address, Data: data, Gas: uint64(viper.GetInt64("gas-limit"))}
+			if value := viper.GetString("value"); value != "" {
+				msg.Value = parseValue(value)
+			}
+
+			block := blockTag(callBlock())
+			config := map[string]interface{}{"tracer": "callTracer"}
+			if overrides := getOverrides(); len(overrides) > 0 {
+				config["stateOverrides"] = overrides
+			}
+			var root callFrame
+			debugErr := getRPC().Call(&root, "debug_traceCall", callArg(msg), block, config)
+			if debugErr != nil {
+				// trace_call takes no state overrides, so it can't stand in for debug_traceCall with them.
+				if len(getOverrides()) > 0 {
+					fatalf("The node can't trace calls with --override: debug_traceCall failed (%v)\n", debugErr)
+				}
+				var result struct {
+					Trace []parityTrace
+				}
+				if err := getRPC().Call(&result, "trace_call", callArg(msg), []string{"trace"}, block); err != nil {
+					fatalf("The node can't trace calls: debug_traceCall failed (%v), and so did trace_call (%v)\n", debugErr, err)
+				}
+				root = parityCallTree(result.Trace)
+			}
+			printCallTree(theABI, root)
+		},
+	}
+}
+
+// parityCallTree assembles traces, from a trace_* method, into the call tree callTracer would give.
+func parityCallTree(traces []parityTrace) callFrame {
+	if len(traces) == 0 {
+		fatal("The node returned an empty trace")
+	}
+	var root callFrame
+	for _, trace := range traces {
+		frame := callFrame{
+			Type:  strings.ToUpper(trace.Type),
+			From:  trace.Action.From,
+			To:    trace.Action.To,
+			Value: trace.Action.Value,
+			Gas:   trace.Action.Gas,
+			Input: trace.Action.Input,
+			Error: trace.Error,
+		}
+		if trace.Action.CallType != "" {
+			frame.Type = strings.ToUpper(trace.Action.CallType)
+		}
+		if trace.Type == "create" {
+			frame.Input = trace.Action.Init
+		}
+		if trace.Result != nil {
+			frame.GasUsed = trace.Result.GasUsed
+			frame.Output = trace.Result.Output
+			if trace.Result.Address != nil {
+				frame.To = trace.Result.Address
+			}
+		}
+		if len(trace.TraceAddress) == 0 {
+			root = frame
+			continue
+		}
+		// Traces come in depth-first order, so a call's parent is always already in the tree.
+		parent := &root
+		for _, i := range trace.TraceAddress[:len(trace.TraceAddress)-1] {
+			parent = &parent.Calls[i]
+		}
+		parent.Calls = append(parent.Calls, frame)
+	}
+	return root
+}
+
+// printCallTree prints root and the calls it made, one per line, each indented under its caller.
+// With --output json, it prints the tree as callTracer gives it instead.
+func printCallTree(theABI abi.ABI, root callFrame) {
+	if jsonOutput() {
+		printJSON(root)
+		return
+	}
+	var printFrame func(frame callFrame, depth int)
+	printFrame = func(frame callFrame, depth int) {
+		fmt.Println(strings.Repeat("  ", depth) + describeFrame(theABI, frame))
+		for _, call := range frame.Calls {
+			printFrame(call, depth+1)
+		}
+	}
+	printFrame(root, 0)
+}
+
+// describeFrame describes a call in a call tree: its type, the contract called, the function and arguments,
+// any ETH sent, the gas it used, and its result, or why it reverted.
+func describeFrame(theABI abi.ABI, frame callFrame) string {
+	to := "(new contract)"
+	if frame.To != nil {
+		to = frame.To.Hex()
+	}
+	description := frame.Type + " " + to
+	var method *abi.Method
+	var values []interface{}
+	// Contract creations' input is code, not calldata.
+	if !strings.HasPrefix(frame.Type, "CREATE") {
+		if m, v, err := decodeCalldata(theABI, frame.Input); err == nil {
+			method, values = &m, v
+			fields := make([]string, len(values))
+			for i, input := range method.Inputs {
+				fields[i] = displayValue(input.Type, values[i])
+				if input.Name != "" {
+					fields[i] = input.Name + ": " + fields[i]
+				}
+			}
+			description += fmt.Sprintf(" %v(%v)", method.RawName, strings.Join(fields, ", "))
+		} else if len(frame.Input) >= 4 {
+			description += fmt.Sprintf(" %v(%v bytes)", hexutil.Encode(frame.Input[:4]), len(frame.Input)-4)
+		}
+	}
+	if frame.Value != nil && frame.Value.ToInt().Sign() != 0 {
+		description += fmt.Sprintf(" value: %v ETH", decimal.NewFromBigInt(frame.Value.ToInt(), -18))
+	}
+	description += fmt.Sprintf(" [%v gas]", uint64(frame.GasUsed))
+
+	switch {
+	case frame.Error != "" && len(frame.Output) > 0:
+		description += " reverted: " + decodeRevert(theABI, frame.Output)
+	case frame.Error != "":
+		description += " failed: " + frame.Error
+	case method != nil && len(method.Outputs) > 0:
+		results, err := method.Outputs.UnpackValues(frame.Output)
+		if err != nil {
+			description += " -> " + hexutil.Encode(frame.Output)
+			break
+		}
+		fields := make([]string, len(results))
+		for i, output := range method.Outputs {
+			fields[i] = displayValue(output.Type, results[i])
+		}
+		description += " -> " + strings.Join(fields, ", ")
+	}
+	return description
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// parseArg parses s as a value of the ABI type t.
+func parseArg(t abi.Type, s string) interface{} {
+	if t.T == abi.TupleTy {
+		return parseTuple(t, s)
+	}
+	if (t.T == abi.SliceTy || t.T == abi.ArrayTy) && t.Elem.T == abi.TupleTy {
+		return parseTupleArray(t, s)
+	}
+	solType, ok := lookupSolType(t.String())
+	if !ok && (t.T == abi.SliceTy || t.T == abi.ArrayTy) {
+		return parseArrayArg(t, s)
+	}
+	if !ok {
+		fatalf("I don't know how to parse arguments of type %v\n", t)
+	}
+	return solType.parser(s)
+}
+
+// parseTuple parses s as a struct of the tuple type t.
+// s is either a JSON object keyed by field name, or a JSON array of the fields in order.
+// Each field is parsed according to its own type, so it can be a JSON string in any
+// format its type accepts (e.g. "1.5e18"), a JSON number or boolean, or a nested tuple.
+func parseTuple(t abi.Type, s string) interface{} {
+	var fields []json.RawMessage
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "{") {
+		var object map[string]json.RawMessage
+		check(json.Unmarshal([]byte(s), &object), fmt.Sprintf("parsing %q as a JSON object", s))
+		for _, name := range t.TupleRawNames {
+			field, ok := object[name]
+			if !ok {
+				fatalf("missing field %q in %v\n", name, s)
+			}
+			fields = append(fields, field)
+		}
+		if len(object) != len(t.TupleRawNames) {
+			fatalf("%v has unexpected fields; expected exactly: %v\n", s, strings.Join(t.TupleRawNames, ", "))
+		}
+	} else {
+		check(json.Unmarshal([]byte(s), &fields), fmt.Sprintf("parsing %q as a JSON object or array", s))
+		if len(fields) != len(t.TupleElems) {
+			fatalf("%v has %v fields, but %v expects %v\n", s, len(fields), t, len(t.TupleElems))
+		}
+	}
+
+	value := reflect.New(t.GetType()).Elem()
+	for i, elem := range t.TupleElems {
+		value.Field(i).Set(reflect.ValueOf(parseArg(*elem, jsonArg(*elem, fields[i]))))
+	}
+	return value.Interface()
+}
+
+// parseTupleArray parses s, a JSON array, as a slice or array of tuples.
+func parseTupleArray(t abi.Type, s string) interface{} {
+	var elems []json.RawMessage
+	check(json.Unmarshal([]byte(s), &elems), fmt.Sprintf("parsing %q as a JSON array", s))
+	var value reflect.Value
+	if t.T == abi.ArrayTy {
+		if len(elems) != t.Size {
+			fatalf("%v has %v elements, but %v expects %v\n", s, len(elems), t, t.Size)
+		}
+		value = reflect.New(t.GetType()).Elem()
+	} else {
+		value = reflect.MakeSlice(t.GetType(), len(elems), len(elems))
+	}
+	for i, elem := range elems {
+		value.Index(i).Set(reflect.ValueOf(parseTuple(*t.Elem, string(elem))))
+	}
+	return value.Interface()
+}
+
+// jsonArg converts a JSON value into the string form that parseArg expects for type t.
+// Tuples stay JSON, JSON strings are unquoted, and JSON arrays become poke's "[a,b,c]" syntax.
+func jsonArg(t abi.Type, raw json.RawMessage) string {
+	if t.T == abi.TupleTy || ((t.T == abi.SliceTy || t.T == abi.ArrayTy) && t.Elem.T == abi.TupleTy) {
+		return string(raw)
+	}
+	raw = bytes.TrimSpace(raw)
+	if bytes.HasPrefix(raw, []byte(`"`)) {
+		var s string
+		check(json.Unmarshal(raw, &s), fmt.Sprintf("parsing %s as a JSON string", raw))
+		return s
+	}
+	if bytes.HasPrefix(raw, []byte("[")) && t.Elem != nil {
+		var elems []json.RawMessage
+		check(json.Unmarshal(raw, &elems), fmt.Sprintf("parsing %s as a JSON array", raw))
+		parts := make([]string, len(elems))
+		for i, elem := range elems {
+			parts[i] = jsonArg(*t.Elem, elem)
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	}
+	return string(raw)
+}
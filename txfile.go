@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/spf13/cobra"
+)
+
+// buildingTx is set by `tx build`, to have the command it runs print its transaction,
+// unsigned, as a transaction file instead of signing and sending it.
+var buildingTx bool
+
+// txFile is a transaction on its way through `tx build`, `tx sign`, and `tx broadcast`.
+// It records the chain id and sender, which an unsigned transaction doesn't.
+type txFile struct {
+	ChainID     *hexutil.Big       `json:"chainId"`
+	From        common.Address     `json:"from"`
+	Transaction *types.Transaction `json:"transaction"`
+	// Raw is the signed transaction, RLP-encoded, as for send-raw.
+	Raw hexutil.Bytes `json:"raw,omitempty"`
+}
+
+// printTxFile prints the transaction file for tx, sent by from on the chain chainID, as JSON.
+func printTxFile(chainID *big.Int, from common.Address, tx *types.Transaction, signed bool) {
+	file := txFile{ChainID: (*hexutil.Big)(chainID), From: from, Transaction: tx}
+	if signed {
+		raw, err := tx.MarshalBinary()
+		check(err, "encoding transaction")
+		file.Raw = raw
+	}
+	printJSON(file)
+}
+
+// readTxFile reads a transaction file written by `tx build` or `tx sign`.
+func readTxFile(name string) txFile {
+	contents, err := ioutil.ReadFile(name)
+	check(err, "reading transaction file")
+	var file txFile
+	check(json.Unmarshal(contents, &file), "decoding transaction file "+name)
+	if file.ChainID == nil || file.Transaction == nil {
+		fatalf("%v isn't a transaction file: it needs a chainId and a transaction\n", name)
+	}
+	return file
+}
+
+// txCmd groups the commands of the offline signing workflow. theABI describes the transactions' events.
+func txCmd(theABI abi.ABI) *cobra.Command {
+	tx := &cobra.Command{
+		Use:   "tx",
+		Short: "Build, sign, and broadcast a transaction in separate steps, e.g. to sign it on an air-gapped machine",
+		Example: "  poke Token.sol tx build transfer @1 1e18 -F 0x1234... > unsigned.json\n" +
+			"  poke Token.sol tx sign unsigned.json -F hardware > signed.json   # offline\n" +
+			"  poke Token.sol tx broadcast signed.json",
+	}
+	tx.AddCommand(
+		&cobra.Command{
+			Use:   "build <command> [arg...]",
+			Short: "Print the transaction another command would send, unsigned, as JSON",
+			Long: "Runs another poke command, but prints the transaction it would send as a transaction file instead of signing it.\n" +
+				"--from may be a plain address. The node is still used for the nonce, gas, and fees.",
+			Args:               cobra.MinimumNArgs(1),
+			DisableFlagParsing: true,
+			Run: func(cmd *cobra.Command, args []string) {
+				buildingTx = true
+				root := cmd.Root()
+				root.SetArgs(protectNegativeNumbers(args, root.PersistentFlags()))
+				check(root.Execute(), args[0])
+				fatalf("%v didn't send a transaction\n", args[0])
+			},
+		},
+		&cobra.Command{
+			Use:   "sign <file>",
+			Short: "Sign a transaction file from `tx build` with the `from` account. Doesn't use the node.",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				file := readTxFile(args[0])
+				chainID := file.ChainID.ToInt()
+				var signed *types.Transaction
+				var err error
+				if getFrom() == "hardware" {
+					wallet, account := openHardwareWallet()
+					fmt.Fprintln(os.Stderr, "Waiting for you to confirm on the hardware wallet...")
+					signed, err = wallet.SignTx(account, file.Transaction, chainID)
+				} else if isKMS(getFrom()) {
+					signed, err = kmsSignTx(getFrom(), file.Transaction, chainID)
+				} else if getFrom() == walletConnectFrom {
+					signed, err = walletConnectSignTx(file.Transaction, chainID)
+				} else {
+					signed, err = types.SignTx(file.Transaction, types.LatestSignerForChainID(chainID), parseKey(getFrom()))
+				}
+				check(err, "signing transaction")
+				sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+				check(err, "checking signature")
+				if sender != file.From {
+					fatalf("The transaction is from %v, but `from` is %v\n", file.From.Hex(), sender.Hex())
+				}
+				printTxFile(chainID, sender, signed, true)
+			},
+		},
+		&cobra.Command{
+			Use:     "bump <txhash>",
+			Short:   "Speed up a stuck pending transaction by resending it with fees raised by --fee-multiplier",
+			Example: "  poke tx bump 0x1234... --fee-multiplier 1.5",
+			Args:    cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				replaceTransaction(common.HexToHash(args[0]), false)
+			},
+		},
+		&cobra.Command{
+			Use:   "cancel <txhash>",
+			Short: "Cancel a stuck pending transaction by replacing it with an empty transfer to yourself",
+			Long: "Sends a transaction of 0 ETH from the `from` account to itself, with the pending transaction's nonce " +
+				"and fees raised by --fee-multiplier, so that once it's mined the pending transaction can't be.",
+			Args: cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				replaceTransaction(common.HexToHash(args[0]), true)
+			},
+		},
+		&cobra.Command{
+			Use:   "broadcast <file>",
+			Short: "Send a signed transaction file from `tx sign`, and wait for it to be mined",
+			Args:  cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				file := readTxFile(args[0])
+				if len(file.Raw) == 0 {
+					fatalf("%v isn't signed. Sign it with `tx sign` first.\n", args[0])
+				}
+				tx := new(types.Transaction)
+				check(tx.UnmarshalBinary(file.Raw), "decoding signed transaction")
+				checkReplayProtection(tx)
+				sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+				check(err, "recovering sender")
+				transactor{Client: getNode(), abi: &theABI}.approve(sender, tx)
+				err = getNode().SendTransaction(context.Background(), tx)
+				if err == nil {
+					fmt.Fprintln(humanOutput(), "Sent transaction "+tx.Hash().Hex())
+				}
+				log("transaction", tx, theABI, err)
+			},
+		},
+	)
+	return tx
+}
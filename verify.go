@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/xerrors"
+)
+
+// verifyAttempts is how many times to submit a verification while the explorer hasn't yet indexed
+// a just-deployed contract, and how many times to poll for the result, verifyInterval apart.
+const (
+	verifyAttempts = 12
+	verifyInterval = 5 * time.Second
+)
+
+// solcMetadata is the part of solc's metadata needed to recompile a contract exactly.
+type solcMetadata struct {
+	Compiler struct {
+		Version string
+	}
+	Language string
+	Sources  map[string]struct {
+		Content *string
+	}
+	Settings struct {
+		CompilationTarget map[string]string
+		EVMVersion        string `json:"evmVersion"`
+		Libraries         map[string]string
+		Optimizer         json.RawMessage
+		Remappings        []string
+		Metadata          json.RawMessage
+		ViaIR             bool `json:"viaIR"`
+	}
+}
+
+// standardJSONInput builds solc's standard-json input for the compilation described by metadataJSON,
+// reading any sources the metadata doesn't include from disk. It also returns the fully qualified
+// name of the contract, like contracts/Token.sol:Token, and the compiler version.
+func standardJSONInput(metadataJSON string) (input []byte, contract string, version string, err error) {
+	if metadataJSON == "" {
+		return nil, "", "", xerrors.New("the compiler output has no metadata, which verification needs")
+	}
+	var metadata solcMetadata
+	if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+		return nil, "", "", xerrors.Errorf("parsing metadata: %w", err)
+	}
+
+	sources := make(map[string]map[string]string)
+	for file, source := range metadata.Sources {
+		content := source.Content
+		if content == nil {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, "", "", xerrors.Errorf("reading source %v: %w", file, err)
+			}
+			s := string(data)
+			content = &s
+		}
+		sources[file] = map[string]string{"content": *content}
+	}
+
+	// The metadata has libraries as "file:Library": address, but the input nests them by file.
+	libraries := make(map[string]map[string]string)
+	for name, address := range metadata.Settings.Libraries {
+		file, library := "", name
+		if colon := strings.LastIndex(name, ":"); colon != -1 {
+			file, library = name[:colon], name[colon+1:]
+		}
+		if libraries[file] == nil {
+			libraries[file] = make(map[string]string)
+		}
+		libraries[file][library] = address
+	}
+
+	settings := map[string]interface{}{
+		"optimizer":  metadata.Settings.Optimizer,
+		"libraries":  libraries,
+		"remappings": metadata.Settings.Remappings,
+		"outputSelection": map[string]interface{}{
+			"*": map[string]interface{}{"*": []string{"abi", "evm.bytecode", "metadata"}},
+		},
+	}
+	if metadata.Settings.EVMVersion != "" {
+		settings["evmVersion"] = metadata.Settings.EVMVersion
+	}
+	if len(metadata.Settings.Metadata) > 0 {
+		settings["metadata"] = metadata.Settings.Metadata
+	}
+	if metadata.Settings.ViaIR {
+		settings["viaIR"] = true
+	}
+	input, err = json.Marshal(map[string]interface{}{
+		"language": metadata.Language,
+		"sources":  sources,
+		"settings": settings,
+	})
+	if err != nil {
+		return nil, "", "", xerrors.Errorf("encoding standard-json input: %w", err)
+	}
+
+	for file, name := range metadata.Settings.CompilationTarget {
+		contract = file + ":" + name
+	}
+	return input, contract, "v" + metadata.Compiler.Version, nil
+}
+
+// etherscanResponse is the envelope of every Etherscan API response.
+type etherscanResponse struct {
+	Status  string
+	Message string
+	Result  string
+}
+
+// etherscanRequest calls the Etherscan API for the current chain with the given parameters,
+// by POST if post is set and by GET otherwise.
+func etherscanRequest(params url.Values, post bool) (etherscanResponse, error) {
+	params.Set("chainid", getChainID().String())
+	params.Set("apikey", viper.GetString("etherscan-api-key"))
+	apiURL := viper.GetString("etherscan-url")
+
+	var resp *http.Response
+	var err error
+	if post {
+		resp, err = http.PostForm(apiURL, params)
+	} else {
+		resp, err = http.Get(apiURL + "?" + params.Encode())
+	}
+	if err != nil {
+		return etherscanResponse{}, err
+	}
+	defer resp.Body.Close()
+	var response etherscanResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return etherscanResponse{}, xerrors.Errorf("decoding Etherscan response (HTTP %v): %w", resp.Status, err)
+	}
+	return response, nil
+}
+
+// verifyContract submits the source of the contract described by metadata, deployed at address
+// with the hex-encoded constructorArgs, to Etherscan, and waits for the verification result.
+func verifyContract(metadata string, address common.Address, constructorArgs string) {
+	if viper.GetString("etherscan-api-key") == "" {
+		fatal("Verifying needs an Etherscan API key. Set --etherscan-api-key or POKE_ETHERSCAN_API_KEY.")
+	}
+	input, contract, version, err := standardJSONInput(metadata)
+	check(err, "building verification request")
+
+	params := url.Values{
+		"module":          {"contract"},
+		"action":          {"verifysourcecode"},
+		"contractaddress": {address.Hex()},
+		"sourceCode":      {string(input)},
+		"codeformat":      {"solidity-standard-json-input"},
+		"contractname":    {contract},
+		"compilerversion": {version},
+		// Etherscan's API really does spell it this way.
+		"constructorArguements": {strings.TrimPrefix(constructorArgs, "0x")},
+	}
+	var guid string
+	for attempt := 1; ; attempt++ {
+		response, err := etherscanRequest(params, true)
+		check(err, "submitting verification")
+		if response.Status == "1" {
+			guid = response.Result
+			break
+		}
+		if strings.Contains(response.Result, "already verified") {
+			fmt.Fprintln(os.Stderr, "The contract is already verified.")
+			return
+		}
+		// A contract deployed moments ago may not be indexed yet.
+		if !strings.Contains(response.Result, "Unable to locate ContractCode") || attempt == verifyAttempts {
+			fatalf("Etherscan rejected the verification: %v\n", response.Result)
+		}
+		fmt.Fprintln(os.Stderr, "Waiting for Etherscan to index the contract...")
+		time.Sleep(verifyInterval)
+	}
+
+	fmt.Fprintf(os.Stderr, "Submitted %v for verification. Waiting for the result...\n", contract)
+	for attempt := 1; attempt <= verifyAttempts; attempt++ {
+		time.Sleep(verifyInterval)
+		response, err := etherscanRequest(url.Values{
+			"module": {"contract"},
+			"action": {"checkverifystatus"},
+			"guid":   {guid},
+		}, false)
+		check(err, "checking verification status")
+		switch {
+		case strings.HasPrefix(response.Result, "Pending"):
+			continue
+		case response.Status == "1" || strings.Contains(response.Result, "Already Verified"):
+			fmt.Fprintln(os.Stderr, "Verified:", response.Result)
+			return
+		default:
+			fatalf("Verification failed: %v\n", response.Result)
+		}
+	}
+	fatalf("Verification is still pending. Check its status with the GUID %v.\n", guid)
+}
+
+// verifyCmd verifies the source of a deployed copy of the contract on Etherscan.
+func verifyCmd(name string, metadata string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify <address>",
+		Short: "Verify the source of a deployed copy of " + name + " on Etherscan",
+		Long: "Submits the contract's source and compiler settings, and its constructor arguments, to Etherscan's verification API for the current chain.\n" +
+			"The constructor arguments come from --constructor-args, or else from the deployments file.",
+		Example: "  poke Token.sol verify 0x1234... --network sepolia",
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			address := parseAddress(args[0])
+			constructorArgs := viper.GetString("constructor-args")
+			if !isExplicitlySet("constructor-args") {
+				for _, deployment := range readDeployments()[registryNetwork()] {
+					if common.HexToAddress(deployment.Address) == address {
+						constructorArgs = deployment.ConstructorArgs
+					}
+				}
+			}
+			verifyContract(metadata, address, constructorArgs)
+		},
+	}
+}
+
+// fetchABI downloads the ABI of the verified contract at address from Etherscan, and returns
+// it as a cacheObject for the contract `name`, without bytecode. ABIs are cached in ~/.poke/abis.
+func fetchABI(name string, address common.Address) *cacheObject {
+	cacheFile := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		cacheFile = filepath.Join(home, ".poke", "abis", getChainID().String(), address.Hex()+".json")
+		if cached, err := ioutil.ReadFile(cacheFile); err == nil {
+			return &cacheObject{ABI: string(cached), Name: name}
+		}
+	}
+
+	response, err := etherscanRequest(url.Values{
+		"module":  {"contract"},
+		"action":  {"getabi"},
+		"address": {address.Hex()},
+	}, false)
+	check(err, "fetching ABI")
+	if response.Status != "1" {
+		fatalf("Etherscan has no ABI for %v: %v\n", address.Hex(), response.Result)
+	}
+	if cacheFile != "" {
+		check(os.MkdirAll(filepath.Dir(cacheFile), 0755), "creating ABI cache directory")
+		check(ioutil.WriteFile(cacheFile, []byte(response.Result), 0644), "caching ABI")
+	}
+	return &cacheObject{ABI: response.Result, Name: name}
+}
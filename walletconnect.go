@@ -0,0 +1,640 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gorilla/websocket"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/xerrors"
+)
+
+// walletConnectFrom is the --from value that signs with a mobile wallet, connected over WalletConnect v2.
+// The wallet holds the key, and the user approves each request on their phone.
+const walletConnectFrom = "walletconnect"
+
+// walletConnectTimeout is how long to wait for the user to scan the pairing code or approve a request,
+// which is also how long the relay keeps messages for the wallet.
+const walletConnectTimeout = 5 * time.Minute
+
+// Tags tell the relay, and the wallet's push notifications, what kind of message is published.
+// A response's tag is its request's plus one.
+const (
+	wcSessionProposeTag = 1100
+	wcSessionSettleTag  = 1102
+	wcSessionRequestTag = 1108
+)
+
+// wcRPC is a JSON-RPC 2.0 message, both to and from the relay and, encrypted, to and from the wallet.
+type wcRPC struct {
+	ID      int64           `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *wcError        `json:"error,omitempty"`
+}
+
+type wcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *wcError) Error() string {
+	return e.Message
+}
+
+// wcMessage is a message from the wallet, decrypted.
+type wcMessage struct {
+	topic string
+	tag   int
+	rpc   wcRPC
+}
+
+// walletConnectSession is a session with a wallet, as saved to ~/.poke/walletconnect.json to be reused by later runs.
+type walletConnectSession struct {
+	Topic    string        `json:"topic"`
+	SymKey   hexutil.Bytes `json:"symKey"`
+	Accounts []string      `json:"accounts"` // CAIP-10 accounts, like eip155:1:0xabc...
+	Methods  []string      `json:"methods"`
+	Expiry   int64         `json:"expiry"`
+	Wallet   string        `json:"wallet"`
+}
+
+// account returns the session's account on chainID, if it has one.
+func (s *walletConnectSession) account(chainID *big.Int) (common.Address, bool) {
+	prefix := fmt.Sprintf("eip155:%v:", chainID)
+	for _, account := range s.Accounts {
+		if strings.HasPrefix(account, prefix) && common.IsHexAddress(strings.TrimPrefix(account, prefix)) {
+			return common.HexToAddress(strings.TrimPrefix(account, prefix)), true
+		}
+	}
+	return common.Address{}, false
+}
+
+func (s *walletConnectSession) supports(method string) bool {
+	for _, m := range s.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// walletConnectClient is a connection to a WalletConnect relay, which passes encrypted messages between poke and the wallet.
+type walletConnectClient struct {
+	conn *websocket.Conn
+	// keys are the symmetric keys of the topics subscribed to.
+	keys map[string][]byte
+	// received holds messages from the wallet that arrived while waiting for something else.
+	received []wcMessage
+}
+
+var (
+	wcClient  *walletConnectClient
+	wcSession *walletConnectSession
+	wcLastID  int64
+)
+
+// wcPayloadID returns a new JSON-RPC id, as WalletConnect's own clients make them: the time in microseconds,
+// so that they don't repeat across runs that share a session.
+func wcPayloadID() int64 {
+	id := time.Now().UnixNano() / 1000
+	if id <= wcLastID {
+		id = wcLastID + 1
+	}
+	wcLastID = id
+	return id
+}
+
+func walletConnectSessionFile() string {
+	home, err := os.UserHomeDir()
+	check(err, "finding home directory")
+	return filepath.Join(home, ".poke", "walletconnect.json")
+}
+
+// getWalletConnectSession returns a session with a wallet on chainID, connecting to the relay on first use.
+// It reuses the session saved by an earlier run if there is one for the chain. Otherwise it prints a pairing URI,
+// as a QR code, for the user to scan with their wallet, and waits for them to approve the session.
+func getWalletConnectSession(chainID *big.Int) *walletConnectSession {
+	if wcSession != nil {
+		if _, ok := wcSession.account(chainID); ok {
+			return wcSession
+		}
+	}
+	if wcClient == nil {
+		wcClient = dialWalletConnectRelay()
+	}
+	if data, err := ioutil.ReadFile(walletConnectSessionFile()); err == nil {
+		var saved walletConnectSession
+		check(json.Unmarshal(data, &saved), "reading "+walletConnectSessionFile())
+		_, ok := saved.account(chainID)
+		// Leave a minute to approve requests in.
+		if ok && time.Unix(saved.Expiry, 0).After(time.Now().Add(time.Minute)) {
+			wcSession = &saved
+			wcClient.subscribe(saved.Topic, saved.SymKey)
+			return wcSession
+		}
+	}
+	wcSession = wcClient.pair(chainID)
+	data, err := json.MarshalIndent(wcSession, "", "  ")
+	check(err, "encoding WalletConnect session")
+	file := walletConnectSessionFile()
+	check(os.MkdirAll(filepath.Dir(file), 0700), "creating "+filepath.Dir(file))
+	// The key lets anyone who reads it ask the wallet to sign things, so keep it private.
+	check(ioutil.WriteFile(file, data, 0600), "saving WalletConnect session")
+	return wcSession
+}
+
+// forgetWalletConnectSession deletes the saved session, so that the next run pairs again.
+func forgetWalletConnectSession() {
+	os.Remove(walletConnectSessionFile())
+	wcSession = nil
+}
+
+// dialWalletConnectRelay connects to the relay given by --walletconnect-relay, authenticating with --walletconnect-project-id.
+// The relay wants a JWT signed by a client key, which needn't be the same from run to run.
+func dialWalletConnectRelay() *walletConnectClient {
+	projectID := viper.GetString("walletconnect-project-id")
+	if projectID == "" {
+		fatal("WalletConnect needs a project ID, which you can get for free from WalletConnect Cloud. " +
+			"Give it with --walletconnect-project-id or POKE_WALLETCONNECT_PROJECT_ID.")
+	}
+	relay := strings.TrimSuffix(viper.GetString("walletconnect-relay"), "/")
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	check(err, "generating WalletConnect client key")
+	query := url.Values{
+		"auth":      {walletConnectRelayJWT(relay, pub, priv)},
+		"projectId": {projectID},
+		"ua":        {"wc-2/poke/go"},
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(relay+"/?"+query.Encode(), nil)
+	check(err, "connecting to WalletConnect relay "+relay)
+	atExit(func() { conn.Close() })
+	return &walletConnectClient{conn: conn, keys: make(map[string][]byte)}
+}
+
+// walletConnectRelayJWT returns the JWT that authenticates a client key to the relay at aud.
+// The key is identified by its did:key, and the token is signed with it, with EdDSA.
+func walletConnectRelayJWT(aud string, pub ed25519.PublicKey, priv ed25519.PrivateKey) string {
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		check(err, "encoding JWT")
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	now := time.Now().Unix()
+	unsigned := encode(map[string]string{"alg": "EdDSA", "typ": "JWT"}) + "." + encode(map[string]interface{}{
+		"iss": "did:key:z" + base58Encode(append([]byte{0xed, 0x01}, pub...)),
+		"sub": hex.EncodeToString(randomBytes(32)),
+		"aud": aud,
+		"iat": now,
+		"exp": now + 24*60*60,
+	})
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, []byte(unsigned)))
+}
+
+// call sends a request to the relay itself and returns its result, setting aside any messages from the wallet that arrive first.
+func (c *walletConnectClient) call(method string, params interface{}) json.RawMessage {
+	data, err := json.Marshal(params)
+	check(err, "encoding "+method)
+	req := wcRPC{ID: wcPayloadID(), JSONRPC: "2.0", Method: method, Params: data}
+	check(c.conn.WriteJSON(req), "sending "+method+" to WalletConnect relay")
+	c.conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	for {
+		msg, ok := c.read()
+		if ok && msg.Method == "" && msg.ID == req.ID {
+			if msg.Error != nil {
+				fatalf("The WalletConnect relay failed %v: %v\n", method, msg.Error)
+			}
+			return msg.Result
+		}
+	}
+}
+
+// read reads the next message from the relay, and reports whether it's from the relay itself.
+// Messages the relay delivers from the wallet are instead acknowledged, decrypted, and set aside for receive.
+func (c *walletConnectClient) read() (wcRPC, bool) {
+	var msg wcRPC
+	if err := c.conn.ReadJSON(&msg); err != nil {
+		if err, ok := err.(interface{ Timeout() bool }); ok && err.Timeout() {
+			failf(timeoutError, "Timed out waiting for the wallet. If it's no longer connected, delete %v to pair it again.\n", walletConnectSessionFile())
+		}
+		fatalf("Reading from WalletConnect relay: %v\n", err)
+	}
+	if msg.Method != "irn_subscription" {
+		return msg, true
+	}
+	var params struct {
+		Data struct {
+			Topic   string `json:"topic"`
+			Message string `json:"message"`
+			Tag     int    `json:"tag"`
+		} `json:"data"`
+	}
+	check(json.Unmarshal(msg.Params, &params), "decoding message from WalletConnect relay")
+	check(c.conn.WriteJSON(wcRPC{ID: msg.ID, JSONRPC: "2.0", Result: json.RawMessage("true")}), "acknowledging message")
+	key, ok := c.keys[params.Data.Topic]
+	if !ok {
+		return msg, false
+	}
+	plaintext, err := wcDecrypt(key, params.Data.Message)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring a message from the wallet that can't be decrypted: %v\n", err)
+		return msg, false
+	}
+	var rpc wcRPC
+	if err := json.Unmarshal(plaintext, &rpc); err != nil {
+		fmt.Fprintf(os.Stderr, "Ignoring a message from the wallet that isn't JSON-RPC: %v\n", err)
+		return msg, false
+	}
+	c.received = append(c.received, wcMessage{topic: params.Data.Topic, tag: params.Data.Tag, rpc: rpc})
+	return msg, false
+}
+
+// receive waits for a message from the wallet on topic that wanted accepts.
+// Requests from the wallet that it doesn't want, like pings and events, are answered with true.
+func (c *walletConnectClient) receive(topic string, wanted func(wcRPC) bool) wcMessage {
+	deadline := time.Now().Add(walletConnectTimeout)
+	for {
+		for len(c.received) == 0 {
+			// Anything but a message from the wallet would be a stray response from the relay.
+			c.conn.SetReadDeadline(deadline)
+			c.read()
+		}
+		msg := c.received[0]
+		c.received = c.received[1:]
+		if msg.topic != topic {
+			continue
+		}
+		if wanted(msg.rpc) {
+			return msg
+		}
+		if msg.rpc.Method != "" {
+			c.publish(topic, wcRPC{ID: msg.rpc.ID, JSONRPC: "2.0", Result: json.RawMessage("true")}, msg.tag+1, false)
+		}
+		if msg.rpc.Method == "wc_sessionDelete" {
+			forgetWalletConnectSession()
+			fatal("The wallet ended the WalletConnect session. Run again to pair again.")
+		}
+	}
+}
+
+// subscribe asks the relay for the messages published to topic, which are encrypted with key.
+func (c *walletConnectClient) subscribe(topic string, key []byte) {
+	c.keys[topic] = key
+	c.call("irn_subscribe", map[string]string{"topic": topic})
+}
+
+// publish encrypts msg with topic's key and publishes it to the wallet. prompt asks the wallet to notify the user.
+func (c *walletConnectClient) publish(topic string, msg wcRPC, tag int, prompt bool) {
+	plaintext, err := json.Marshal(msg)
+	check(err, "encoding message to wallet")
+	c.call("irn_publish", map[string]interface{}{
+		"topic":   topic,
+		"message": wcEncrypt(c.keys[topic], plaintext),
+		"ttl":     int(walletConnectTimeout.Seconds()),
+		"tag":     tag,
+		"prompt":  prompt,
+	})
+}
+
+// request sends a JSON-RPC request to the wallet on topic and waits for its response.
+func (c *walletConnectClient) request(topic, method string, params interface{}, tag int) (json.RawMessage, error) {
+	data, err := json.Marshal(params)
+	check(err, "encoding "+method)
+	req := wcRPC{ID: wcPayloadID(), JSONRPC: "2.0", Method: method, Params: data}
+	c.publish(topic, req, tag, true)
+	resp := c.receive(topic, func(rpc wcRPC) bool { return rpc.Method == "" && rpc.ID == req.ID })
+	if resp.rpc.Error != nil {
+		// The wallet declined the request, as when its user rejects it.
+		return nil, withClass(refusedError, resp.rpc.Error)
+	}
+	return resp.rpc.Result, nil
+}
+
+// pair proposes a session on chainID to a new wallet, shows the pairing URI for the user to scan,
+// and waits for the wallet to approve the proposal and settle the session.
+func (c *walletConnectClient) pair(chainID *big.Int) *walletConnectSession {
+	pairingTopic := hex.EncodeToString(randomBytes(32))
+	symKey := randomBytes(32)
+	c.subscribe(pairingTopic, symKey)
+
+	// The session's key is agreed by X25519 between a key of ours and one of the wallet's.
+	privateKey := randomBytes(curve25519.ScalarSize)
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	check(err, "generating WalletConnect session key")
+	chain := fmt.Sprintf("eip155:%v", chainID)
+	proposal := map[string]interface{}{
+		"requiredNamespaces": map[string]interface{}{
+			"eip155": map[string]interface{}{
+				"chains":  []string{chain},
+				"methods": []string{"eth_sendTransaction", "personal_sign"},
+				"events":  []string{"chainChanged", "accountsChanged"},
+			},
+		},
+		"optionalNamespaces": map[string]interface{}{
+			"eip155": map[string]interface{}{
+				"chains":  []string{chain},
+				"methods": []string{"eth_signTransaction", "eth_signTypedData_v4"},
+				"events":  []string{},
+			},
+		},
+		"relays": []map[string]string{{"protocol": "irn"}},
+		"proposer": map[string]interface{}{
+			"publicKey": hex.EncodeToString(publicKey),
+			"metadata": map[string]interface{}{
+				"name":        "poke",
+				"description": "Command-line tool for Ethereum contracts",
+				"url":         "https://github.com/reserve-protocol/poke",
+				"icons":       []string{},
+			},
+		},
+	}
+	data, err := json.Marshal(proposal)
+	check(err, "encoding session proposal")
+	propose := wcRPC{ID: wcPayloadID(), JSONRPC: "2.0", Method: "wc_sessionPropose", Params: data}
+	c.publish(pairingTopic, propose, wcSessionProposeTag, true)
+
+	uri := fmt.Sprintf("wc:%v@2?relay-protocol=irn&symKey=%x&expiryTimestamp=%v",
+		pairingTopic, symKey, time.Now().Add(walletConnectTimeout).Unix())
+	fmt.Fprintln(os.Stderr, "Scan this with your wallet to connect it with WalletConnect:")
+	if qr, err := encodeQR([]byte(uri)); err == nil {
+		fmt.Fprint(os.Stderr, qr.terminal())
+	}
+	fmt.Fprintf(os.Stderr, "Or paste this URI into it:\n%v\n", uri)
+
+	resp := c.receive(pairingTopic, func(rpc wcRPC) bool { return rpc.Method == "" && rpc.ID == propose.ID })
+	if resp.rpc.Error != nil {
+		failf(refusedError, "The wallet rejected the connection: %v\n", resp.rpc.Error)
+	}
+	var approval struct {
+		ResponderPublicKey string `json:"responderPublicKey"`
+	}
+	check(json.Unmarshal(resp.rpc.Result, &approval), "decoding the wallet's approval")
+	responderPublicKey, err := hex.DecodeString(approval.ResponderPublicKey)
+	check(err, "decoding the wallet's public key")
+	sharedSecret, err := curve25519.X25519(privateKey, responderPublicKey)
+	check(err, "agreeing on a key with the wallet")
+	sessionKey := make([]byte, 32)
+	_, err = io.ReadFull(hkdf.New(sha256.New, sharedSecret, nil, nil), sessionKey)
+	check(err, "deriving session key")
+	sessionTopic := sha256.Sum256(sessionKey)
+	session := &walletConnectSession{Topic: hex.EncodeToString(sessionTopic[:]), SymKey: sessionKey}
+	c.subscribe(session.Topic, sessionKey)
+
+	// Once it's approved, the wallet settles the session: it says which accounts, and which methods, it's for.
+	settle := c.receive(session.Topic, func(rpc wcRPC) bool { return rpc.Method == "wc_sessionSettle" })
+	var params struct {
+		Namespaces map[string]struct {
+			Accounts []string `json:"accounts"`
+			Methods  []string `json:"methods"`
+		} `json:"namespaces"`
+		Controller struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		} `json:"controller"`
+		Expiry int64 `json:"expiry"`
+	}
+	check(json.Unmarshal(settle.rpc.Params, &params), "decoding the wallet's session")
+	c.publish(session.Topic, wcRPC{ID: settle.rpc.ID, JSONRPC: "2.0", Result: json.RawMessage("true")}, wcSessionSettleTag+1, false)
+	session.Accounts = params.Namespaces["eip155"].Accounts
+	session.Methods = params.Namespaces["eip155"].Methods
+	session.Expiry = params.Expiry
+	session.Wallet = params.Controller.Metadata.Name
+	if _, ok := session.account(chainID); !ok {
+		fatalf("The wallet connected, but without an account on chain %v\n", chainID)
+	}
+	fmt.Fprintf(os.Stderr, "Connected to %v.\n", session.Wallet)
+	return session
+}
+
+// walletConnectRequest asks the wallet to handle an Ethereum JSON-RPC request on chainID, and returns its result.
+func walletConnectRequest(chainID *big.Int, method string, params interface{}) (json.RawMessage, error) {
+	session := getWalletConnectSession(chainID)
+	fmt.Fprintf(os.Stderr, "Waiting for you to confirm in %v...\n", session.Wallet)
+	return wcClient.request(session.Topic, "wc_sessionRequest", map[string]interface{}{
+		"request": map[string]interface{}{"method": method, "params": params},
+		"chainId": fmt.Sprintf("eip155:%v", chainID),
+	}, wcSessionRequestTag)
+}
+
+// walletConnectAddress returns the address of the wallet's account on chainID.
+func walletConnectAddress(chainID *big.Int) common.Address {
+	address, _ := getWalletConnectSession(chainID).account(chainID)
+	return address
+}
+
+// walletConnectSignsTransactions reports whether the wallet can sign transactions without sending them.
+// Most mobile wallets can't, and only send them.
+func walletConnectSignsTransactions(chainID *big.Int) bool {
+	return getWalletConnectSession(chainID).supports("eth_signTransaction")
+}
+
+// walletConnectTxArgs returns tx as the transaction object eth_sendTransaction and eth_signTransaction take.
+func walletConnectTxArgs(from common.Address, tx *types.Transaction) map[string]interface{} {
+	args := map[string]interface{}{
+		"from":  from,
+		"data":  hexutil.Bytes(tx.Data()),
+		"value": (*hexutil.Big)(tx.Value()),
+		"gas":   hexutil.Uint64(tx.Gas()),
+		"nonce": hexutil.Uint64(tx.Nonce()),
+	}
+	if tx.To() != nil {
+		args["to"] = tx.To()
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		args["maxFeePerGas"] = (*hexutil.Big)(tx.GasFeeCap())
+		args["maxPriorityFeePerGas"] = (*hexutil.Big)(tx.GasTipCap())
+	} else {
+		args["gasPrice"] = (*hexutil.Big)(tx.GasPrice())
+	}
+	return args
+}
+
+// walletConnectSignTx has the wallet sign tx, with eth_signTransaction, and checks that it signed the transaction it was given.
+// Wallets may change the gas and fees.
+func walletConnectSignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	from := walletConnectAddress(chainID)
+	result, err := walletConnectRequest(chainID, "eth_signTransaction", []interface{}{walletConnectTxArgs(from, tx)})
+	if err != nil {
+		return nil, err
+	}
+	var raw hexutil.Bytes
+	if err := json.Unmarshal(result, &raw); err != nil {
+		return nil, xerrors.Errorf("the wallet returned something other than a signed transaction: %s", result)
+	}
+	signed := new(types.Transaction)
+	if err := signed.UnmarshalBinary(raw); err != nil {
+		return nil, xerrors.Errorf("decoding the wallet's transaction: %w", err)
+	}
+	if !signed.Protected() || signed.ChainId().Cmp(chainID) != 0 {
+		return nil, xerrors.Errorf("the wallet's transaction isn't signed for chain %v", chainID)
+	}
+	if sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed); err != nil || sender != from {
+		return nil, xerrors.Errorf("the wallet's transaction isn't signed by %v", from.Hex())
+	}
+	if !sameCall(tx, signed) {
+		return nil, xerrors.New("the wallet signed a different transaction than the one it was asked to")
+	}
+	return signed, nil
+}
+
+// walletConnectSendTransaction has the wallet sign and send tx, with eth_sendTransaction, for wallets that can't only sign it.
+// It returns the transaction as the node has it, since the wallet may have changed the gas, fees, and nonce.
+func walletConnectSendTransaction(tx *types.Transaction) (*types.Transaction, error) {
+	chainID := getChainID()
+	result, err := walletConnectRequest(chainID, "eth_sendTransaction", []interface{}{walletConnectTxArgs(walletConnectAddress(chainID), tx)})
+	if err != nil {
+		return nil, err
+	}
+	var hash common.Hash
+	if err := json.Unmarshal(result, &hash); err != nil {
+		return nil, xerrors.Errorf("the wallet returned something other than a transaction hash: %s", result)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for {
+		sent, _, err := getNode().TransactionByHash(ctx, hash)
+		if err == nil {
+			if !sameCall(tx, sent) {
+				return sent, xerrors.Errorf("the wallet sent a different transaction, %v, than the one it was asked to", hash.Hex())
+			}
+			return sent, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, xerrors.Errorf("the wallet sent transaction %v, but the node hasn't seen it", hash.Hex())
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// sameCall reports whether a and b call the same contract with the same value and calldata.
+func sameCall(a, b *types.Transaction) bool {
+	if (a.To() == nil) != (b.To() == nil) || (a.To() != nil && *a.To() != *b.To()) {
+		return false
+	}
+	return a.Value().Cmp(b.Value()) == 0 && bytes.Equal(a.Data(), b.Data())
+}
+
+// walletConnectTransactOpts returns transaction options that sign with the wallet, if it can sign transactions.
+// If it can only send them, the transaction is left unsigned, for the transactor to have the wallet send it.
+func walletConnectTransactOpts() *bind.TransactOpts {
+	chainID := getChainID()
+	signs := walletConnectSignsTransactions(chainID)
+	if !signs && viper.GetBool("offline") {
+		fatal("The wallet can only send transactions, not sign them for --offline")
+	}
+	return &bind.TransactOpts{
+		From: walletConnectAddress(chainID),
+		Signer: func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if !signs {
+				return tx, nil
+			}
+			return walletConnectSignTx(tx, chainID)
+		},
+	}
+}
+
+// walletConnectSignMessage has the wallet sign text with personal_sign, and checks that its account signed it.
+func walletConnectSignMessage(text []byte) ([]byte, error) {
+	chainID := getChainID()
+	from := walletConnectAddress(chainID)
+	result, err := walletConnectRequest(chainID, "personal_sign", []interface{}{hexutil.Bytes(text), from})
+	if err != nil {
+		return nil, err
+	}
+	var sig hexutil.Bytes
+	if err := json.Unmarshal(result, &sig); err != nil {
+		return nil, xerrors.Errorf("the wallet returned something other than a signature: %s", result)
+	}
+	if signer, err := recoverSigner(accounts.TextHash(text), sig); err != nil || signer != from || len(sig) != 65 {
+		return nil, xerrors.Errorf("the wallet's signature isn't from %v", from.Hex())
+	}
+	return sig, nil
+}
+
+// wcEncrypt encrypts plaintext with key in a type 0 envelope, as WalletConnect does:
+// the type, a random nonce, and the ChaCha20-Poly1305 ciphertext, base64-encoded.
+func wcEncrypt(key, plaintext []byte) string {
+	aead, err := chacha20poly1305.New(key)
+	check(err, "encrypting message to wallet")
+	nonce := randomBytes(chacha20poly1305.NonceSize)
+	envelope := append(append([]byte{0}, nonce...), aead.Seal(nil, nonce, plaintext, nil)...)
+	return base64.StdEncoding.EncodeToString(envelope)
+}
+
+// wcDecrypt decrypts a message from wcEncrypt, or a type 1 envelope, which also carries the sender's public key.
+func wcDecrypt(key []byte, message string) ([]byte, error) {
+	envelope, err := base64.StdEncoding.DecodeString(message)
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope) > 33 && envelope[0] == 1 {
+		// The public key is for agreeing on a key, which the key we have already is.
+		envelope = append([]byte{0}, envelope[33:]...)
+	}
+	if len(envelope) < 1+chacha20poly1305.NonceSize || envelope[0] != 0 {
+		return nil, xerrors.New("unknown envelope")
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := envelope[1 : 1+chacha20poly1305.NonceSize]
+	return aead.Open(nil, nonce, envelope[1+chacha20poly1305.NonceSize:], nil)
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	check(err, "generating random bytes")
+	return b
+}
+
+// base58Encode encodes data in Bitcoin's base58 alphabet, as did:key uses.
+func base58Encode(data []byte) string {
+	const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	var out []byte
+	for n.Sign() > 0 {
+		mod := new(big.Int)
+		n.DivMod(n, radix, mod)
+		out = append(out, alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
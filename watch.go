@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// maxWatchBackoff bounds the wait between attempts to reconnect to the node.
+const maxWatchBackoff = 30 * time.Second
+
+// watchCmd streams the contract's events as they are mined, over a websocket subscription.
+func watchCmd(theABI abi.ABI) *cobra.Command {
+	return &cobra.Command{
+		Use:     "watch [EventName...] | watch <method> [arg...]",
+		Aliases: []string{"watch-events"},
+		Short:   "Print events as they are mined, or a view function's value every --interval, until interrupted",
+		Long: "Prints the contract's events named EventName as they are mined, or all its events if none are named. " +
+			"That needs a ws:// or wss:// node.\n" +
+			"With one event, --topic filters on its indexed arguments, as with the events command.\n" +
+			"Given a view function instead, calls it every --interval and prints its value with the time, " +
+			"or with --on-change, only when the value changes.\n" +
+			"With --output json, each event or value is printed as a line of JSON.",
+		Example: "  poke Token.sol watch Transfer Approval -n ws://localhost:8546\n" +
+			"  poke Token.sol watch totalSupply --interval 15s --on-change",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) > 0 && cmd.CalledAs() != "watch-events" {
+				if _, isEvent := theABI.Events[args[0]]; !isEvent {
+					if method, err := lookupViewMethod(theABI, args[0]); err == nil {
+						pollView(theABI, method, args[1:])
+						return
+					}
+				}
+			}
+			var ids []common.Hash
+			for _, name := range args {
+				event, ok := theABI.Events[name]
+				if !ok {
+					fatalf("The contract has no event named %q\n", name)
+				}
+				ids = append(ids, event.ID)
+			}
+			if len(args) == 0 {
+				for _, event := range theABI.Events {
+					ids = append(ids, event.ID)
+				}
+			}
+			topics := [][]common.Hash{ids}
+			if filters := viper.GetStringSlice("topic"); len(filters) > 0 {
+				if len(args) != 1 {
+					fatal("--topic needs exactly one event to watch, to know which arguments it refers to.")
+				}
+				topics = eventTopics(theABI.Events[args[0]], filters)
+			}
+
+			nodeURL := getNodeURL()
+			if !strings.HasPrefix(nodeURL, "ws://") && !strings.HasPrefix(nodeURL, "wss://") {
+				fatalf("watching events needs a websocket connection, but the node URL is %q\n", nodeURL)
+			}
+			address := getContractAddress()
+			query := ethereum.FilterQuery{
+				Addresses: []common.Address{address},
+				Topics:    topics,
+			}
+			watchLogs(nodeURL, query, func(log types.Log) {
+				if jsonOutput() {
+					event := eventJSON(theABI, log)
+					if log.Removed {
+						event["removed"] = true
+					}
+					line, err := json.Marshal(event)
+					check(err, "encoding JSON output")
+					fmt.Println(string(line))
+					return
+				}
+				if log.Removed {
+					fmt.Printf("Block %v, transaction %v, undone by a reorg:\n", log.BlockNumber, log.TxHash.Hex())
+				} else {
+					fmt.Printf("Block %v, transaction %v:\n", log.BlockNumber, log.TxHash.Hex())
+				}
+				printEvent(theABI, log)
+			})
+		},
+	}
+}
+
+// logKey identifies a log within the chain.
+type logKey struct {
+	block uint64
+	index uint
+}
+
+// watchLogs subscribes to the logs matching query, and calls handle on each one.
+// It runs until the process is interrupted. When the subscription drops, it reconnects
+// with exponential backoff, and catches up on any logs it missed in the meantime.
+// Logs a reorg undoes are passed to handle again, with Removed set.
+func watchLogs(nodeURL string, query ethereum.FilterQuery, handle func(types.Log)) {
+	ctx := context.Background()
+	backoff := time.Second
+	// processed is the last block whose logs have all been handled, and seen holds the logs handled
+	// in the blocks after it, so that those a reconnection sees again aren't handled twice.
+	var processed *big.Int
+	seen := make(map[logKey]bool)
+	advance := func(block uint64) {
+		if block > processed.Uint64() {
+			processed.SetUint64(block)
+			for key := range seen {
+				if key.block <= block {
+					delete(seen, key)
+				}
+			}
+		}
+	}
+
+	deliver := func(log types.Log) {
+		key := logKey{log.BlockNumber, log.Index}
+		done := seen[key] || log.BlockNumber <= processed.Uint64()
+		if log.Removed {
+			// The log's block is no longer part of the chain, so the one replacing it has yet to be processed.
+			if done {
+				delete(seen, key)
+				handle(log)
+			}
+			if log.BlockNumber <= processed.Uint64() {
+				processed.SetUint64(log.BlockNumber - 1)
+			}
+			return
+		}
+		if done {
+			return
+		}
+		// Logs arrive in order, so every block before this log's has been processed.
+		advance(log.BlockNumber - 1)
+		seen[key] = true
+		handle(log)
+	}
+
+	// subscribe streams logs to deliver until the subscription fails.
+	// It reports whether it managed to subscribe at all.
+	subscribe := func() (subscribed bool, err error) {
+		client, err := ethclient.Dial(nodeURL)
+		if err != nil {
+			return false, err
+		}
+		defer client.Close()
+
+		// Subscribe before catching up, so that no block falls between the two.
+		// Logs both see are only delivered once.
+		logs := make(chan types.Log)
+		sub, err := client.SubscribeFilterLogs(ctx, query, logs)
+		if err != nil {
+			return false, err
+		}
+		defer sub.Unsubscribe()
+
+		header, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return false, err
+		}
+		if processed == nil {
+			processed = header.Number
+		} else if header.Number.Cmp(processed) > 0 {
+			// Catch up from the last block processed, rather than the last log handled,
+			// since the connection might have dropped partway through a block's logs.
+			missedQuery := query
+			missedQuery.FromBlock = new(big.Int).Add(processed, big.NewInt(1))
+			missedQuery.ToBlock = header.Number
+			missed, err := client.FilterLogs(ctx, missedQuery)
+			if err != nil {
+				return false, err
+			}
+			for _, log := range missed {
+				deliver(log)
+			}
+			advance(header.Number.Uint64())
+		}
+
+		for {
+			select {
+			case err := <-sub.Err():
+				return true, err
+			case log := <-logs:
+				deliver(log)
+			}
+		}
+	}
+
+	for {
+		started := time.Now()
+		subscribed, err := subscribe()
+		if subscribed {
+			// Only a subscription that lasted a while shows the node is back to normal.
+			if time.Since(started) > maxWatchBackoff {
+				backoff = time.Second
+			}
+			fmt.Fprintf(os.Stderr, "Lost the event subscription (%v). Reconnecting in %v...\n", err, backoff)
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to subscribe to events (%v). Retrying in %v...\n", err, backoff)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > maxWatchBackoff {
+			backoff = maxWatchBackoff
+		}
+	}
+}
+
+// pollView calls method with args every --interval, and prints its value with the time, until interrupted.
+// With --on-change, it only prints values that differ from the last one. Failed calls are reported, and retried
+// at the next interval.
+func pollView(theABI abi.ABI, method abi.Method, args []string) {
+	if len(args) != len(method.Inputs) {
+		fatalf("%v takes %v arguments, but got %v\n", method.Sig, len(method.Inputs), len(args))
+	}
+	interval := viper.GetDuration("interval")
+	if interval <= 0 {
+		fatal("--interval must be positive.")
+	}
+	inputs := parseArgs(method.Sig, method.Inputs, args)
+	var last string
+	for first := true; ; first = false {
+		if !first {
+			time.Sleep(interval)
+		}
+		var results []interface{}
+		err := getDeployment(theABI).Call(callOpts(), &results, method.Name, inputs...)
+		now := time.Now().UTC().Format(time.RFC3339)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v calling %v: %v\n", now, method.RawName, explainRevert(theABI, err))
+			continue
+		}
+		fields := make(map[string]string)
+		values := make([]string, len(method.Outputs))
+		for i, output := range method.Outputs {
+			name := output.Name
+			if name == "" {
+				name = fmt.Sprint(i)
+			}
+			fields[name] = displayValue(output.Type, results[i])
+			values[i] = fields[name]
+			if len(method.Outputs) > 1 {
+				values[i] = name + ": " + values[i]
+			}
+		}
+		value := strings.Join(values, ", ")
+		if viper.GetBool("on-change") && !first && value == last {
+			continue
+		}
+		last = value
+		if jsonOutput() {
+			line, err := json.Marshal(map[string]interface{}{"time": now, "result": fields})
+			check(err, "encoding JSON output")
+			fmt.Println(string(line))
+			continue
+		}
+		fmt.Printf("%v %v\n", now, value)
+	}
+}